@@ -18,6 +18,11 @@ func main() {
 	app.AddCommand(&subcmd.V3Command{})
 	app.AddCommand(&subcmd.V4Command{})
 	app.AddCommand(&subcmd.MigrationCommand{})
+	migrations := app.AddCommand(&subcmd.MigrationsCommand{})
+	migrations.AddCommand(&subcmd.MigrationsListCommand{})
+	migrations.AddCommand(&subcmd.MigrationsDeleteArchiveCommand{})
+	migrations.AddCommand(&subcmd.MigrationsUnlockCommand{})
+	app.AddCommand(&subcmd.CompareCommand{})
 
 	app.RunMain()
 }
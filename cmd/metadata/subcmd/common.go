@@ -1,42 +1,128 @@
 package subcmd
 
 import (
-	"bytes"
-	"io/ioutil"
+	"context"
+	"fmt"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/carlosms/metadata-retrieval-playground/internal/client"
+	"github.com/carlosms/metadata-retrieval-playground/internal/metrics"
+	"golang.org/x/oauth2"
 	"gopkg.in/src-d/go-log.v1"
 )
 
-func setLogTransport(client *http.Client, logger log.Logger) {
-	t := &logTransport{client.Transport, logger}
-	client.Transport = t
-}
+// clientOptions contains the flags shared by all the commands that use the
+// GitHub API
+type clientOptions struct {
+	Token       string `long:"token" short:"t" env:"SOURCED_GITHUB_TOKEN" description:"GitHub personal access token, required unless --replay is used"`
+	LogHTTP     bool   `long:"log-http" description:"log http requests (debug level)"`
+	LogHTTPFile string `long:"log-http-file" description:"write a JSON line for each http request to the given file"`
+	LogHTTPBody bool   `long:"log-http-body" description:"include the request and response bodies in the http logs"`
+	Record      string `long:"record" description:"record all the HTTP interactions in the given cassette file"`
+	Replay      string `long:"replay" description:"replay the HTTP interactions from the given cassette file instead of using the network"`
 
-type logTransport struct {
-	T      http.RoundTripper
-	Logger log.Logger
+	MaxRetries      int           `long:"max-retries" default:"5" description:"maximum number of retries for a failed request"`
+	RetryBudget     int           `long:"retry-budget" default:"100" description:"maximum number of retries for all the requests of the run"`
+	RetryMaxBackoff time.Duration `long:"retry-max-backoff" default:"1m" description:"maximum wait between retries"`
 }
 
-func (t *logTransport) RoundTrip(r *http.Request) (*http.Response, error) {
-	t0 := time.Now()
+// newHTTPClient returns the *http.Client to be passed to the downloaders,
+// already wrapped by client.NewClientWithOptions. The returned function must
+// be called once all the requests are done, it logs the retries done and
+// saves the recorded cassette when --record is used.
+func (o *clientOptions) newHTTPClient(logger log.Logger) (*http.Client, func(), error) {
+	done := func() {}
 
-	resp, err := t.T.RoundTrip(r)
-	if err != nil {
-		return resp, err
+	if o.Record != "" && o.Replay != "" {
+		return nil, done, fmt.Errorf("--record and --replay cannot be used at the same time")
 	}
 
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return resp, err
+	var httpClient *http.Client
+	if o.Replay != "" {
+		cassette, err := client.LoadCassette(o.Replay)
+		if err != nil {
+			return nil, done, err
+		}
+
+		httpClient = &http.Client{Transport: client.NewReplayer(cassette)}
+	} else {
+		if o.Token == "" {
+			return nil, done, fmt.Errorf("a GitHub token is required, use --token or SOURCED_GITHUB_TOKEN")
+		}
+
+		httpClient = oauth2.NewClient(context.TODO(), oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: o.Token},
+		))
+	}
+
+	save := func() {}
+	if o.Record != "" {
+		recorder := client.NewRecorder(httpClient.Transport)
+		httpClient.Transport = recorder
+		save = func() {
+			if err := recorder.Save(o.Record); err != nil {
+				logger.Errorf(err, "could not save the HTTP interactions in %s", o.Record)
+				return
+			}
+
+			logger.Infof("HTTP interactions recorded in %s", o.Record)
+		}
+	}
+
+	httpClient.Transport = metrics.NewTransport(httpClient.Transport)
+
+	closeLog := func() {}
+	if o.LogHTTP || o.LogHTTPFile != "" {
+		t := &client.LogTransport{
+			T:       httpClient.Transport,
+			Body:    o.LogHTTPBody,
+			Secrets: []string{o.Token},
+		}
+		if o.LogHTTP {
+			t.Logger = logger
+		}
+		if o.LogHTTPFile != "" {
+			f, err := os.Create(o.LogHTTPFile)
+			if err != nil {
+				return nil, done, fmt.Errorf("could not create the http log file: %v", err)
+			}
+
+			t.Out = f
+			closeLog = func() {
+				if err := f.Close(); err != nil {
+					logger.Errorf(err, "could not close the http log file %s", o.LogHTTPFile)
+				}
+			}
+		}
+
+		httpClient.Transport = t
 	}
 
-	t.Logger.With(
-		log.Fields{"elapsed": time.Since(t0), "code": resp.StatusCode, "url": r.URL, "body": string(b)},
-	).Debugf("HTTP response")
+	policy := client.DefaultRetryPolicy()
+	policy.MaxRetries = o.MaxRetries
+	policy.Budget = o.RetryBudget
+	policy.MaxBackoff = o.RetryMaxBackoff
+
+	done = func() {
+		if n := policy.Used(); n > 0 {
+			logger.Infof("%d failed requests were retried", n)
+		}
 
-	resp.Body = ioutil.NopCloser(bytes.NewBuffer(b))
+		save()
+		closeLog()
+	}
+
+	// the cassettes must not be filled from, or replayed into, the disk cache
+	httpClient, err := client.NewClientWithOptions(httpClient, client.Options{
+		RetryPolicy: policy,
+		NoCache:     o.Record != "" || o.Replay != "",
+	})
+	if err != nil {
+		closeLog()
+		return nil, func() {}, err
+	}
 
-	return resp, err
+	return httpClient, done, nil
 }
@@ -0,0 +1,121 @@
+package subcmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/carlosms/metadata-retrieval-playground/compare"
+	"github.com/carlosms/metadata-retrieval-playground/internal/store"
+	"github.com/carlosms/metadata-retrieval-playground/migration"
+	v3 "github.com/carlosms/metadata-retrieval-playground/v3"
+	v4 "github.com/carlosms/metadata-retrieval-playground/v4"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+type CompareCommand struct {
+	cli.Command `name:"compare" short-description:"compare the metadata of a repository downloaded with two strategies" long-description:"Downloads the repository with the strategies --a and --b, or loads it from a version stored in the DB, and reports the missing entities and the field differences"`
+
+	clientOptions
+
+	DB string `long:"db" description:"PostgreSQL URL connection string, required for the db:<version> sources"`
+
+	A string `long:"a" required:"true" description:"first source: v3, v3-shallow, v3-repo-comments, v4, migration or db:<version>"`
+	B string `long:"b" required:"true" description:"second source: v3, v3-shallow, v3-repo-comments, v4, migration or db:<version>"`
+
+	JSON            bool `long:"json" description:"print the report as JSON"`
+	ListDifferences bool `long:"list-differences" description:"list each field difference, not only the summary by field"`
+
+	Owner string `long:"owner"  required:"true"`
+	Name  string `long:"name"  required:"true"`
+}
+
+func (c *CompareCommand) Execute(args []string) error {
+	logger := log.New(log.Fields{"owner": c.Owner, "repo": c.Name})
+
+	httpClient, done, err := c.newHTTPClient(logger)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var db *sql.DB
+	if c.DB != "" {
+		db, err = (&dbOptions{DB: c.DB}).openDB()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	a, err := c.load(c.A, httpClient, db)
+	if err != nil {
+		return fmt.Errorf("could not load %s: %v", c.A, err)
+	}
+
+	b, err := c.load(c.B, httpClient, db)
+	if err != nil {
+		return fmt.Errorf("could not load %s: %v", c.B, err)
+	}
+
+	report := compare.Compare(c.A, a, c.B, b)
+	if c.JSON {
+		return report.PrintJSON(os.Stdout)
+	}
+
+	report.Print(os.Stdout, c.ListDifferences)
+	return nil
+}
+
+// load returns the entities of the repository downloaded or loaded from the
+// source
+func (c *CompareCommand) load(source string, httpClient *http.Client, db *sql.DB) (*compare.Snapshot, error) {
+	s := &compare.Snapshot{}
+
+	if strings.HasPrefix(source, "db:") {
+		if db == nil {
+			return nil, fmt.Errorf("the source %s requires --db", source)
+		}
+
+		err := store.LoadVersion(context.Background(), db, strings.TrimPrefix(source, "db:"), c.Owner, c.Name, s)
+		return s, err
+	}
+
+	// each downloader wraps the transport of its client
+	hc := *httpClient
+
+	var downloader metadata.MetadataDownloader
+	var err error
+	switch source {
+	case "v3", "v3-shallow", "v3-repo-comments":
+		d, err := v3.NewMetadataDownloader(&hc, s)
+		if err != nil {
+			return nil, err
+		}
+
+		if source == "v3-shallow" {
+			d.UseShallowMode()
+		}
+		if source == "v3-repo-comments" {
+			d.UseRepositoryComments()
+		}
+		downloader = d
+	case "v4":
+		downloader, err = v4.NewMetadataDownloader(&hc, s)
+	case "migration":
+		downloader, err = migration.NewMigrationDownloader(&hc, s)
+	default:
+		return nil, fmt.Errorf("unknown source %q, it must be v3, v3-shallow, v3-repo-comments, v4, migration or db:<version>", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = downloader.DownloadRepository(c.Owner, c.Name, "compare")
+	return s, err
+}
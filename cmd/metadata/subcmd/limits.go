@@ -8,19 +8,22 @@ import (
 
 	"github.com/google/go-github/github"
 	"github.com/shurcooL/githubv4"
-	"golang.org/x/oauth2"
 	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
 )
 
 type LimitsCommand struct {
 	cli.Command `name:"limits" short-description:"" long-description:""`
-	Token       string `long:"token" short:"t" env:"SOURCED_GITHUB_TOKEN" description:"GitHub personal access token" required:"true"`
+
+	clientOptions
 }
 
 func (c *LimitsCommand) Execute(args []string) error {
-	httpClient := oauth2.NewClient(context.TODO(), oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: c.Token},
-	))
+	httpClient, done, err := c.newHTTPClient(log.New(nil))
+	if err != nil {
+		return err
+	}
+	defer done()
 
 	v3Client := github.NewClient(httpClient)
 
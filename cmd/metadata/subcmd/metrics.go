@@ -0,0 +1,55 @@
+package subcmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/metrics"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// metricsOptions contains the flags to export the Prometheus metrics of a run
+type metricsOptions struct {
+	MetricsAddr    string `long:"metrics-addr" description:"serve the Prometheus metrics at this address while running, e.g. :9102"`
+	MetricsPushURL string `long:"metrics-push-url" description:"push the Prometheus metrics to this Pushgateway compatible URL at the end of the run"`
+	MetricsJob     string `long:"metrics-job" default:"metadata" description:"job name used to push the metrics"`
+}
+
+// startMetrics starts serving the metrics if --metrics-addr is set. The
+// returned function must be called at the end of the run with its result, it
+// records the run metrics and pushes them if --metrics-push-url is set.
+func (o *metricsOptions) startMetrics(command string, logger log.Logger) (func(error), error) {
+	t0 := time.Now()
+
+	stop := func() {}
+	if o.MetricsAddr != "" {
+		srv, err := metrics.Serve(o.MetricsAddr)
+		if err != nil {
+			return nil, fmt.Errorf("could not serve the metrics at %s: %v", o.MetricsAddr, err)
+		}
+
+		logger.Infof("serving metrics at %s/metrics", o.MetricsAddr)
+		stop = func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(ctx)
+		}
+	}
+
+	return func(err error) {
+		metrics.ObserveRun(command, t0, err)
+		stop()
+
+		if o.MetricsPushURL == "" {
+			return
+		}
+
+		if err := metrics.Push(o.MetricsPushURL, o.MetricsJob); err != nil {
+			logger.Errorf(err, "could not push the metrics to %s", o.MetricsPushURL)
+			return
+		}
+
+		logger.Infof("metrics pushed to %s", o.MetricsPushURL)
+	}, nil
+}
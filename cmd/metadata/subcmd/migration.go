@@ -1,10 +1,11 @@
 package subcmd
 
 import (
-	"context"
+	"time"
 
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/carlosms/metadata-retrieval-playground/internal/store"
 	"github.com/carlosms/metadata-retrieval-playground/migration"
-	"golang.org/x/oauth2"
 	"gopkg.in/src-d/go-cli.v0"
 	"gopkg.in/src-d/go-log.v1"
 )
@@ -12,29 +13,115 @@ import (
 type MigrationCommand struct {
 	cli.Command `name:"migration" short-description:"" long-description:""`
 
-	LogHTTP bool `long:"log-http" description:"log http requests (debug level)"`
+	clientOptions
+	metricsOptions
+	tracingOptions
 
-	//DB    string `long:"db" description:"PostgreSQL URL connection string" required:"true"`
-	Token string `long:"token" short:"t" env:"SOURCED_GITHUB_TOKEN" description:"GitHub personal access token" required:"true"`
+	dbOptions
+
+	Repositories []string `long:"repository" description:"when downloading an organization, a repository to download, can be repeated. By default all the repositories are downloaded"`
+	SkipArchived bool     `long:"skip-archived" description:"when downloading an organization, skip the archived repositories"`
+	SkipForks    bool     `long:"skip-forks" description:"when downloading an organization, skip the forks"`
+
+	MigrationID int64         `long:"migration-id" description:"resume an existing migration instead of starting a new one, waiting for it to be exported or downloading its archive again"`
+	PollTimeout time.Duration `long:"poll-timeout" default:"1h" description:"maximum time to wait for a migration to be exported"`
+
+	LockRepositories bool `long:"lock-repositories" description:"lock the repositories while they are exported"`
+	AutoCleanup      bool `long:"auto-cleanup" description:"delete the archive from GitHub, and unlock the repositories, once its contents are saved"`
+
+	Attachments    bool   `long:"attachments" description:"include the attachments of issues and comments, their URLs in the bodies are replaced with the stored paths"`
+	AttachmentsDir string `long:"attachments-dir" default:"downloads/attachments" description:"directory where the attachments are stored"`
+
+	RepositoryStorage string `long:"repository-storage" description:"directory where the bare git repositories of the archives are moved to, as <owner>/<name>.git. By default they are left in the archive"`
 
 	Owner string `long:"owner"  required:"true"`
-	Name  string `long:"name"  required:"true"`
+	Name  string `long:"name" description:"repository name, if it is empty the repositories of the --owner organization are downloaded"`
 }
 
-func (c *MigrationCommand) Execute(args []string) error {
-	client := oauth2.NewClient(context.TODO(), oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: c.Token},
-	))
+func (c *MigrationCommand) Execute(args []string) (err error) {
+	logger := log.New(log.Fields{"owner": c.Owner, "repo": c.Name})
+
+	finish, err := c.startMetrics("migration", logger)
+	if err != nil {
+		return err
+	}
+	defer func() { finish(err) }()
+
+	stopTracing, err := c.startTracing(logger)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	client, done, err := c.newHTTPClient(logger)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var storer metadata.Storer = store.MetadataStdoutStorer{}
+	if c.DB == "" {
+		log.Infof("using stdout to save the data")
+	} else {
+		db, err := c.openDB()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		storer = store.NewMetadataDBStorer(db)
+	}
+
+	downloader, err := migration.NewMigrationDownloader(client, storer)
+	if err != nil {
+		return err
+	}
+
+	downloader.SetPollTimeout(c.PollTimeout)
+	if c.MigrationID != 0 {
+		downloader.UseMigrationID(c.MigrationID)
+	}
+
+	if c.LockRepositories {
+		downloader.UseLockRepositories()
+	}
+
+	if c.AutoCleanup {
+		downloader.UseAutoCleanup()
+	}
+
+	if c.Attachments {
+		downloader.UseAttachments(c.AttachmentsDir)
+	}
+
+	if c.RepositoryStorage != "" {
+		downloader.UseRepositoryStorage(c.RepositoryStorage)
+	}
+
+	version := c.version()
+	if c.Name == "" {
+		downloader.SetOrgFilter(migration.OrgFilter{
+			Repositories: c.Repositories,
+			SkipArchived: c.SkipArchived,
+			SkipForks:    c.SkipForks,
+		})
 
-	if c.LogHTTP {
-		logger := log.New(log.Fields{"owner": c.Owner, "repo": c.Name})
-		setLogTransport(client, logger)
+		err = downloader.DownloadOrg(c.Owner, version)
+	} else {
+		err = downloader.DownloadRepository(c.Owner, c.Name, version)
+	}
+	if err != nil {
+		return err
 	}
 
-	downloader, err := migration.NewMigrationDownloader(client)
+	err = downloader.SetCurrent(version)
 	if err != nil {
 		return err
 	}
 
-	return downloader.DownloadRepository(c.Owner, c.Name, "v0")
+	if c.Cleanup {
+		return downloader.Cleanup(version)
+	}
+
+	return nil
 }
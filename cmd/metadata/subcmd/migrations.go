@@ -0,0 +1,131 @@
+package subcmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/migration"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+type MigrationsCommand struct {
+	cli.PlainCommand `name:"migrations" short-description:"manage the migrations of an organization" long-description:"Lists the migrations of an organization, deletes their archives and unlocks their repositories"`
+}
+
+// migrationsOptions are the options shared by the migrations subcommands
+type migrationsOptions struct {
+	clientOptions
+
+	Owner string `long:"owner" required:"true" description:"organization login"`
+}
+
+// newDownloader returns a migration downloader that is only used to manage
+// the migrations, it does not save any data
+func (o *migrationsOptions) newDownloader() (*migration.GitHubMigrationDownloader, func(), error) {
+	httpClient, done, err := o.newHTTPClient(log.New(log.Fields{"owner": o.Owner}))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d, err := migration.NewMigrationDownloader(httpClient, nil)
+	if err != nil {
+		done()
+		return nil, nil, err
+	}
+
+	return d, done, nil
+}
+
+type MigrationsListCommand struct {
+	cli.Command `name:"list" short-description:"list the migrations of an organization" long-description:"Lists the recent migrations of an organization with their state, age and repositories"`
+
+	migrationsOptions
+}
+
+func (c *MigrationsListCommand) Execute(args []string) error {
+	d, done, err := c.newDownloader()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	migrations, err := d.ListMigrations(c.Owner)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tAGE\tLOCKED\tREPOSITORIES")
+	for _, m := range migrations {
+		var age time.Duration
+		if created, err := time.Parse(time.RFC3339, m.GetCreatedAt()); err == nil {
+			age = time.Since(created).Truncate(time.Second)
+		}
+
+		names := make([]string, len(m.Repositories))
+		for i, r := range m.Repositories {
+			names[i] = r.GetName()
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%v\t%v\t%s\n",
+			m.GetID(), m.GetState(), age, m.GetLockRepositories(), strings.Join(names, ","))
+	}
+
+	return w.Flush()
+}
+
+type MigrationsDeleteArchiveCommand struct {
+	cli.Command `name:"delete-archive" short-description:"delete the archive of a migration" long-description:"Deletes the archive of a migration from GitHub. Archives are otherwise deleted automatically after seven days"`
+
+	migrationsOptions
+
+	MigrationID []int64 `long:"migration-id" required:"true" description:"migration ID, can be repeated"`
+}
+
+func (c *MigrationsDeleteArchiveCommand) Execute(args []string) error {
+	d, done, err := c.newDownloader()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	for _, id := range c.MigrationID {
+		err := d.DeleteArchive(c.Owner, id)
+		if err != nil {
+			return fmt.Errorf("could not delete the archive of migration %v: %v", id, err)
+		}
+
+		log.With(log.Fields{"owner": c.Owner, "migration-id": id}).Infof("migration archive deleted")
+	}
+
+	return nil
+}
+
+type MigrationsUnlockCommand struct {
+	cli.Command `name:"unlock" short-description:"unlock the repositories locked by a migration" long-description:"Unlocks the repositories locked by a migration started with --lock-repositories"`
+
+	migrationsOptions
+
+	MigrationID  int64    `long:"migration-id" required:"true" description:"migration ID"`
+	Repositories []string `long:"repository" description:"repository to unlock, can be repeated. By default all the repositories of the migration are unlocked"`
+}
+
+func (c *MigrationsUnlockCommand) Execute(args []string) error {
+	d, done, err := c.newDownloader()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	err = d.UnlockRepositories(c.Owner, c.MigrationID, c.Repositories)
+	if err != nil {
+		return err
+	}
+
+	log.With(log.Fields{"owner": c.Owner, "migration-id": c.MigrationID}).Infof("repositories unlocked")
+	return nil
+}
@@ -0,0 +1,38 @@
+package subcmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// tracingOptions contains the flags to export the OpenTelemetry spans
+type tracingOptions struct {
+	TraceOTLPEndpoint string `long:"trace-otlp-endpoint" description:"export the traces to this OTLP/HTTP collector, e.g. localhost:55681"`
+	TraceOTLPInsecure bool   `long:"trace-otlp-insecure" description:"do not use TLS to connect to the OTLP collector"`
+	TraceFile         string `long:"trace-file" description:"write the traces as JSON to the given file"`
+}
+
+// startTracing configures the span exporters. The returned function must be
+// called at the end of the run to flush the pending spans.
+func (o *tracingOptions) startTracing(logger log.Logger) (func(), error) {
+	shutdown, err := tracing.Setup(tracing.Options{
+		OTLPEndpoint: o.TraceOTLPEndpoint,
+		OTLPInsecure: o.TraceOTLPInsecure,
+		File:         o.TraceFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := shutdown(ctx); err != nil {
+			logger.Errorf(err, "could not export the traces")
+		}
+	}, nil
+}
@@ -1,10 +1,9 @@
 package subcmd
 
 import (
-	"context"
+	"fmt"
 
 	v3 "github.com/carlosms/metadata-retrieval-playground/v3"
-	"golang.org/x/oauth2"
 	"gopkg.in/src-d/go-cli.v0"
 	"gopkg.in/src-d/go-log.v1"
 )
@@ -12,29 +11,109 @@ import (
 type V3Command struct {
 	cli.Command `name:"v3" short-description:"" long-description:""`
 
-	LogHTTP bool `long:"log-http" description:"log http requests (debug level)"`
+	clientOptions
+	metricsOptions
+	tracingOptions
 
-	//DB    string `long:"db" description:"PostgreSQL URL connection string" required:"true"`
-	Token string `long:"token" short:"t" env:"SOURCED_GITHUB_TOKEN" description:"GitHub personal access token" required:"true"`
+	dbOptions
+
+	RepoComments bool `long:"repo-comments" description:"list the comments once for the whole repository, instead of once per issue and PR"`
+	Shallow      bool `long:"shallow" description:"save the issues and PRs from the list endpoints, without the fields only returned by the individual endpoints"`
+	Incremental  bool `long:"incremental" description:"download only the changes since the last incremental download, merged into the new version. Requires --db"`
+
+	SkipArchived bool   `long:"skip-archived" description:"when downloading an organization, skip the archived repositories"`
+	SkipForks    bool   `long:"skip-forks" description:"when downloading an organization, skip the forks"`
+	Visibility   string `long:"visibility" choice:"all" choice:"public" choice:"private" default:"all" description:"when downloading an organization, the visibility of the repositories"`
 
 	Owner string `long:"owner"  required:"true"`
-	Name  string `long:"name"  required:"true"`
+	Name  string `long:"name" description:"repository name, if it is empty all the repositories of the --owner organization are downloaded"`
 }
 
-func (c *V3Command) Execute(args []string) error {
-	client := oauth2.NewClient(context.TODO(), oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: c.Token},
-	))
+func (c *V3Command) Execute(args []string) (err error) {
+	logger := log.New(log.Fields{"owner": c.Owner, "repo": c.Name})
 
-	if c.LogHTTP {
-		logger := log.New(log.Fields{"owner": c.Owner, "repo": c.Name})
-		setLogTransport(client, logger)
+	if c.Incremental && c.DB == "" {
+		return fmt.Errorf("--incremental requires --db")
 	}
 
-	downloader, err := v3.NewStdoutDownloader(client)
+	if c.Incremental && c.Shallow {
+		return fmt.Errorf("--incremental cannot be used with --shallow")
+	}
+
+	finish, err := c.startMetrics("v3", logger)
 	if err != nil {
 		return err
 	}
+	defer func() { finish(err) }()
+
+	stopTracing, err := c.startTracing(logger)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	client, done, err := c.newHTTPClient(logger)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var downloader *v3.GitHubDownloader
+	if c.DB == "" {
+		log.Infof("using stdout to save the data")
+		downloader, err = v3.NewStdoutDownloader(client)
+		if err != nil {
+			return err
+		}
+	} else {
+		db, err := c.openDB()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		downloader, err = v3.NewDBDownloader(client, db)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.RepoComments {
+		downloader.UseRepositoryComments()
+	}
+
+	if c.Shallow {
+		downloader.UseShallowMode()
+	}
+
+	if c.Incremental {
+		downloader.UseIncrementalSync()
+	}
+
+	version := c.version()
+	if c.Name == "" {
+		downloader.SetOrgFilter(v3.OrgFilter{
+			SkipArchived: c.SkipArchived,
+			SkipForks:    c.SkipForks,
+			Visibility:   c.Visibility,
+		})
+
+		err = downloader.DownloadOrg(c.Owner, version)
+	} else {
+		err = downloader.DownloadRepository(c.Owner, c.Name, version)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = downloader.SetCurrent(version)
+	if err != nil {
+		return err
+	}
+
+	if c.Cleanup {
+		return downloader.Cleanup(version)
+	}
 
-	return downloader.DownloadRepository(c.Owner, c.Name, "v0")
+	return nil
 }
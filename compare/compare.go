@@ -0,0 +1,311 @@
+// Package compare finds the differences between the canonical metadata
+// downloaded with two strategies, e.g. v3 and v4, to validate that switching
+// from one to the other does not lose data.
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+)
+
+// Report has the differences between two snapshots, A and B, for each entity
+// type
+type Report struct {
+	A        string         `json:"a"`
+	B        string         `json:"b"`
+	Entities []EntityReport `json:"entities"`
+}
+
+// EntityReport has the differences of one entity type. The entities are
+// matched by key, e.g. owner/name#number for issues.
+type EntityReport struct {
+	Type    string `json:"type"`
+	A       int    `json:"a"`
+	B       int    `json:"b"`
+	Matched int    `json:"matched"`
+	// OnlyA are the keys of the entities missing in B, and OnlyB the ones
+	// missing in A
+	OnlyA []string `json:"only_a,omitempty"`
+	OnlyB []string `json:"only_b,omitempty"`
+	// Fields summarizes the differences by field
+	Fields      []FieldSummary `json:"fields,omitempty"`
+	Differences []Difference   `json:"differences,omitempty"`
+}
+
+// FieldSummary counts the matched entities where the field is only set in A,
+// only set in B, or set in both with different values
+type FieldSummary struct {
+	Field     string `json:"field"`
+	OnlyA     int    `json:"only_a"`
+	OnlyB     int    `json:"only_b"`
+	Different int    `json:"different"`
+}
+
+// Difference is a field with different values in the entity key of A and B.
+// An empty value means the field is not set.
+type Difference struct {
+	Key   string      `json:"key"`
+	Field string      `json:"field"`
+	A     interface{} `json:"a"`
+	B     interface{} `json:"b"`
+}
+
+// Equal returns true if there are no missing entities or field differences
+func (r *Report) Equal() bool {
+	for _, e := range r.Entities {
+		if len(e.OnlyA) > 0 || len(e.OnlyB) > 0 || len(e.Differences) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Compare matches the entities of the snapshots a and b, named nameA and
+// nameB in the report, and compares all their fields
+func Compare(nameA string, a *Snapshot, nameB string, b *Snapshot) *Report {
+	return &Report{
+		A: nameA,
+		B: nameB,
+		Entities: []EntityReport{
+			compareEntities("organization", a.Organizations, b.Organizations, func(v interface{}) string {
+				return v.(metadata.Organization).Login
+			}),
+			compareEntities("user", a.Users, b.Users, func(v interface{}) string {
+				return v.(metadata.User).Login
+			}),
+			compareEntities("repository", a.Repositories, b.Repositories, func(v interface{}) string {
+				r := v.(metadata.Repository)
+				return r.Owner + "/" + r.Name
+			}),
+			compareEntities("issue", a.Issues, b.Issues, func(v interface{}) string {
+				i := v.(metadata.Issue)
+				return issueKey(i.RepositoryOwner, i.RepositoryName, i.Number)
+			}),
+			compareEntities("pull_request", a.PullRequests, b.PullRequests, func(v interface{}) string {
+				pr := v.(metadata.PullRequest)
+				return issueKey(pr.RepositoryOwner, pr.RepositoryName, pr.Number)
+			}),
+			compareEntities("comment", a.Comments, b.Comments, func(v interface{}) string {
+				c := v.(metadata.Comment)
+				return fmt.Sprintf("%s/comment-%d", issueKey(c.RepositoryOwner, c.RepositoryName, c.Number), c.DatabaseID)
+			}),
+			compareEntities("review", a.Reviews, b.Reviews, func(v interface{}) string {
+				r := v.(metadata.Review)
+				return fmt.Sprintf("%s/review-%d", issueKey(r.RepositoryOwner, r.RepositoryName, r.PullRequestNumber), r.DatabaseID)
+			}),
+			compareEntities("review_comment", a.ReviewComments, b.ReviewComments, func(v interface{}) string {
+				c := v.(metadata.ReviewComment)
+				return fmt.Sprintf("%s/review-comment-%d", issueKey(c.RepositoryOwner, c.RepositoryName, c.PullRequestNumber), c.DatabaseID)
+			}),
+			compareEntities("review_thread", a.ReviewThreads, b.ReviewThreads, func(v interface{}) string {
+				t := v.(metadata.ReviewThread)
+				return fmt.Sprintf("%s/review-thread-%s", issueKey(t.RepositoryOwner, t.RepositoryName, t.PullRequestNumber), t.ID)
+			}),
+			compareEntities("event", a.Events, b.Events, func(v interface{}) string {
+				e := v.(metadata.Event)
+				return fmt.Sprintf("%s/event-%d", issueKey(e.RepositoryOwner, e.RepositoryName, e.Number), e.DatabaseID)
+			}),
+			compareEntities("reaction", a.Reactions, b.Reactions, func(v interface{}) string {
+				r := v.(metadata.Reaction)
+				return fmt.Sprintf("%s/%s-%d/%s", issueKey(r.RepositoryOwner, r.RepositoryName, r.Number), r.SubjectType, r.SubjectID, r.Content)
+			}),
+		},
+	}
+}
+
+func issueKey(owner, name string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, name, number)
+}
+
+// compareEntities compares the slices a and b of an entity type, key returns
+// the key of each element
+func compareEntities(typ string, a, b interface{}, key func(interface{}) string) EntityReport {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	r := EntityReport{Type: typ, A: va.Len(), B: vb.Len()}
+
+	byKey := make(map[string]reflect.Value)
+	for i := 0; i < vb.Len(); i++ {
+		byKey[key(vb.Index(i).Interface())] = vb.Index(i)
+	}
+
+	seen := make(map[string]bool)
+	fields := make(map[string]*FieldSummary)
+	for i := 0; i < va.Len(); i++ {
+		k := key(va.Index(i).Interface())
+		seen[k] = true
+
+		eb, ok := byKey[k]
+		if !ok {
+			r.OnlyA = append(r.OnlyA, k)
+			continue
+		}
+		r.Matched++
+
+		for _, d := range compareFields(k, va.Index(i), eb) {
+			s, ok := fields[d.Field]
+			if !ok {
+				s = &FieldSummary{Field: d.Field}
+				fields[d.Field] = s
+			}
+
+			switch {
+			case d.B == nil:
+				s.OnlyA++
+			case d.A == nil:
+				s.OnlyB++
+			default:
+				s.Different++
+			}
+
+			r.Differences = append(r.Differences, d)
+		}
+	}
+
+	for k := range byKey {
+		if !seen[k] {
+			r.OnlyB = append(r.OnlyB, k)
+		}
+	}
+
+	sort.Strings(r.OnlyA)
+	sort.Strings(r.OnlyB)
+
+	for _, s := range fields {
+		r.Fields = append(r.Fields, *s)
+	}
+	sort.Slice(r.Fields, func(i, j int) bool { return r.Fields[i].Field < r.Fields[j].Field })
+
+	return r
+}
+
+// compareFields returns the differences of the struct values a and b. The
+// fields of embedded structs are compared as fields of the outer struct.
+// Unset fields are nil in the differences.
+func compareFields(key string, a, b reflect.Value) []Difference {
+	var diffs []Difference
+
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			diffs = append(diffs, compareFields(key, a.Field(i), b.Field(i))...)
+			continue
+		}
+
+		fa, fb := value(a.Field(i)), value(b.Field(i))
+		if equal(fa, fb) {
+			continue
+		}
+
+		diffs = append(diffs, Difference{Key: key, Field: f.Name, A: fa, B: fb})
+	}
+
+	return diffs
+}
+
+// value returns the field value, or nil if it is not set: the zero value, a
+// nil pointer or an empty slice. Pointers are dereferenced.
+func value(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return value(v.Elem())
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		return v.Interface()
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC()
+	}
+
+	if reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface()) {
+		return nil
+	}
+
+	return v.Interface()
+}
+
+func equal(a, b interface{}) bool {
+	ta, okA := a.(time.Time)
+	tb, okB := b.(time.Time)
+	if okA && okB {
+		return ta.Equal(tb)
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// Print writes the report in a human readable format. With verbose all the
+// differences are listed, otherwise only the summary of each field.
+func (r *Report) Print(w io.Writer, verbose bool) {
+	fmt.Fprintf(w, "A: %s\nB: %s\n", r.A, r.B)
+
+	for _, e := range r.Entities {
+		if e.A == 0 && e.B == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "\n%s: A=%d B=%d matched=%d\n", e.Type, e.A, e.B, e.Matched)
+
+		for _, k := range e.OnlyA {
+			fmt.Fprintf(w, "  missing in B: %s\n", k)
+		}
+		for _, k := range e.OnlyB {
+			fmt.Fprintf(w, "  missing in A: %s\n", k)
+		}
+
+		for _, f := range e.Fields {
+			fmt.Fprintf(w, "  field %s: only in A=%d only in B=%d different=%d\n", f.Field, f.OnlyA, f.OnlyB, f.Different)
+		}
+
+		if !verbose {
+			continue
+		}
+
+		for _, d := range e.Differences {
+			fmt.Fprintf(w, "    %s %s: A=%s B=%s\n", d.Key, d.Field, format(d.A), format(d.B))
+		}
+	}
+}
+
+// PrintJSON writes the report as indented JSON
+func (r *Report) PrintJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func format(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", trim(s))
+	}
+
+	return fmt.Sprint(v)
+}
+
+func trim(s string) string {
+	if len(s) > 40 {
+		return s[0:39] + "..."
+	}
+
+	return s
+}
@@ -0,0 +1,144 @@
+package compare
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/carlosms/metadata-retrieval-playground/internal/fakegithub"
+	v3 "github.com/carlosms/metadata-retrieval-playground/v3"
+	v4 "github.com/carlosms/metadata-retrieval-playground/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	require := require.New(t)
+
+	created := time.Date(2019, 6, 19, 10, 0, 0, 0, time.UTC)
+	closed := created.Add(time.Hour)
+
+	a := &Snapshot{
+		Issues: []metadata.Issue{
+			{RepositoryOwner: "o", RepositoryName: "r", Number: 1, Title: "one", CreatedAt: created},
+			{RepositoryOwner: "o", RepositoryName: "r", Number: 2, Title: "two", Labels: []string{"bug"}},
+		},
+		Comments: []metadata.Comment{
+			{DatabaseID: 10, RepositoryOwner: "o", RepositoryName: "r", Number: 1},
+		},
+	}
+
+	b := &Snapshot{
+		Issues: []metadata.Issue{
+			{RepositoryOwner: "o", RepositoryName: "r", Number: 1, Title: "one", CreatedAt: created.In(time.FixedZone("CEST", 2*3600))},
+			{RepositoryOwner: "o", RepositoryName: "r", Number: 2, Title: "2", DatabaseID: 20, ClosedAt: &closed},
+			{RepositoryOwner: "o", RepositoryName: "r", Number: 3},
+		},
+	}
+
+	r := Compare("a", a, "b", b)
+	require.False(r.Equal())
+
+	entities := make(map[string]EntityReport)
+	for _, e := range r.Entities {
+		entities[e.Type] = e
+	}
+
+	issues := entities["issue"]
+	require.Equal(2, issues.A)
+	require.Equal(3, issues.B)
+	require.Equal(2, issues.Matched)
+	require.Empty(issues.OnlyA)
+	require.Equal([]string{"o/r#3"}, issues.OnlyB)
+	require.Equal([]FieldSummary{
+		{Field: "ClosedAt", OnlyB: 1},
+		{Field: "DatabaseID", OnlyB: 1},
+		{Field: "Labels", OnlyA: 1},
+		{Field: "Title", Different: 1},
+	}, issues.Fields)
+	require.Len(issues.Differences, 4)
+
+	comments := entities["comment"]
+	require.Equal([]string{"o/r#1/comment-10"}, comments.OnlyA)
+	require.Empty(comments.OnlyB)
+
+	require.True(Compare("a", a, "a", a).Equal())
+}
+
+// rewriteTransport sends the requests for api.github.com to the fake server
+type rewriteTransport struct {
+	host string
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := *req
+	u := *req.URL
+	u.Scheme = "http"
+	u.Host = t.host
+	r.URL = &u
+
+	return http.DefaultTransport.RoundTrip(&r)
+}
+
+func TestCompareV3V4(t *testing.T) {
+	require := require.New(t)
+
+	repo := fakegithub.NewRepository(fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       2,
+		CommentsPerIssue:             2,
+		PullRequests:                 2,
+		CommentsPerPullRequest:       1,
+		ReviewsPerPullRequest:        1,
+		CommentsPerPullRequestReview: 2,
+	})
+	// a deleted account and an app
+	repo.Issues[0].Author = ""
+	repo.Issues[1].Comments[0].Author = "dependabot[bot]"
+
+	srv := fakegithub.NewServer(repo)
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(err)
+
+	a := &Snapshot{}
+	d3, err := v3.NewMetadataDownloader(&http.Client{Transport: &rewriteTransport{host: u.Host}}, a)
+	require.NoError(err)
+	require.NoError(d3.DownloadRepository("org", "repo", "v0"))
+
+	b := &Snapshot{}
+	d4, err := v4.NewMetadataDownloader(&http.Client{Transport: &rewriteTransport{host: u.Host}}, b)
+	require.NoError(err)
+	require.NoError(d4.DownloadRepository("org", "repo", "v0"))
+
+	r := Compare("v3", a, "v4", b)
+	for _, e := range r.Entities {
+		// v4 also saves the accounts of the actors, v3 only downloads the
+		// organization with DownloadOrg
+		if e.Type == "user" || e.Type == "organization" {
+			require.Zero(e.A, e.Type)
+			continue
+		}
+
+		// the REST API does not have review threads, there is one for each
+		// position of the review comments of the 2 PRs
+		if e.Type == "review_thread" {
+			require.Zero(e.A)
+			require.Equal(4, e.B)
+			continue
+		}
+
+		require.Empty(e.OnlyA, e.Type)
+		require.Empty(e.OnlyB, e.Type)
+		require.Equal(e.A, e.Matched, e.Type)
+
+		// the fields of v3 that the v4 downloader does not query
+		for _, f := range e.Fields {
+			require.Zero(f.OnlyB, "%s %s", e.Type, f.Field)
+			require.Zero(f.Different, "%s %s", e.Type, f.Field)
+		}
+	}
+}
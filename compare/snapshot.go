@@ -0,0 +1,103 @@
+package compare
+
+import (
+	"context"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+)
+
+// Snapshot is a metadata.Storer that keeps all the saved entities in memory,
+// to compare the results of two downloads
+type Snapshot struct {
+	Organizations  []metadata.Organization
+	Users          []metadata.User
+	Repositories   []metadata.Repository
+	Issues         []metadata.Issue
+	PullRequests   []metadata.PullRequest
+	Comments       []metadata.Comment
+	Reviews        []metadata.Review
+	ReviewComments []metadata.ReviewComment
+	ReviewThreads  []metadata.ReviewThread
+	Events         []metadata.Event
+	Reactions      []metadata.Reaction
+}
+
+var _ metadata.Storer = &Snapshot{}
+
+func (s *Snapshot) SaveOrganization(ctx context.Context, org *metadata.Organization) error {
+	s.Organizations = append(s.Organizations, *org)
+	return nil
+}
+
+func (s *Snapshot) SaveUser(ctx context.Context, user *metadata.User) error {
+	s.Users = append(s.Users, *user)
+	return nil
+}
+
+func (s *Snapshot) SaveRepository(ctx context.Context, repository *metadata.Repository) error {
+	s.Repositories = append(s.Repositories, *repository)
+	return nil
+}
+
+func (s *Snapshot) SaveIssue(ctx context.Context, issue *metadata.Issue) error {
+	s.Issues = append(s.Issues, *issue)
+	return nil
+}
+
+func (s *Snapshot) SavePullRequest(ctx context.Context, pr *metadata.PullRequest) error {
+	s.PullRequests = append(s.PullRequests, *pr)
+	return nil
+}
+
+func (s *Snapshot) SaveComment(ctx context.Context, comment *metadata.Comment) error {
+	s.Comments = append(s.Comments, *comment)
+	return nil
+}
+
+func (s *Snapshot) SaveReview(ctx context.Context, review *metadata.Review) error {
+	s.Reviews = append(s.Reviews, *review)
+	return nil
+}
+
+func (s *Snapshot) SaveReviewComment(ctx context.Context, comment *metadata.ReviewComment) error {
+	s.ReviewComments = append(s.ReviewComments, *comment)
+	return nil
+}
+
+func (s *Snapshot) SaveReviewThread(ctx context.Context, thread *metadata.ReviewThread) error {
+	s.ReviewThreads = append(s.ReviewThreads, *thread)
+	return nil
+}
+
+func (s *Snapshot) SaveEvent(ctx context.Context, event *metadata.Event) error {
+	s.Events = append(s.Events, *event)
+	return nil
+}
+
+func (s *Snapshot) SaveReaction(ctx context.Context, reaction *metadata.Reaction) error {
+	s.Reactions = append(s.Reactions, *reaction)
+	return nil
+}
+
+func (s *Snapshot) Begin() error {
+	return nil
+}
+
+func (s *Snapshot) Commit() error {
+	return nil
+}
+
+func (s *Snapshot) Rollback() error {
+	return nil
+}
+
+func (s *Snapshot) Version(v string) {
+}
+
+func (s *Snapshot) SetActiveVersion(v string) error {
+	return nil
+}
+
+func (s *Snapshot) Cleanup(currentVersion string) error {
+	return nil
+}
@@ -6,23 +6,64 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/carlosms/metadata-retrieval-playground/internal/progress"
 	"github.com/gregjones/httpcache"
 	"github.com/gregjones/httpcache/diskcache"
 	"github.com/src-d/ghsync/utils"
 )
 
+// Options configure the transports added by NewClientWithOptions
+type Options struct {
+	// RetryPolicy is the policy of the retries, DefaultRetryPolicy if nil
+	RetryPolicy *RetryPolicy
+	// NoCache disables the disk cache. It must be set to record or replay a
+	// cassette: the requests answered by the cache would be missing from the
+	// cassette, and the replayed responses would be cached for the next runs.
+	NoCache bool
+}
+
+// configured is the outermost transport set by NewClientWithOptions
+type configured struct {
+	http.RoundTripper
+}
+
+// NewClient wraps the transport of httpClient as NewClientWithOptions does,
+// with the default Options. A client that is already wrapped is returned as
+// is, so the commands can set their own Options before passing the client to
+// a downloader.
 func NewClient(httpClient *http.Client) (*http.Client, error) {
-	dirPath := filepath.Join(os.TempDir(), "ghsync")
-	err := os.MkdirAll(dirPath, os.ModePerm)
-	if err != nil {
-		return nil, fmt.Errorf("error while creating directory %s: %v", dirPath, err)
+	if _, ok := httpClient.Transport.(*configured); ok {
+		return httpClient, nil
 	}
 
-	t := httpcache.NewTransport(diskcache.New(dirPath))
-	t.Transport = &RemoveHeaderTransport{
+	return NewClientWithOptions(httpClient, Options{})
+}
+
+// NewClientWithOptions wraps the transport of httpClient with a disk cache,
+// the rate limit handling, the retries, a span for each request and the
+// progress updates. The retries are done on top of the cache, and of any
+// Recorder in the given transport, so the failed attempts are recorded and
+// replayed too.
+func NewClientWithOptions(httpClient *http.Client, opts Options) (*http.Client, error) {
+	var t http.RoundTripper = &RemoveHeaderTransport{
 		T: utils.NewRateLimitTransport(httpClient.Transport),
 	}
-	httpClient.Transport = &RetryTransport{T: t}
+
+	if !opts.NoCache {
+		dirPath := filepath.Join(os.TempDir(), "ghsync")
+		err := os.MkdirAll(dirPath, os.ModePerm)
+		if err != nil {
+			return nil, fmt.Errorf("error while creating directory %s: %v", dirPath, err)
+		}
+
+		cache := httpcache.NewTransport(diskcache.New(dirPath))
+		cache.Transport = t
+		t = cache
+	}
+
+	t = NewRetryTransport(t, opts.RetryPolicy)
+
+	httpClient.Transport = &configured{&TracingTransport{T: &progress.Transport{T: t}}}
 
 	return httpClient, nil
 }
@@ -37,18 +78,3 @@ func (t *RemoveHeaderTransport) RoundTrip(req *http.Request) (*http.Response, er
 	req.Header.Del("X-Ratelimit-Reset")
 	return t.T.RoundTrip(req)
 }
-
-type RetryTransport struct {
-	T http.RoundTripper
-}
-
-func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	var r *http.Response
-	var err error
-	utils.Retry(func() error {
-		r, err = t.T.RoundTrip(req)
-		return err
-	})
-
-	return r, err
-}
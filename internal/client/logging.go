@@ -0,0 +1,259 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveHeaders are always redacted from the logs
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// sensitiveParams are the URL query parameters redacted from the logs
+var sensitiveParams = []string{"access_token", "client_id", "client_secret", "token"}
+
+// HTTPLogEntry is the information logged for each request
+type HTTPLogEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+	// RequestHeader contains the request headers, with the sensitive ones
+	// redacted
+	RequestHeader http.Header  `json:"request_header,omitempty"`
+	GraphQL       *GraphQLInfo `json:"graphql,omitempty"`
+
+	Status     int     `json:"status,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+	// RateLimit contains the X-RateLimit-* and Retry-After response headers
+	RateLimit map[string]string `json:"rate_limit,omitempty"`
+
+	RequestBody  string `json:"request_body,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+}
+
+// GraphQLInfo describes a GraphQL request
+type GraphQLInfo struct {
+	// Operation is the operation type and name, if any
+	Operation string `json:"operation"`
+	// Fields are the top level fields of the query
+	Fields    []string        `json:"fields"`
+	Variables json.RawMessage `json:"variables,omitempty"`
+}
+
+// LogTransport logs every request and its response. Each entry is written as
+// a JSON line to Out, and as a debug message with fields to Logger, any of
+// them can be nil. The Authorization header, credentials in the URL and the
+// values in Secrets are redacted.
+type LogTransport struct {
+	T      http.RoundTripper
+	Out    io.Writer
+	Logger log.Logger
+	// Body includes the request and response bodies in the entries
+	Body bool
+	// Secrets are values, like tokens, replaced anywhere they appear
+	Secrets []string
+
+	m sync.Mutex
+}
+
+func (t *LogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	e := &HTTPLogEntry{
+		Time:          time.Now(),
+		Method:        req.Method,
+		URL:           t.redactURL(req),
+		RequestHeader: t.redactHeader(req.Header),
+		GraphQL:       t.graphQLInfo(reqBody),
+	}
+
+	resp, err := t.T.RoundTrip(req)
+	e.DurationMs = float64(time.Since(e.Time)) / float64(time.Millisecond)
+
+	var respBody []byte
+	if err != nil {
+		e.Error = t.redact(err.Error())
+	} else {
+		e.Status = resp.StatusCode
+		e.RateLimit = rateLimitHeaders(resp.Header)
+
+		if t.Body {
+			respBody, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+		}
+	}
+
+	if t.Body {
+		e.RequestBody = t.redact(string(reqBody))
+		e.ResponseBody = t.redact(string(respBody))
+	}
+
+	t.log(e)
+
+	return resp, err
+}
+
+func (t *LogTransport) log(e *HTTPLogEntry) {
+	if t.Out != nil {
+		b, err := json.Marshal(e)
+		if err != nil {
+			log.Errorf(err, "could not encode the HTTP log entry")
+			return
+		}
+
+		t.m.Lock()
+		t.Out.Write(append(b, '\n'))
+		t.m.Unlock()
+	}
+
+	if t.Logger != nil {
+		fields := log.Fields{
+			"method":  e.Method,
+			"url":     e.URL,
+			"code":    e.Status,
+			"elapsed": time.Duration(e.DurationMs * float64(time.Millisecond)),
+		}
+		if e.GraphQL != nil {
+			fields["graphql"] = strings.Join(e.GraphQL.Fields, ",")
+			fields["variables"] = string(e.GraphQL.Variables)
+		}
+		if e.Error != "" {
+			fields["error"] = e.Error
+		}
+		if e.RateLimit != nil {
+			fields["rate_remaining"] = e.RateLimit["X-Ratelimit-Remaining"]
+		}
+		if t.Body {
+			fields["body"] = e.ResponseBody
+		}
+
+		t.Logger.With(fields).Debugf("HTTP request")
+	}
+}
+
+func (t *LogTransport) redact(s string) string {
+	for _, secret := range t.Secrets {
+		if secret != "" {
+			s = strings.Replace(s, secret, redacted, -1)
+		}
+	}
+
+	return s
+}
+
+func (t *LogTransport) redactURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+
+	q := u.Query()
+	changed := false
+	for _, p := range sensitiveParams {
+		if q.Get(p) != "" {
+			q.Set(p, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = q.Encode()
+	}
+
+	return t.redact(u.String())
+}
+
+func (t *LogTransport) redactHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+
+	res := make(http.Header, len(h))
+	for k, v := range h {
+		values := make([]string, len(v))
+		for i, s := range v {
+			values[i] = t.redact(s)
+		}
+		res[k] = values
+	}
+
+	for _, k := range sensitiveHeaders {
+		if res.Get(k) != "" {
+			res.Set(k, redacted)
+		}
+	}
+
+	return res
+}
+
+func (t *LogTransport) graphQLInfo(body []byte) *GraphQLInfo {
+	var gql struct {
+		Query     string          `json:"query"`
+		Variables json.RawMessage `json:"variables"`
+	}
+	if len(body) == 0 || json.Unmarshal(body, &gql) != nil || gql.Query == "" {
+		return nil
+	}
+
+	info := &GraphQLInfo{
+		Operation: operation(gql.Query),
+		Fields:    topLevelFields(gql.Query),
+	}
+	if len(gql.Variables) > 0 && string(gql.Variables) != "null" {
+		info.Variables = json.RawMessage(t.redact(string(gql.Variables)))
+	}
+
+	return info
+}
+
+// operation returns the operation type and name of a GraphQL query, like
+// "query" or "mutation addComment"
+func operation(query string) string {
+	query = strings.TrimSpace(query)
+	if strings.HasPrefix(query, "{") {
+		return "query"
+	}
+
+	end := strings.IndexAny(query, "({")
+	if end < 0 {
+		end = len(query)
+	}
+
+	return strings.Join(strings.Fields(query[:end]), " ")
+}
+
+func rateLimitHeaders(h http.Header) map[string]string {
+	var res map[string]string
+	for k, v := range h {
+		if strings.HasPrefix(k, "X-Ratelimit-") || k == "Retry-After" {
+			if res == nil {
+				res = make(map[string]string)
+			}
+			res[k] = strings.Join(v, ",")
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogTransport(t *testing.T) {
+	require := require.New(t)
+
+	const token = "0123456789abcdef"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, `{"echo":%q}`, b)
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	c := &http.Client{Transport: &LogTransport{
+		T:       http.DefaultTransport,
+		Out:     &out,
+		Body:    true,
+		Secrets: []string{token},
+	}}
+
+	body := `{"query":"query($owner:String!){repository(owner:$owner){id},rateLimit{cost}}","variables":{"owner":"` + token + `"}}`
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/graphql?access_token="+token, strings.NewReader(body))
+	require.NoError(err)
+	req.Header.Set("Authorization", "bearer "+token)
+
+	resp, err := c.Do(req)
+	require.NoError(err)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	resp.Body.Close()
+
+	// the response is not modified
+	require.Contains(string(b), token)
+
+	require.NotContains(out.String(), token)
+
+	var e HTTPLogEntry
+	require.NoError(json.Unmarshal(out.Bytes(), &e))
+
+	require.Equal(http.MethodPost, e.Method)
+	require.Equal(srv.URL+"/graphql?access_token=REDACTED", e.URL)
+	require.Equal("REDACTED", e.RequestHeader.Get("Authorization"))
+	require.Equal(http.StatusOK, e.Status)
+	require.Equal("4999", e.RateLimit["X-Ratelimit-Remaining"])
+
+	require.NotNil(e.GraphQL)
+	require.Equal("query", e.GraphQL.Operation)
+	require.Equal([]string{"rateLimit", "repository"}, e.GraphQL.Fields)
+	require.JSONEq(`{"owner":"REDACTED"}`, string(e.GraphQL.Variables))
+
+	require.Contains(e.RequestBody, "REDACTED")
+	require.Contains(e.ResponseBody, "REDACTED")
+}
+
+func TestOperation(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("query", operation("{viewer{login}}"))
+	require.Equal("query", operation("query($a:Int!){viewer{login}}"))
+	require.Equal("query Viewer", operation("query Viewer { viewer { login } }"))
+	require.Equal("mutation addComment", operation("mutation addComment($input:AddCommentInput!){x}"))
+}
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Cassette is a list of HTTP interactions, stored on disk as JSON
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response it got
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	// Encoding is "base64" for bodies that are not valid UTF-8
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// LoadCassette reads a cassette from a JSON file
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("could not parse cassette %s: %v", path, err)
+	}
+
+	return &c, nil
+}
+
+// Save writes the cassette as JSON to the given path
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Recorder is an http.RoundTripper that either records the interactions made
+// through the wrapped transport T, or replays them from a Cassette without
+// touching the network.
+//
+// Requests are matched by method and URL. GraphQL requests are also matched
+// by the top level fields of the query and its variables, so adding fields
+// to a query does not invalidate a recorded cassette. Identical requests are
+// replayed in the order they were recorded, repeating the last one once the
+// recorded ones are exhausted.
+type Recorder struct {
+	T http.RoundTripper
+
+	replay   bool
+	cassette *Cassette
+
+	m sync.Mutex
+	// replayed keeps the number of times each key has been replayed
+	replayed map[string]int
+}
+
+// NewRecorder returns a Recorder that records the interactions made through t.
+// Call Save to write them to disk.
+func NewRecorder(t http.RoundTripper) *Recorder {
+	return &Recorder{T: t, cassette: &Cassette{}}
+}
+
+// NewReplayer returns a Recorder that replays the interactions in the given
+// cassette
+func NewReplayer(cassette *Cassette) *Recorder {
+	return &Recorder{
+		replay:   true,
+		cassette: cassette,
+		replayed: make(map[string]int),
+	}
+}
+
+// Save writes the recorded interactions to path
+func (r *Recorder) Save(path string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.cassette.Save(path)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.replay {
+		return r.replayRoundTrip(req, body)
+	}
+
+	return r.recordRoundTrip(req, body)
+}
+
+func (r *Recorder) recordRoundTrip(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.T.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	recorded := RecordedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+	if utf8.Valid(b) {
+		recorded.Body = string(b)
+	} else {
+		recorded.Body = base64.StdEncoding.EncodeToString(b)
+		recorded.Encoding = "base64"
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   string(body),
+		},
+		Response: recorded,
+	})
+
+	return resp, nil
+}
+
+func (r *Recorder) replayRoundTrip(req *http.Request, body []byte) (*http.Response, error) {
+	key := interactionKey(req.Method, req.URL.String(), body)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var matches []*Interaction
+	for _, i := range r.cassette.Interactions {
+		if interactionKey(i.Request.Method, i.Request.URL, []byte(i.Request.Body)) == key {
+			matches = append(matches, i)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no recorded interaction for %s %s %s", req.Method, req.URL, body)
+	}
+
+	n := r.replayed[key]
+	r.replayed[key] = n + 1
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+
+	recorded := matches[n].Response
+
+	b := []byte(recorded.Body)
+	if recorded.Encoding == "base64" {
+		var err error
+		b, err = base64.StdEncoding.DecodeString(recorded.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	header := make(http.Header)
+	for k, v := range recorded.Header {
+		header[k] = v
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
+
+// interactionKey returns the string used to match a request against the
+// recorded ones
+func interactionKey(method, url string, body []byte) string {
+	key := method + " " + url
+
+	var gql struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if len(body) == 0 || json.Unmarshal(body, &gql) != nil || gql.Query == "" {
+		return key
+	}
+
+	// encoding/json sorts the map keys
+	vars, _ := json.Marshal(gql.Variables)
+
+	return fmt.Sprintf("%s %v %s", key, topLevelFields(gql.Query), vars)
+}
+
+// topLevelFields returns the sorted names of the fields in the outermost
+// selection set of a GraphQL query
+func topLevelFields(query string) []string {
+	var fields []string
+
+	depth, parens := 0, 0
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			fields = append(fields, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, c := range query {
+		switch {
+		case c == '(':
+			parens++
+		case c == ')':
+			parens--
+		case parens > 0:
+		case c == '{':
+			flush()
+			depth++
+		case c == '}':
+			flush()
+			depth--
+		case depth == 1 && (c == '_' || c == ':' || c == '.' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')):
+			current.WriteRune(c)
+		case depth == 1:
+			flush()
+		}
+	}
+
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderRecordAndReplay(t *testing.T) {
+	require := require.New(t)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Call", fmt.Sprint(calls))
+		if r.Method == http.MethodPost {
+			b, _ := ioutil.ReadAll(r.Body)
+			fmt.Fprintf(w, "post %d %s", calls, b)
+			return
+		}
+		fmt.Fprintf(w, "get %d %s", calls, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	recorder := NewRecorder(http.DefaultTransport)
+	c := &http.Client{Transport: recorder}
+
+	get := func(c *http.Client, path string) string {
+		resp, err := c.Get(srv.URL + path)
+		require.NoError(err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(err)
+		return string(b)
+	}
+
+	post := func(c *http.Client, body string) string {
+		resp, err := c.Post(srv.URL+"/graphql", "application/json", strings.NewReader(body))
+		require.NoError(err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(err)
+		return string(b)
+	}
+
+	q1 := `{"query":"query($id:Int!){issue(number:$id){title}}","variables":{"id":1}}`
+	q2 := `{"query":"query($id:Int!){issue(number:$id){title}}","variables":{"id":2}}`
+
+	require.Equal("get 1 /a", get(c, "/a"))
+	require.Equal("get 2 /a", get(c, "/a"))
+	require.Equal("post 3 "+q1, post(c, q1))
+	require.Equal("post 4 "+q2, post(c, q2))
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(recorder.Save(path))
+
+	cassette, err := LoadCassette(path)
+	require.NoError(err)
+	require.Len(cassette.Interactions, 4)
+
+	c = &http.Client{Transport: NewReplayer(cassette)}
+
+	// repeated requests are replayed in order, and the last one is repeated
+	require.Equal("get 1 /a", get(c, "/a"))
+	require.Equal("get 2 /a", get(c, "/a"))
+	require.Equal("get 2 /a", get(c, "/a"))
+
+	// GraphQL requests are matched by variables, even if the selected fields change
+	require.Equal("post 4 "+q2, post(c, `{"query":"query($id:Int!){issue(number:$id){title,body}}","variables":{"id":2}}`))
+	require.Equal("post 3 "+q1, post(c, q1))
+
+	_, err = c.Get(srv.URL + "/b")
+	require.Error(err)
+
+	require.Equal(4, calls)
+}
+
+func TestTopLevelFields(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]string{"rateLimit"}, topLevelFields(`{rateLimit{remaining}}`))
+	require.Equal([]string{"rateLimit", "repository"}, topLevelFields(
+		`query($name:String!$owner:String!){repository(owner: $owner, name: $name){issues(first:10){nodes{title}}},rateLimit{cost}}`))
+	require.Equal([]string{"alias:node"}, topLevelFields(`query($id:ID!){alias:node(id:$id){... on Issue{title}}}`))
+}
+
+func TestRecorderWithoutCache(t *testing.T) {
+	require := require.New(t)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", `"etag"`)
+		fmt.Fprintf(w, "get %s", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	recorder := NewRecorder(http.DefaultTransport)
+	c, err := NewClientWithOptions(&http.Client{Transport: recorder}, Options{NoCache: true})
+	require.NoError(err)
+
+	// the downloaders do not wrap a client twice
+	transport := c.Transport
+	c, err = NewClient(c)
+	require.NoError(err)
+	require.Equal(transport, c.Transport)
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(srv.URL + "/cached")
+		require.NoError(err)
+		resp.Body.Close()
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(recorder.Save(path))
+
+	cassette, err := LoadCassette(path)
+	require.NoError(err)
+	require.Len(cassette.Interactions, 2)
+	require.Equal(2, calls)
+}
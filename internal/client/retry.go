@@ -0,0 +1,333 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// RetryPolicy decides how many times a failed request is retried, and how
+// long to wait between attempts. The budget is shared by all the requests
+// made with the same policy, so a run against a failing API gives up instead
+// of retrying every single request MaxRetries times.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries for a single request
+	MaxRetries int
+	// MinBackoff is the wait before the first retry, it doubles on each
+	// attempt up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Budget is the total number of retries allowed for all the requests
+	Budget int
+
+	m    sync.Mutex
+	used int
+}
+
+// DefaultRetryPolicy returns a new RetryPolicy with the default values
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries: 5,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+		Budget:     100,
+	}
+}
+
+// Used returns the number of retries done so far
+func (p *RetryPolicy) Used() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return p.used
+}
+
+// take consumes one retry from the budget, returns false if it is exhausted
+func (p *RetryPolicy) take() bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if p.used >= p.Budget {
+		return false
+	}
+
+	p.used++
+	return true
+}
+
+// backoff returns the wait before the given retry (starting at 0), using
+// exponential backoff with equal jitter
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := p.MinBackoff
+	for i := 0; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// RetryTransport retries the requests that failed with an error considered
+// temporary:
+//   - network errors
+//   - HTTP 5xx responses, except 501 Not Implemented
+//   - abuse detection 403 responses, waiting as requested by Retry-After
+//   - GraphQL responses with errors caused by timeouts or server failures,
+//     GitHub returns them with a 200 status for big queries
+//
+// Primary rate limit responses are not retried here, they are handled by the
+// rate limit transport set by NewClient.
+type RetryTransport struct {
+	T      http.RoundTripper
+	Policy *RetryPolicy
+}
+
+// NewRetryTransport returns a RetryTransport for the given policy, if it is
+// nil DefaultRetryPolicy is used
+func NewRetryTransport(t http.RoundTripper, p *RetryPolicy) *RetryTransport {
+	if p == nil {
+		p = DefaultRetryPolicy()
+	}
+
+	return &RetryTransport{T: t, Policy: p}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	getBody, err := bodyGetter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for retry := 0; ; retry++ {
+		r := req
+		if getBody != nil {
+			r = new(http.Request)
+			*r = *req
+			if r.Body, err = getBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.T.RoundTrip(r)
+
+		var body []byte
+		if err == nil && isGraphQL(req, resp) {
+			body, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				resp = nil
+			} else {
+				resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+		}
+
+		kind, wait, reason := shouldRetry(req, resp, body, err)
+		if kind == "" {
+			return resp, err
+		}
+
+		logger := log.With(log.Fields{"url": req.URL.String(), "reason": reason, "retry": retry + 1})
+
+		if retry >= t.Policy.MaxRetries {
+			logger.Warningf("giving up after %d retries", retry)
+			return resp, err
+		}
+
+		if !t.Policy.take() {
+			logger.Warningf("retry budget of %d exhausted, giving up", t.Policy.Budget)
+			return resp, err
+		}
+
+		metrics.Retries.WithLabelValues(kind).Inc()
+		trace.SpanFromContext(req.Context()).AddEvent("retry", trace.WithAttributes(
+			attribute.String("reason", reason),
+			attribute.Int("retry", retry+1),
+		))
+
+		if wait == 0 {
+			wait = t.Policy.backoff(retry)
+		}
+
+		logger.Warningf("retrying in %v", wait)
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// bodyGetter returns a function to get a fresh copy of the request body for
+// each attempt, or nil if the request has no body
+func bodyGetter(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		return req.GetBody, nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}, nil
+}
+
+func isGraphQL(req *http.Request, resp *http.Response) bool {
+	return req.Method == http.MethodPost &&
+		strings.HasSuffix(req.URL.Path, "/graphql") &&
+		resp.StatusCode == http.StatusOK
+}
+
+// Kinds of failures retried by RetryTransport
+const (
+	retryNetwork     = "network"
+	retryServerError = "server_error"
+	retryAbuse       = "abuse"
+	retryGraphQL     = "graphql"
+)
+
+// shouldRetry classifies the result of a request. It returns the kind of
+// failure, or an empty string if the request should not be retried, the wait
+// requested by the server if any, and a description of the failure for the
+// logs.
+func shouldRetry(req *http.Request, resp *http.Response, body []byte, err error) (string, time.Duration, string) {
+	if err != nil {
+		if req.Context().Err() != nil || !isTemporaryError(err) {
+			return "", 0, ""
+		}
+
+		return retryNetwork, 0, err.Error()
+	}
+
+	switch {
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return retryServerError, retryAfter(resp), resp.Status
+	case resp.StatusCode == http.StatusForbidden:
+		if isAbuseResponse(resp) {
+			return retryAbuse, retryAfter(resp), "abuse detection mechanism triggered"
+		}
+	case body != nil:
+		if msg, ok := graphQLTemporaryError(body); ok {
+			return retryGraphQL, 0, msg
+		}
+	}
+
+	return "", 0, ""
+}
+
+func isTemporaryError(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "broken pipe")
+}
+
+// isAbuseResponse returns true for the 403 responses caused by the abuse
+// detection mechanism, as opposed to the ones caused by the primary rate
+// limit or by missing permissions.
+// https://developer.github.com/v3/#abuse-rate-limits
+func isAbuseResponse(resp *http.Response) bool {
+	if resp.Header.Get("Retry-After") != "" {
+		return true
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+
+	msg := strings.ToLower(string(b))
+	return strings.Contains(msg, "abuse detection") ||
+		strings.Contains(msg, "secondary rate limit")
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	s, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || s < 0 {
+		return 0
+	}
+
+	return time.Duration(s) * time.Second
+}
+
+// graphQLTemporaryError checks the errors of a GraphQL response, returning
+// the first message of an error worth retrying. Errors like NOT_FOUND are
+// never retried.
+func graphQLTemporaryError(body []byte) (string, bool) {
+	var r struct {
+		Errors []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", false
+	}
+
+	for _, e := range r.Errors {
+		if e.Type != "" && e.Type != "SERVICE_UNAVAILABLE" && e.Type != "TIMEOUT" {
+			continue
+		}
+
+		msg := strings.ToLower(e.Message)
+		if e.Type != "" ||
+			strings.Contains(msg, "something went wrong") ||
+			strings.Contains(msg, "timeout") ||
+			strings.Contains(msg, "timed out") {
+			return e.Message, true
+		}
+	}
+
+	return "", false
+}
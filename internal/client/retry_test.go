@@ -0,0 +1,186 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries: 3,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+		Budget:     100,
+	}
+}
+
+// failingServer responds with the given handler for the first n requests, and
+// with a 200 OK after that
+func failingServer(n int, fail http.HandlerFunc) (*httptest.Server, *int) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= n {
+			fail(w, r)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, `{"data":{"ok":true},"body":%q}`, b)
+	}))
+
+	return srv, &calls
+}
+
+func TestRetryTransport(t *testing.T) {
+	abuse := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"You have triggered an abuse detection mechanism."}`)
+	}
+
+	cases := []struct {
+		name     string
+		failures int
+		fail     http.HandlerFunc
+		calls    int
+		status   int
+	}{
+		{"bad gateway", 2, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}, 3, http.StatusOK},
+		{"too many failures", 10, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, 4, http.StatusServiceUnavailable},
+		{"not implemented", 1, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotImplemented)
+		}, 1, http.StatusNotImplemented},
+		{"abuse", 1, abuse, 2, http.StatusOK},
+		{"rate limit", 1, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"API rate limit exceeded"}`)
+		}, 1, http.StatusForbidden},
+		{"graphql timeout", 2, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"data":null,"errors":[{"message":"Something went wrong while executing your query. This may be the result of a timeout"}]}`)
+		}, 3, http.StatusOK},
+		{"graphql not found", 1, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"data":null,"errors":[{"type":"NOT_FOUND","message":"Could not resolve to a Repository"}]}`)
+		}, 1, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require := require.New(t)
+
+			srv, calls := failingServer(c.failures, c.fail)
+			defer srv.Close()
+
+			client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, testRetryPolicy())}
+			resp, err := client.Post(srv.URL+"/graphql", "application/json", strings.NewReader(`{"query":"{ok}"}`))
+			require.NoError(err)
+			defer resp.Body.Close()
+
+			require.Equal(c.status, resp.StatusCode)
+			require.Equal(c.calls, *calls)
+
+			if c.status == http.StatusOK && c.failures < c.calls {
+				b, err := ioutil.ReadAll(resp.Body)
+				require.NoError(err)
+				require.Contains(string(b), `{\"query\":\"{ok}\"}`)
+			}
+		})
+	}
+}
+
+func TestRetryTransportBudget(t *testing.T) {
+	require := require.New(t)
+
+	srv, calls := failingServer(100, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	defer srv.Close()
+
+	policy := testRetryPolicy()
+	policy.Budget = 4
+	client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, policy)}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		require.NoError(err)
+		resp.Body.Close()
+		require.Equal(http.StatusBadGateway, resp.StatusCode)
+	}
+
+	// 1 + 3 retries for the first request, 1 + 1 for the second, and no
+	// retries for the third one
+	require.Equal(7, *calls)
+	require.Equal(4, policy.Used())
+}
+
+func TestRetryTransportNetworkError(t *testing.T) {
+	require := require.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	policy := testRetryPolicy()
+	client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, policy)}
+
+	_, err := client.Get(url)
+	require.Error(err)
+	require.Equal(3, policy.Used())
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	require := require.New(t)
+
+	p := &RetryPolicy{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	for retry, max := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		max *= time.Second
+		d := p.backoff(retry)
+		require.True(d >= max/2 && d <= max, "retry %d: %v not in [%v, %v]", retry, d, max/2, max)
+	}
+}
+
+func TestNewClientRetries(t *testing.T) {
+	require := require.New(t)
+
+	srv, calls := failingServer(1, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	defer srv.Close()
+
+	// the downloaders used as a library get the default policy
+	c, err := NewClient(&http.Client{Transport: http.DefaultTransport})
+	require.NoError(err)
+
+	resp, err := c.Post(srv.URL+"/graphql", "application/json", strings.NewReader(`{}`))
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.Equal(2, *calls)
+
+	// and the commands set their own
+	policy := testRetryPolicy()
+	c, err = NewClientWithOptions(&http.Client{Transport: http.DefaultTransport}, Options{
+		RetryPolicy: policy,
+		NoCache:     true,
+	})
+	require.NoError(err)
+
+	*calls = 0
+	resp, err = c.Post(srv.URL+"/graphql", "application/json", strings.NewReader(`{}`))
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(2, *calls)
+	require.Equal(1, policy.Used())
+}
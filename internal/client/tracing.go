@@ -0,0 +1,69 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TracingTransport creates a span for each request, as a child of the span in
+// the request context
+type TracingTransport struct {
+	T http.RoundTripper
+}
+
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.method", req.Method),
+		attribute.String("http.url", req.URL.String()),
+	}
+
+	if fields := graphQLFields(req); len(fields) > 0 {
+		attrs = append(attrs, attribute.String("graphql.fields", strings.Join(fields, ",")))
+	}
+
+	ctx, span := tracing.Start(req.Context(), "HTTP "+req.Method, attrs...)
+
+	resp, err := t.T.RoundTrip(req.WithContext(ctx))
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+		if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+			span.SetAttributes(attribute.Int("github.rate_limit.remaining", remaining))
+		}
+	}
+
+	tracing.End(span, err)
+	return resp, err
+}
+
+// graphQLFields returns the top level fields of a GraphQL request, or nil
+// for other requests
+func graphQLFields(req *http.Request) []string {
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/graphql") ||
+		req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+
+	var gql struct {
+		Query string `json:"query"`
+	}
+	if json.Unmarshal(b, &gql) != nil {
+		return nil
+	}
+
+	return topLevelFields(gql.Query)
+}
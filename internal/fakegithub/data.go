@@ -0,0 +1,349 @@
+package fakegithub
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// Config defines the contents of a synthetic repository
+type Config struct {
+	Owner string
+	Name  string
+
+	Issues           int
+	CommentsPerIssue int
+
+	PullRequests                 int
+	CommentsPerPullRequest       int
+	ReviewsPerPullRequest        int
+	CommentsPerPullRequestReview int
+
+	// Reactions is the number of reactions of every issue, PR, comment,
+	// review and review comment
+	Reactions int
+}
+
+// Repository is a synthetic repository served by the fake server
+type Repository struct {
+	ID        int
+	Owner     string
+	Name      string
+	CreatedAt time.Time
+
+	// Private, Fork and Archived are used by the organization repositories
+	// endpoint filters
+	Private  bool
+	Fork     bool
+	Archived bool
+
+	Issues       []*Issue
+	PullRequests []*PullRequest
+}
+
+// Issue is a synthetic issue
+type Issue struct {
+	ID        int
+	Number    int
+	Title     string
+	Body      string
+	Author    string
+	Closed    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Comments  []*Comment
+	Reactions []*Reaction
+	// Attachments are only included in the migration archives that do not
+	// exclude them
+	Attachments []*Attachment
+}
+
+// Attachment is a file uploaded to an issue, referenced by URL from its body
+type Attachment struct {
+	ID          string
+	URL         string
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// PullRequest is a synthetic pull request
+type PullRequest struct {
+	ID        int
+	Number    int
+	Title     string
+	Body      string
+	Author    string
+	Closed    bool
+	Merged    bool
+	BaseRef   string
+	HeadRef   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Comments  []*Comment
+	Reviews   []*Review
+	Threads   []*ReviewThread
+	Reactions []*Reaction
+}
+
+// Comment is a synthetic issue or pull request comment
+type Comment struct {
+	ID        int
+	Author    string
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Reactions []*Reaction
+}
+
+// Review is a synthetic pull request review
+type Review struct {
+	ID          int
+	Author      string
+	Body        string
+	State       string
+	SubmittedAt time.Time
+
+	Comments  []*ReviewComment
+	Reactions []*Reaction
+}
+
+// ReviewComment is a synthetic comment made on the diff of a pull request, as
+// part of a review
+type ReviewComment struct {
+	ID        int
+	Author    string
+	Body      string
+	Path      string
+	Position  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Reactions []*Reaction
+}
+
+// ReviewThread is a synthetic conversation on the diff of a pull request, the
+// review comments on the same position of all the reviews. An empty
+// ResolvedBy is an unresolved thread.
+type ReviewThread struct {
+	ID         int
+	Path       string
+	Line       int
+	Outdated   bool
+	ResolvedBy string
+
+	Comments []*ReviewComment
+}
+
+// Reaction is a synthetic reaction of User, an empty User is a deleted
+// account
+type Reaction struct {
+	ID      int
+	Content string
+	User    string
+}
+
+// reactionContents are the contents of the generated reactions, with a
+// different number of reactions each
+var reactionContents = []string{"THUMBS_UP", "THUMBS_UP", "HEART"}
+
+// allReactionContents are the reaction groups returned by GraphQL, including
+// the ones without reactions
+var allReactionContents = []string{
+	"THUMBS_UP", "THUMBS_DOWN", "LAUGH", "HOORAY", "CONFUSED", "HEART", "ROCKET", "EYES",
+}
+
+var epoch = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var authors = []string{"alice", "bob", "carol"}
+
+// An empty Author is a deleted account, GraphQL returns it as null and REST
+// as the ghost user. The authors with the bot suffix are apps.
+const (
+	ghostLogin = "ghost"
+	ghostID    = 10137
+	botSuffix  = "[bot]"
+)
+
+// accountID returns a stable database ID for the user or organization login
+func accountID(login string) int {
+	if login == ghostLogin {
+		return ghostID
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(login))
+	return int(h.Sum32() % 1000000)
+}
+
+// NewRepository generates a repository with the number of entities defined in
+// cfg. Issues are numbered first, followed by the pull requests. The contents
+// are deterministic, two calls with the same cfg return the same data.
+func NewRepository(cfg Config) *Repository {
+	ids := 1000
+	nextID := func() int {
+		ids++
+		return ids
+	}
+
+	minutes := 0
+	nextTime := func() time.Time {
+		minutes++
+		return epoch.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	r := &Repository{
+		ID:        nextID(),
+		Owner:     cfg.Owner,
+		Name:      cfg.Name,
+		CreatedAt: nextTime(),
+	}
+
+	reactions := func() []*Reaction {
+		var list []*Reaction
+		for i := 0; i < cfg.Reactions; i++ {
+			list = append(list, &Reaction{
+				ID:      nextID(),
+				Content: reactionContents[i%len(reactionContents)],
+				User:    fmt.Sprintf("user-%d", i),
+			})
+		}
+		return list
+	}
+
+	number := 0
+	for i := 0; i < cfg.Issues; i++ {
+		number++
+		issue := &Issue{
+			ID:        nextID(),
+			Number:    number,
+			Title:     fmt.Sprintf("Issue %d", number),
+			Body:      fmt.Sprintf("Body of issue %d", number),
+			Author:    authors[number%len(authors)],
+			Closed:    number%2 == 0,
+			CreatedAt: nextTime(),
+			Reactions: reactions(),
+		}
+		issue.UpdatedAt = issue.CreatedAt
+
+		for j := 0; j < cfg.CommentsPerIssue; j++ {
+			c := &Comment{
+				ID:        nextID(),
+				Author:    authors[j%len(authors)],
+				Body:      fmt.Sprintf("Comment %d on issue %d", j+1, number),
+				CreatedAt: nextTime(),
+				Reactions: reactions(),
+			}
+			c.UpdatedAt = c.CreatedAt
+			issue.Comments = append(issue.Comments, c)
+		}
+
+		r.Issues = append(r.Issues, issue)
+	}
+
+	for i := 0; i < cfg.PullRequests; i++ {
+		number++
+		pr := &PullRequest{
+			ID:        nextID(),
+			Number:    number,
+			Title:     fmt.Sprintf("PR %d", number),
+			Body:      fmt.Sprintf("Body of PR %d", number),
+			Author:    authors[number%len(authors)],
+			Closed:    number%2 == 0,
+			Merged:    number%4 == 0,
+			BaseRef:   "master",
+			HeadRef:   fmt.Sprintf("branch-%d", number),
+			CreatedAt: nextTime(),
+			Reactions: reactions(),
+		}
+		pr.UpdatedAt = pr.CreatedAt
+
+		for j := 0; j < cfg.CommentsPerPullRequest; j++ {
+			c := &Comment{
+				ID:        nextID(),
+				Author:    authors[j%len(authors)],
+				Body:      fmt.Sprintf("Comment %d on PR %d", j+1, number),
+				CreatedAt: nextTime(),
+				Reactions: reactions(),
+			}
+			c.UpdatedAt = c.CreatedAt
+			pr.Comments = append(pr.Comments, c)
+		}
+
+		for j := 0; j < cfg.ReviewsPerPullRequest; j++ {
+			review := &Review{
+				ID:          nextID(),
+				Author:      authors[j%len(authors)],
+				Body:        fmt.Sprintf("Review %d on PR %d", j+1, number),
+				State:       "COMMENTED",
+				SubmittedAt: nextTime(),
+				Reactions:   reactions(),
+			}
+
+			for k := 0; k < cfg.CommentsPerPullRequestReview; k++ {
+				c := &ReviewComment{
+					ID:        nextID(),
+					Author:    review.Author,
+					Body:      fmt.Sprintf("Review comment %d on review %d", k+1, review.ID),
+					Path:      "README.md",
+					Position:  k + 1,
+					CreatedAt: nextTime(),
+					Reactions: reactions(),
+				}
+				c.UpdatedAt = c.CreatedAt
+				review.Comments = append(review.Comments, c)
+			}
+
+			pr.Reviews = append(pr.Reviews, review)
+		}
+
+		r.PullRequests = append(r.PullRequests, pr)
+	}
+
+	// the threads get their IDs after all the other entities, so the IDs do
+	// not depend on the number of threads
+	for _, pr := range r.PullRequests {
+		for k := 0; k < cfg.CommentsPerPullRequestReview && len(pr.Reviews) > 0; k++ {
+			thread := &ReviewThread{
+				ID:       nextID(),
+				Path:     "README.md",
+				Line:     k + 1,
+				Outdated: k%3 == 2,
+			}
+			if k%2 == 1 {
+				thread.ResolvedBy = pr.Author
+			}
+
+			for _, review := range pr.Reviews {
+				thread.Comments = append(thread.Comments, review.Comments[k])
+			}
+
+			pr.Threads = append(pr.Threads, thread)
+		}
+	}
+
+	return r
+}
+
+func (r *Repository) issue(number int) *Issue {
+	for _, i := range r.Issues {
+		if i.Number == number {
+			return i
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) pullRequest(number int) *PullRequest {
+	for _, pr := range r.PullRequests {
+		if pr.Number == number {
+			return pr
+		}
+	}
+
+	return nil
+}
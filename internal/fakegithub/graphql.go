@@ -0,0 +1,510 @@
+package fakegithub
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file implements the subset of GraphQL needed to answer the queries
+// generated by github.com/shurcooL/githubv4: selection sets, arguments with
+// variables or literals, aliases and inline fragments. Query validation is
+// not done, fields not defined by an object are returned as null.
+
+// selection is a field or an inline fragment in a selection set
+type selection struct {
+	alias string
+	name  string
+	args  map[string]interface{}
+
+	// on is the type condition for inline fragments, name is empty
+	on string
+
+	selections []*selection
+}
+
+func (s *selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+
+	return s.name
+}
+
+// variable is a reference to a query variable, resolved at execution time
+type variable string
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+// parseQuery returns the top level selection set of a query document
+func parseQuery(query string) ([]*selection, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	if p.peek() == "query" {
+		p.next()
+	}
+
+	if p.peek() != "{" && p.peek() != "(" {
+		// operation name
+		p.next()
+	}
+
+	if p.peek() == "(" {
+		// variable definitions, the values are taken from the request
+		depth := 0
+		for {
+			t := p.next()
+			if t == "" {
+				return nil, fmt.Errorf("unexpected end of query")
+			}
+			if t == "(" {
+				depth++
+			}
+			if t == ")" {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+	}
+
+	return p.selectionSet()
+}
+
+func (p *parser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) expect(t string) error {
+	if got := p.next(); got != t {
+		return fmt.Errorf("expected %q, got %q", t, got)
+	}
+
+	return nil
+}
+
+func (p *parser) selectionSet() ([]*selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []*selection
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, fmt.Errorf("unexpected end of query")
+		}
+
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+
+		selections = append(selections, s)
+
+		if p.peek() == "," {
+			p.next()
+		}
+	}
+
+	p.next()
+	return selections, nil
+}
+
+func (p *parser) selection() (*selection, error) {
+	s := &selection{}
+
+	if p.peek() == "..." {
+		p.next()
+		if err := p.expect("on"); err != nil {
+			return nil, err
+		}
+
+		s.on = p.next()
+
+		var err error
+		s.selections, err = p.selectionSet()
+		return s, err
+	}
+
+	s.name = p.next()
+	if p.peek() == ":" {
+		p.next()
+		s.alias = s.name
+		s.name = p.next()
+	}
+
+	if p.peek() == "(" {
+		p.next()
+		s.args = make(map[string]interface{})
+
+		for p.peek() != ")" {
+			name := p.next()
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			s.args[name] = v
+
+			if p.peek() == "," {
+				p.next()
+			}
+		}
+
+		p.next()
+	}
+
+	if p.peek() == "{" {
+		var err error
+		s.selections, err = p.selectionSet()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (p *parser) value() (interface{}, error) {
+	t := p.next()
+
+	switch {
+	case t == "$":
+		return variable(p.next()), nil
+	case t == "[":
+		var list []interface{}
+		for p.peek() != "]" {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if p.peek() == "," {
+				p.next()
+			}
+		}
+		p.next()
+		return list, nil
+	case t == "{":
+		obj := make(map[string]interface{})
+		for p.peek() != "}" {
+			name := p.next()
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = v
+			if p.peek() == "," {
+				p.next()
+			}
+		}
+		p.next()
+		return obj, nil
+	case strings.HasPrefix(t, `"`):
+		return strconv.Unquote(t)
+	case t == "true" || t == "false":
+		return t == "true", nil
+	case t == "null":
+		return nil, nil
+	case len(t) > 0 && (t[0] == '-' || ('0' <= t[0] && t[0] <= '9')):
+		if strings.ContainsAny(t, ".eE") {
+			return strconv.ParseFloat(t, 64)
+		}
+		n, err := strconv.Atoi(t)
+		return float64(n), err
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+
+	// enum value
+	return t, nil
+}
+
+func tokenize(query string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(query); {
+		c := query[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case strings.IndexByte("{}()[]:,$!=@", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(query) && query[j] != '"'; j++ {
+				if query[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(query) {
+				return nil, fmt.Errorf("unterminated string in query")
+			}
+			tokens = append(tokens, query[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(query) && strings.IndexByte(" \t\n\r#{}()[]:,$!=@\"", query[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
+
+// object is a GraphQL object. The field values can be scalars, *object,
+// []*object, or a resolver for fields that take arguments.
+type object struct {
+	typename string
+	// interfaces implemented by the type, used for inline fragments
+	interfaces []string
+	fields     map[string]interface{}
+}
+
+// resolver computes the value of a field from its arguments
+type resolver func(args map[string]interface{}) (interface{}, error)
+
+// notFoundError is returned by resolvers when the requested object does not
+// exist, the same way GitHub returns a NOT_FOUND error
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string {
+	return e.msg
+}
+
+func (o *object) implements(typ string) bool {
+	if o.typename == typ {
+		return true
+	}
+
+	for _, i := range o.interfaces {
+		if i == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+// execute resolves the selections against the given object, and returns the
+// result ready to be encoded as JSON
+func execute(o *object, selections []*selection, vars map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	for _, s := range selections {
+		if s.on != "" {
+			if !o.implements(s.on) {
+				continue
+			}
+
+			fragment, err := execute(o, s.selections, vars)
+			if err != nil {
+				return nil, err
+			}
+
+			for k, v := range fragment {
+				result[k] = v
+			}
+
+			continue
+		}
+
+		if s.name == "__typename" {
+			result[s.key()] = o.typename
+			continue
+		}
+
+		v := o.fields[s.name]
+		if r, ok := v.(resolver); ok {
+			args := make(map[string]interface{})
+			for k, a := range s.args {
+				args[k] = resolveValue(a, vars)
+			}
+
+			var err error
+			v, err = r(args)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		result[s.key()], err = executeValue(v, s, vars)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func executeValue(v interface{}, s *selection, vars map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case *object:
+		if v == nil {
+			return nil, nil
+		}
+		return execute(v, s.selections, vars)
+	case []*object:
+		list := make([]interface{}, 0, len(v))
+		for _, o := range v {
+			r, err := executeValue(o, s, vars)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, r)
+		}
+		return list, nil
+	}
+
+	return v, nil
+}
+
+func resolveValue(v interface{}, vars map[string]interface{}) interface{} {
+	switch v := v.(type) {
+	case variable:
+		return vars[string(v)]
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = resolveValue(e, vars)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{})
+		for k, e := range v {
+			obj[k] = resolveValue(e, vars)
+		}
+		return obj
+	}
+
+	return v
+}
+
+// connection returns a resolver that paginates over the objects returned by
+// list, following https://facebook.github.io/relay/graphql/connections.htm
+func connection(typename string, list func() []*object) resolver {
+	return func(args map[string]interface{}) (interface{}, error) {
+		nodes := list()
+
+		start := 0
+		if after, ok := args["after"].(string); ok && after != "" {
+			i, err := decodeCursor(after)
+			if err != nil {
+				return nil, err
+			}
+			start = i + 1
+		}
+
+		if start > len(nodes) {
+			start = len(nodes)
+		}
+
+		end := len(nodes)
+		if first, ok := args["first"].(float64); ok {
+			if first < 0 || first > 100 {
+				return nil, fmt.Errorf("first must be between 0 and 100")
+			}
+			if start+int(first) < end {
+				end = start + int(first)
+			}
+		}
+
+		page := nodes[start:end]
+
+		var edges []*object
+		for i, n := range page {
+			edges = append(edges, &object{
+				typename: typename + "Edge",
+				fields: map[string]interface{}{
+					"cursor": encodeCursor(start + i),
+					"node":   n,
+				},
+			})
+		}
+
+		var startCursor, endCursor interface{}
+		if len(page) > 0 {
+			startCursor = encodeCursor(start)
+			endCursor = encodeCursor(end - 1)
+		}
+
+		return &object{
+			typename: typename,
+			fields: map[string]interface{}{
+				"totalCount": len(nodes),
+				"nodes":      page,
+				"edges":      edges,
+				"pageInfo": &object{
+					typename: "PageInfo",
+					fields: map[string]interface{}{
+						"hasNextPage":     end < len(nodes),
+						"hasPreviousPage": start > 0,
+						"startCursor":     startCursor,
+						"endCursor":       endCursor,
+					},
+				},
+			},
+		}, nil
+	}
+}
+
+func encodeCursor(i int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("cursor:v2:%d", i)))
+}
+
+func decodeCursor(c string) (int, error) {
+	b, err := base64.StdEncoding.DecodeString(c)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %q", c)
+	}
+
+	return strconv.Atoi(strings.TrimPrefix(string(b), "cursor:v2:"))
+}
+
+// intArg returns a numeric argument, GraphQL numbers are decoded from JSON
+// as float64
+func intArg(args map[string]interface{}, name string) int {
+	f, _ := args[name].(float64)
+	return int(f)
+}
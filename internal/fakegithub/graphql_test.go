@@ -0,0 +1,50 @@
+package fakegithub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecute(t *testing.T) {
+	require := require.New(t)
+
+	query := `query($after:String$id:ID!$n:Int!){first:node(id: $id){__typename,... on PullRequest{number},... on Issue{title}},` +
+		`repository(owner: "org", name: "repo"){pullRequests(first: $n, after: $after){totalCount,pageInfo{hasNextPage,endCursor},nodes{number}}}}`
+
+	selections, err := parseQuery(query)
+	require.NoError(err)
+
+	srv := NewServer(NewRepository(Config{Owner: "org", Name: "repo", PullRequests: 3}))
+	defer srv.Close()
+
+	vars := map[string]interface{}{
+		"id":    nodeID("PullRequest", 1002),
+		"n":     float64(2),
+		"after": nil,
+	}
+
+	data, err := execute(newSchema(srv).query(), selections, vars)
+	require.NoError(err)
+
+	require.Equal(map[string]interface{}{"__typename": "PullRequest", "number": 1}, data["first"])
+
+	prs := data["repository"].(map[string]interface{})["pullRequests"].(map[string]interface{})
+	require.Equal(3, prs["totalCount"])
+	require.Len(prs["nodes"], 2)
+
+	pageInfo := prs["pageInfo"].(map[string]interface{})
+	require.Equal(true, pageInfo["hasNextPage"])
+
+	vars["after"] = pageInfo["endCursor"]
+	data, err = execute(newSchema(srv).query(), selections, vars)
+	require.NoError(err)
+
+	prs = data["repository"].(map[string]interface{})["pullRequests"].(map[string]interface{})
+	require.Equal([]interface{}{map[string]interface{}{"number": 3}}, prs["nodes"])
+	require.Equal(false, prs["pageInfo"].(map[string]interface{})["hasNextPage"])
+
+	vars["id"] = "missing"
+	_, err = execute(newSchema(srv).query(), selections, vars)
+	require.IsType(&notFoundError{}, err)
+}
@@ -0,0 +1,629 @@
+package fakegithub
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file implements the organization migrations endpoints in
+// https://developer.github.com/v3/migrations/orgs/ used by the migration
+// downloader. The archives are generated from the served repositories, with
+// the same JSON files as the real ones.
+
+// MaxMigrationRepositories is the maximum number of repositories of a
+// migration, more are rejected with a 422
+const MaxMigrationRepositories = 100
+
+// archiveVersion is the format version written in the schema.json of the
+// archives
+const archiveVersion = "1.0.1"
+
+type migration struct {
+	ID               int
+	Org              string
+	Repositories     []*Repository
+	LockRepositories bool
+	// ExcludeAttachments excludes the issue attachments from the archive
+	ExcludeAttachments bool
+	CreatedAt          time.Time
+
+	// polls is the number of status requests received
+	polls int
+	// archiveDeleted is set when the archive is deleted
+	archiveDeleted bool
+	// unlocked are the names of the locked repositories that were unlocked
+	unlocked map[string]bool
+}
+
+// migrationState returns the state of the migration m, exported after
+// ExportPolls status requests
+func (s *Server) migrationState(m *migration) string {
+	switch {
+	case m.polls == 0:
+		return "pending"
+	case m.polls < s.ExportPolls:
+		return "exporting"
+	default:
+		return "exported"
+	}
+}
+
+func (s *Server) restMigration(m *migration) map[string]interface{} {
+	repos := make([]interface{}, len(m.Repositories))
+	for i, r := range m.Repositories {
+		repos[i] = s.restRepository(r)
+	}
+
+	return map[string]interface{}{
+		"id":                  m.ID,
+		"guid":                fmt.Sprintf("guid-%d", m.ID),
+		"state":               s.migrationState(m),
+		"lock_repositories":   m.LockRepositories,
+		"exclude_attachments": m.ExcludeAttachments,
+		"url":                 *s.apiURL("/orgs/%s/migrations/%d", m.Org, m.ID),
+		"created_at":          m.CreatedAt.Format(time.RFC3339),
+		"updated_at":          m.CreatedAt.Format(time.RFC3339),
+		"repositories":        repos,
+	}
+}
+
+// serveMigrations serves the /orgs/{login}/migrations endpoints. parts is the
+// path after migrations.
+func (s *Server) serveMigrations(w http.ResponseWriter, r *http.Request, login string, parts []string) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodPost:
+			s.startMigration(w, r, login)
+		case http.MethodGet:
+			s.listMigrations(w, login)
+		default:
+			writeError(w, http.StatusNotFound, "Not Found")
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.m.Lock()
+	m, ok := s.migrations[id]
+	if ok && len(parts) == 1 && r.Method == http.MethodGet {
+		m.polls++
+	}
+	s.m.Unlock()
+
+	if !ok || !strings.EqualFold(m.Org, login) {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		s.deleteMigrationResource(w, m, parts[1:])
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		s.m.Lock()
+		resp := s.restMigration(m)
+		s.m.Unlock()
+
+		writeJSON(w, http.StatusOK, resp)
+	case len(parts) == 2 && parts[1] == "archive":
+		s.m.Lock()
+		exported := s.migrationState(m) == "exported" && !m.archiveDeleted
+		s.m.Unlock()
+
+		if !exported {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+
+		location := fmt.Sprintf("%s/archives/%d.tar.gz", s.URL, m.ID)
+		if s.ArchiveURLExpiry > 0 {
+			location += fmt.Sprintf("?expires=%d", time.Now().Add(s.ArchiveURLExpiry).UnixNano())
+		}
+
+		w.Header().Set("Location", location)
+		w.WriteHeader(http.StatusFound)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// deleteMigrationResource serves DELETE /orgs/{login}/migrations/{id}/archive
+// and /orgs/{login}/migrations/{id}/repos/{name}/lock. parts is the path after
+// the migration ID.
+func (s *Server) deleteMigrationResource(w http.ResponseWriter, m *migration, parts []string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	switch {
+	case len(parts) == 1 && parts[0] == "archive":
+		m.archiveDeleted = true
+	case len(parts) == 3 && parts[0] == "repos" && parts[2] == "lock":
+		found := false
+		for _, r := range m.Repositories {
+			found = found || strings.EqualFold(r.Name, parts[1])
+		}
+
+		if !found || !m.LockRepositories {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+
+		if m.unlocked == nil {
+			m.unlocked = make(map[string]bool)
+		}
+		m.unlocked[strings.ToLower(parts[1])] = true
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listMigrations serves GET /orgs/{login}/migrations
+func (s *Server) listMigrations(w http.ResponseWriter, login string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var ids []int
+	for id, m := range s.migrations {
+		if strings.EqualFold(m.Org, login) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	items := []interface{}{}
+	for _, id := range ids {
+		items = append(items, s.restMigration(s.migrations[id]))
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// ArchiveDeleted returns true if the archive of the migration id was deleted
+func (s *Server) ArchiveDeleted(id int64) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	m, ok := s.migrations[int(id)]
+	return ok && m.archiveDeleted
+}
+
+// LockedRepositories returns the names of the repositories of the migration id
+// that are locked
+func (s *Server) LockedRepositories(id int64) []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	m, ok := s.migrations[int(id)]
+	if !ok || !m.LockRepositories {
+		return nil
+	}
+
+	var names []string
+	for _, r := range m.Repositories {
+		if !m.unlocked[strings.ToLower(r.Name)] {
+			names = append(names, r.Name)
+		}
+	}
+
+	return names
+}
+
+func (s *Server) startMigration(w http.ResponseWriter, r *http.Request, login string) {
+	var req struct {
+		Repositories       []string `json:"repositories"`
+		LockRepositories   bool     `json:"lock_repositories"`
+		ExcludeAttachments bool     `json:"exclude_attachments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	if len(req.Repositories) == 0 || len(req.Repositories) > MaxMigrationRepositories {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+
+	var repos []*Repository
+	for _, name := range req.Repositories {
+		// the names can be name or owner/name
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+
+		repo := s.repository(login, name)
+		if repo == nil {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+			return
+		}
+		repos = append(repos, repo)
+	}
+
+	s.m.Lock()
+	if s.migrations == nil {
+		s.migrations = make(map[int]*migration)
+	}
+	m := &migration{
+		ID:                 100000 + len(s.migrations) + 1,
+		Org:                login,
+		Repositories:       repos,
+		LockRepositories:   req.LockRepositories,
+		ExcludeAttachments: req.ExcludeAttachments,
+		CreatedAt:          time.Now().UTC().Truncate(time.Second),
+	}
+	s.migrations[m.ID] = m
+	resp := s.restMigration(m)
+	s.m.Unlock()
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// serveArchive serves /archives/{id}.tar.gz, the URL the archive endpoint
+// redirects to. Range requests are supported, an expired URL gets a 403 like
+// S3, and the first InterruptArchives responses are cut in half.
+func (s *Server) serveArchive(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/archives/"), ".tar.gz"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if expires := r.URL.Query().Get("expires"); expires != "" {
+		t, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().UnixNano() > t {
+			http.Error(w, "Request has expired", http.StatusForbidden)
+			return
+		}
+	}
+
+	s.m.Lock()
+	m, ok := s.migrations[id]
+	interrupt := s.InterruptArchives > 0
+	if interrupt {
+		s.InterruptArchives--
+	}
+	s.m.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	b, err := archive(m)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-gzip")
+	if interrupt {
+		w = &interruptedWriter{ResponseWriter: w}
+	}
+
+	http.ServeContent(w, r, "", m.CreatedAt, bytes.NewReader(b))
+}
+
+// interruptedWriter writes only half of the body announced by the
+// Content-Length header, the connection is closed without the rest
+type interruptedWriter struct {
+	http.ResponseWriter
+	remaining int
+	started   bool
+}
+
+func (w *interruptedWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		n, _ := strconv.Atoi(w.Header().Get("Content-Length"))
+		w.remaining = n / 2
+	}
+
+	if w.remaining <= 0 {
+		return 0, fmt.Errorf("interrupted")
+	}
+
+	if len(b) > w.remaining {
+		b = b[:w.remaining]
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.remaining -= n
+	if err == nil && w.remaining == 0 {
+		err = fmt.Errorf("interrupted")
+	}
+
+	return n, err
+}
+
+// archive returns the tar.gz of the migration m
+func archive(m *migration) ([]byte, error) {
+	files := make(map[string][]byte)
+	for name, v := range archiveFiles(m) {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		files[name] = b
+	}
+
+	if !m.ExcludeAttachments {
+		for _, r := range m.Repositories {
+			for _, i := range r.Issues {
+				for _, a := range i.Attachments {
+					files[attachmentPath(a)] = a.Content
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range names {
+		b := files[name]
+
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(b)),
+			ModTime: m.CreatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tw.Write(b); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// attachmentPath returns the path of the attachment file in the archive
+func attachmentPath(a *Attachment) string {
+	return fmt.Sprintf("attachments/%s/%s", a.ID, a.Name)
+}
+
+// archiveFiles returns the contents of each JSON file of the archive of m
+func archiveFiles(m *migration) map[string]interface{} {
+	var (
+		attachments    []interface{}
+		users          []interface{}
+		repositories   []interface{}
+		issues         []interface{}
+		prs            []interface{}
+		comments       []interface{}
+		reviews        []interface{}
+		reviewComments []interface{}
+	)
+
+	seen := make(map[string]bool)
+	user := func(login string) string {
+		if login == "" {
+			login = ghostLogin
+		}
+
+		typ := "user"
+		if strings.HasSuffix(login, botSuffix) {
+			typ = "bot"
+		}
+
+		u := githubURL + "/" + login
+		if !seen[login] {
+			seen[login] = true
+			users = append(users, map[string]interface{}{
+				"type":       typ,
+				"url":        u,
+				"avatar_url": fmt.Sprintf("https://avatars.githubusercontent.com/u/%d", accountID(login)),
+				"login":      login,
+				"name":       strings.Title(login),
+				"emails":     []interface{}{},
+				"created_at": epoch.Format(time.RFC3339),
+			})
+		}
+
+		return u
+	}
+
+	for _, r := range m.Repositories {
+		repoURL := fmt.Sprintf("%s/%s/%s", githubURL, r.Owner, r.Name)
+
+		repositories = append(repositories, map[string]interface{}{
+			"type":           "repository",
+			"url":            repoURL,
+			"owner":          githubURL + "/" + r.Owner,
+			"name":           r.Name,
+			"description":    fmt.Sprintf("Synthetic repository %s/%s", r.Owner, r.Name),
+			"private":        r.Private,
+			"default_branch": "master",
+			"created_at":     r.CreatedAt.Format(time.RFC3339),
+		})
+
+		comment := func(itemURL, key string, c *Comment) {
+			comments = append(comments, map[string]interface{}{
+				"type":       "issue_comment",
+				"url":        fmt.Sprintf("%s#issuecomment-%d", itemURL, c.ID),
+				key:          itemURL,
+				"user":       user(c.Author),
+				"body":       c.Body,
+				"formatter":  "markdown",
+				"created_at": c.CreatedAt.Format(time.RFC3339),
+			})
+		}
+
+		for _, i := range r.Issues {
+			issueURL := fmt.Sprintf("%s/issues/%d", repoURL, i.Number)
+			issues = append(issues, map[string]interface{}{
+				"type":       "issue",
+				"url":        issueURL,
+				"repository": repoURL,
+				"user":       user(i.Author),
+				"title":      i.Title,
+				"body":       i.Body,
+				"assignees":  []interface{}{},
+				"labels":     []interface{}{},
+				"closed_at":  closedAt(i.Closed, i.CreatedAt),
+				"created_at": i.CreatedAt.Format(time.RFC3339),
+			})
+
+			for _, c := range i.Comments {
+				comment(issueURL, "issue", c)
+			}
+
+			if m.ExcludeAttachments {
+				continue
+			}
+
+			for _, a := range i.Attachments {
+				attachments = append(attachments, map[string]interface{}{
+					"type":               "attachment",
+					"url":                a.URL,
+					"issue":              issueURL,
+					"issue_comment":      nil,
+					"user":               user(i.Author),
+					"asset_name":         a.Name,
+					"asset_content_type": a.ContentType,
+					"asset_url":          "tarball://root/" + attachmentPath(a),
+					"created_at":         i.CreatedAt.Format(time.RFC3339),
+				})
+			}
+		}
+
+		for _, pr := range r.PullRequests {
+			prURL := fmt.Sprintf("%s/pull/%d", repoURL, pr.Number)
+
+			var mergedAt *time.Time
+			if pr.Merged {
+				mergedAt = closedAt(pr.Closed, pr.CreatedAt)
+			}
+
+			prs = append(prs, map[string]interface{}{
+				"type":       "pull_request",
+				"url":        prURL,
+				"repository": repoURL,
+				"user":       user(pr.Author),
+				"title":      pr.Title,
+				"body":       pr.Body,
+				"base":       map[string]interface{}{"ref": pr.BaseRef, "repo": repoURL},
+				"head":       map[string]interface{}{"ref": pr.HeadRef, "repo": repoURL},
+				"assignees":  []interface{}{},
+				"labels":     []interface{}{},
+				"merged_at":  mergedAt,
+				"closed_at":  closedAt(pr.Closed, pr.CreatedAt),
+				"created_at": pr.CreatedAt.Format(time.RFC3339),
+			})
+
+			for _, c := range pr.Comments {
+				comment(prURL, "pull_request", c)
+			}
+
+			for _, review := range pr.Reviews {
+				reviewURL := fmt.Sprintf("%s/files#pullrequestreview-%d", prURL, review.ID)
+				reviews = append(reviews, map[string]interface{}{
+					"type":         "pull_request_review",
+					"url":          reviewURL,
+					"pull_request": prURL,
+					"user":         user(review.Author),
+					"body":         review.Body,
+					"formatter":    "markdown",
+					"state":        archiveReviewStates[review.State],
+					"created_at":   review.SubmittedAt.Format(time.RFC3339),
+					"submitted_at": review.SubmittedAt.Format(time.RFC3339),
+				})
+
+				for _, c := range review.Comments {
+					reviewComments = append(reviewComments, map[string]interface{}{
+						"type":                "pull_request_review_comment",
+						"url":                 fmt.Sprintf("%s/files#r%d", prURL, c.ID),
+						"pull_request":        prURL,
+						"pull_request_review": reviewURL,
+						"user":                user(c.Author),
+						"body":                c.Body,
+						"formatter":           "markdown",
+						"path":                c.Path,
+						"position":            c.Position,
+						"original_position":   c.Position,
+						"created_at":          c.CreatedAt.Format(time.RFC3339),
+					})
+				}
+			}
+		}
+	}
+
+	files := map[string]interface{}{
+		"schema.json": map[string]interface{}{"version": archiveVersion},
+		"organizations_000001.json": []interface{}{map[string]interface{}{
+			"type":       "organization",
+			"url":        githubURL + "/" + m.Org,
+			"login":      m.Org,
+			"name":       m.Org,
+			"created_at": epoch.Format(time.RFC3339),
+		}},
+		"users_000001.json":        users,
+		"repositories_000001.json": repositories,
+	}
+
+	// like the real archives, there are no files for the empty kinds
+	for name, items := range map[string][]interface{}{
+		"issues_000001.json":                       issues,
+		"pull_requests_000001.json":                prs,
+		"issue_comments_000001.json":               comments,
+		"pull_request_reviews_000001.json":         reviews,
+		"pull_request_review_comments_000001.json": reviewComments,
+		"attachments_000001.json":                  attachments,
+	} {
+		if len(items) > 0 {
+			files[name] = items
+		}
+	}
+
+	return files
+}
+
+// archiveReviewStates are the numeric review states used in the archives
+var archiveReviewStates = map[string]int{
+	"PENDING":           0,
+	"COMMENTED":         1,
+	"CHANGES_REQUESTED": 30,
+	"APPROVED":          40,
+	"DISMISSED":         50,
+}
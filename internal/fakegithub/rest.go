@@ -0,0 +1,619 @@
+package fakegithub
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// This file implements the REST endpoints in https://developer.github.com/v3/
+// used by the v3 downloader. The responses are built with the go-github types.
+
+const (
+	defaultPerPage = 30
+	maxPerPage     = 100
+)
+
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(parts) == 1 && parts[0] == "rate_limit" {
+		s.serveRateLimit(w)
+		return
+	}
+
+	if len(parts) >= 2 && parts[0] == "orgs" {
+		s.serveOrganization(w, r, parts[1], parts[2:])
+		return
+	}
+
+	if len(parts) < 3 || parts[0] != "repos" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	repo := s.repository(parts[1], parts[2])
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	parts = parts[3:]
+	if len(parts) == 2 && parts[1] == "comments" {
+		switch parts[0] {
+		case "issues":
+			s.serveRepositoryIssueComments(w, r, repo)
+			return
+		case "pulls":
+			s.serveRepositoryReviewComments(w, r, repo)
+			return
+		}
+	}
+
+	var number int
+	if len(parts) >= 2 {
+		var err error
+		number, err = strconv.Atoi(parts[1])
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+	}
+
+	switch {
+	case len(parts) == 0:
+		writeJSON(w, http.StatusOK, s.restRepository(repo))
+	case len(parts) == 1 && parts[0] == "issues":
+		s.serveIssues(w, r, repo)
+	case len(parts) == 2 && parts[0] == "issues":
+		s.serveIssue(w, repo, number)
+	case len(parts) == 3 && parts[0] == "issues" && parts[2] == "comments":
+		s.serveIssueComments(w, r, repo, number)
+	case len(parts) == 1 && parts[0] == "pulls":
+		s.servePullRequests(w, r, repo)
+	case len(parts) == 2 && parts[0] == "pulls":
+		s.servePullRequest(w, repo, number)
+	case len(parts) == 3 && parts[0] == "pulls" && parts[2] == "reviews":
+		s.serveReviews(w, r, repo, number)
+	case len(parts) == 3 && parts[0] == "pulls" && parts[2] == "comments":
+		s.serveReviewComments(w, r, repo, number)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) serveRateLimit(w http.ResponseWriter) {
+	remaining, resetAt := s.rateLimit()
+
+	rate := &github.Rate{
+		Limit:     rateLimit,
+		Remaining: remaining,
+		Reset:     github.Timestamp{Time: resetAt},
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources": map[string]interface{}{
+			"core":    rate,
+			"search":  &github.Rate{Limit: 30, Remaining: 30, Reset: github.Timestamp{Time: resetAt}},
+			"graphql": rate,
+		},
+		"rate": rate,
+	})
+}
+
+// paginate writes the page of items requested with the page and per_page
+// parameters, adding the Link header used by go-github to find the next page
+func paginate(w http.ResponseWriter, r *http.Request, items []interface{}) {
+	query := r.URL.Query()
+
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	current, err := strconv.Atoi(query.Get("page"))
+	if err != nil || current <= 0 {
+		current = 1
+	}
+
+	lastPage := (len(items) + perPage - 1) / perPage
+	if lastPage == 0 {
+		lastPage = 1
+	}
+
+	start := (current - 1) * perPage
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+
+	pageURL := func(p int) string {
+		u := url.URL{Path: r.URL.Path}
+		q := r.URL.Query()
+		q.Set("page", fmt.Sprint(p))
+		q.Set("per_page", fmt.Sprint(perPage))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf("<http://%s%s>", r.Host, u.String())
+	}
+
+	var links []string
+	if current < lastPage {
+		links = append(links,
+			pageURL(current+1)+`; rel="next"`,
+			pageURL(lastPage)+`; rel="last"`)
+	}
+	if current > 1 {
+		links = append(links,
+			pageURL(1)+`; rel="first"`,
+			pageURL(current-1)+`; rel="prev"`)
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	page := items[start:end]
+	if page == nil {
+		page = []interface{}{}
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) apiURL(format string, a ...interface{}) *string {
+	return github.String(s.URL + fmt.Sprintf(format, a...))
+}
+
+func (s *Server) user(login string) *github.User {
+	if login == "" {
+		login = ghostLogin
+	}
+
+	typ := "User"
+	if strings.HasSuffix(login, botSuffix) {
+		typ = "Bot"
+	}
+
+	return &github.User{
+		ID:      github.Int64(int64(accountID(login))),
+		Login:   github.String(login),
+		Type:    github.String(typ),
+		URL:     s.apiURL("/users/%s", login),
+		HTMLURL: github.String(githubURL + "/" + login),
+	}
+}
+
+func (s *Server) restRepository(r *Repository) *github.Repository {
+	return &github.Repository{
+		ID:       github.Int64(int64(r.ID)),
+		NodeID:   github.String(nodeID("Repository", r.ID)),
+		Name:     github.String(r.Name),
+		FullName: github.String(r.Owner + "/" + r.Name),
+		Owner: &github.User{
+			Login: github.String(r.Owner),
+			Type:  github.String("Organization"),
+		},
+		Description: github.String(fmt.Sprintf("Synthetic repository %s/%s", r.Owner, r.Name)),
+		CreatedAt:   &github.Timestamp{Time: r.CreatedAt},
+		UpdatedAt:   &github.Timestamp{Time: r.CreatedAt},
+		PushedAt:    &github.Timestamp{Time: r.CreatedAt},
+		Private:     github.Bool(r.Private),
+		Fork:        github.Bool(r.Fork),
+		Archived:    github.Bool(r.Archived),
+		HasIssues:   github.Bool(true),
+		HasWiki:     github.Bool(true),
+		URL:         s.apiURL("/repos/%s/%s", r.Owner, r.Name),
+		HTMLURL:     github.String(fmt.Sprintf("%s/%s/%s", githubURL, r.Owner, r.Name)),
+	}
+}
+
+// serveOrganization serves /orgs/{login} and /orgs/{login}/repos. An
+// organization exists if any of the repositories is owned by it.
+func (s *Server) serveOrganization(w http.ResponseWriter, r *http.Request, login string, parts []string) {
+	var repos []*Repository
+	for _, repo := range s.repositories() {
+		if strings.EqualFold(repo.Owner, login) {
+			repos = append(repos, repo)
+		}
+	}
+
+	if len(repos) == 0 {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	switch {
+	case len(parts) == 0:
+		writeJSON(w, http.StatusOK, s.restOrganization(repos[0].Owner))
+	case len(parts) == 1 && parts[0] == "repos":
+		items := []interface{}{}
+		for _, repo := range repos {
+			if matchesType(repo, r.URL.Query().Get("type")) {
+				items = append(items, s.restRepository(repo))
+			}
+		}
+
+		paginate(w, r, items)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// matchesType filters the organization repositories by the type parameter
+func matchesType(r *Repository, typ string) bool {
+	switch typ {
+	case "public":
+		return !r.Private
+	case "private":
+		return r.Private
+	case "forks":
+		return r.Fork
+	case "sources":
+		return !r.Fork
+	default:
+		return true
+	}
+}
+
+func (s *Server) restOrganization(login string) *github.Organization {
+	id := accountID(login)
+	createdAt := epoch
+
+	return &github.Organization{
+		ID:          github.Int64(int64(id)),
+		NodeID:      github.String(nodeID("Organization", id)),
+		Login:       github.String(login),
+		Name:        github.String(login),
+		Description: github.String(fmt.Sprintf("Synthetic organization %s", login)),
+		Type:        github.String("Organization"),
+		CreatedAt:   &createdAt,
+		UpdatedAt:   &createdAt,
+		URL:         s.apiURL("/orgs/%s", login),
+		HTMLURL:     github.String(githubURL + "/" + login),
+	}
+}
+
+func state(closed bool) *string {
+	if closed {
+		return github.String("closed")
+	}
+
+	return github.String("open")
+}
+
+func closedAt(closed bool, createdAt time.Time) *time.Time {
+	if !closed {
+		return nil
+	}
+
+	t := createdAt.Add(time.Hour)
+	return &t
+}
+
+func (s *Server) restIssue(r *Repository, i *Issue) *github.Issue {
+	return &github.Issue{
+		ID:        github.Int64(int64(i.ID)),
+		NodeID:    github.String(nodeID("Issue", i.ID)),
+		Number:    github.Int(i.Number),
+		Title:     github.String(i.Title),
+		Body:      github.String(i.Body),
+		User:      s.user(i.Author),
+		State:     state(i.Closed),
+		Comments:  github.Int(len(i.Comments)),
+		CreatedAt: &i.CreatedAt,
+		UpdatedAt: &i.UpdatedAt,
+		ClosedAt:  closedAt(i.Closed, i.CreatedAt),
+		URL:       s.apiURL("/repos/%s/%s/issues/%d", r.Owner, r.Name, i.Number),
+		HTMLURL:   github.String(fmt.Sprintf("%s/%s/%s/issues/%d", githubURL, r.Owner, r.Name, i.Number)),
+	}
+}
+
+// restPullRequestIssue returns the issue representation of a PR, as returned
+// by the issues endpoints
+func (s *Server) restPullRequestIssue(r *Repository, pr *PullRequest) *github.Issue {
+	return &github.Issue{
+		ID:        github.Int64(int64(pr.ID)),
+		NodeID:    github.String(nodeID("Issue", pr.ID)),
+		Number:    github.Int(pr.Number),
+		Title:     github.String(pr.Title),
+		Body:      github.String(pr.Body),
+		User:      s.user(pr.Author),
+		State:     state(pr.Closed),
+		Comments:  github.Int(len(pr.Comments)),
+		CreatedAt: &pr.CreatedAt,
+		UpdatedAt: &pr.UpdatedAt,
+		ClosedAt:  closedAt(pr.Closed, pr.CreatedAt),
+		URL:       s.apiURL("/repos/%s/%s/issues/%d", r.Owner, r.Name, pr.Number),
+		HTMLURL:   github.String(fmt.Sprintf("%s/%s/%s/pull/%d", githubURL, r.Owner, r.Name, pr.Number)),
+		PullRequestLinks: &github.PullRequestLinks{
+			URL:     s.apiURL("/repos/%s/%s/pulls/%d", r.Owner, r.Name, pr.Number),
+			HTMLURL: github.String(fmt.Sprintf("%s/%s/%s/pull/%d", githubURL, r.Owner, r.Name, pr.Number)),
+		},
+	}
+}
+
+func (s *Server) restPullRequest(r *Repository, pr *PullRequest) *github.PullRequest {
+	var mergedAt *time.Time
+	if pr.Merged {
+		mergedAt = closedAt(pr.Closed, pr.CreatedAt)
+	}
+
+	return &github.PullRequest{
+		ID:        github.Int64(int64(pr.ID)),
+		NodeID:    github.String(nodeID("PullRequest", pr.ID)),
+		Number:    github.Int(pr.Number),
+		Title:     github.String(pr.Title),
+		Body:      github.String(pr.Body),
+		User:      s.user(pr.Author),
+		State:     state(pr.Closed),
+		Merged:    github.Bool(pr.Merged),
+		Comments:  github.Int(len(pr.Comments)),
+		CreatedAt: &pr.CreatedAt,
+		UpdatedAt: &pr.UpdatedAt,
+		ClosedAt:  closedAt(pr.Closed, pr.CreatedAt),
+		MergedAt:  mergedAt,
+		Base:      &github.PullRequestBranch{Ref: github.String(pr.BaseRef)},
+		Head:      &github.PullRequestBranch{Ref: github.String(pr.HeadRef)},
+		URL:       s.apiURL("/repos/%s/%s/pulls/%d", r.Owner, r.Name, pr.Number),
+		IssueURL:  s.apiURL("/repos/%s/%s/issues/%d", r.Owner, r.Name, pr.Number),
+		HTMLURL:   github.String(fmt.Sprintf("%s/%s/%s/pull/%d", githubURL, r.Owner, r.Name, pr.Number)),
+	}
+}
+
+func (s *Server) restComment(r *Repository, number int, c *Comment) *github.IssueComment {
+	return &github.IssueComment{
+		ID:        github.Int64(int64(c.ID)),
+		Body:      github.String(c.Body),
+		User:      s.user(c.Author),
+		CreatedAt: &c.CreatedAt,
+		UpdatedAt: &c.UpdatedAt,
+		URL:       s.apiURL("/repos/%s/%s/issues/comments/%d", r.Owner, r.Name, c.ID),
+		IssueURL:  s.apiURL("/repos/%s/%s/issues/%d", r.Owner, r.Name, number),
+	}
+}
+
+func (s *Server) restReview(r *Repository, number int, review *Review) *github.PullRequestReview {
+	return &github.PullRequestReview{
+		ID:             github.Int64(int64(review.ID)),
+		User:           s.user(review.Author),
+		Body:           github.String(review.Body),
+		State:          github.String(review.State),
+		SubmittedAt:    &review.SubmittedAt,
+		PullRequestURL: s.apiURL("/repos/%s/%s/pulls/%d", r.Owner, r.Name, number),
+	}
+}
+
+func (s *Server) restReviewComment(r *Repository, number int, review *Review, c *ReviewComment) *github.PullRequestComment {
+	return &github.PullRequestComment{
+		ID:                  github.Int64(int64(c.ID)),
+		PullRequestReviewID: github.Int64(int64(review.ID)),
+		Body:                github.String(c.Body),
+		Path:                github.String(c.Path),
+		Position:            github.Int(c.Position),
+		User:                s.user(c.Author),
+		CreatedAt:           &c.CreatedAt,
+		UpdatedAt:           &c.UpdatedAt,
+		URL:                 s.apiURL("/repos/%s/%s/pulls/comments/%d", r.Owner, r.Name, c.ID),
+		PullRequestURL:      s.apiURL("/repos/%s/%s/pulls/%d", r.Owner, r.Name, number),
+	}
+}
+
+func matchesState(closed bool, state string) bool {
+	switch state {
+	case "all":
+		return true
+	case "closed":
+		return closed
+	default:
+		return !closed
+	}
+}
+
+func (s *Server) serveIssues(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	state := r.URL.Query().Get("state")
+
+	// the issues endpoint includes PRs, by default sorted by creation date,
+	// newest first
+	var sorted []sortedItem
+	for _, pr := range repo.PullRequests {
+		if matchesState(pr.Closed, state) {
+			sorted = append(sorted, sortedItem{pr.CreatedAt, pr.UpdatedAt, s.restPullRequestIssue(repo, pr)})
+		}
+	}
+	for _, issue := range repo.Issues {
+		if matchesState(issue.Closed, state) {
+			sorted = append(sorted, sortedItem{issue.CreatedAt, issue.UpdatedAt, s.restIssue(repo, issue)})
+		}
+	}
+
+	paginate(w, r, sortItems(r, sorted, "desc"))
+}
+
+func (s *Server) serveIssue(w http.ResponseWriter, repo *Repository, number int) {
+	if i := repo.issue(number); i != nil {
+		writeJSON(w, http.StatusOK, s.restIssue(repo, i))
+		return
+	}
+
+	if pr := repo.pullRequest(number); pr != nil {
+		writeJSON(w, http.StatusOK, s.restPullRequestIssue(repo, pr))
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) serveIssueComments(w http.ResponseWriter, r *http.Request, repo *Repository, number int) {
+	var comments []*Comment
+	if i := repo.issue(number); i != nil {
+		comments = i.Comments
+	} else if pr := repo.pullRequest(number); pr != nil {
+		comments = pr.Comments
+	} else {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	items := []interface{}{}
+	for _, c := range comments {
+		items = append(items, s.restComment(repo, number, c))
+	}
+
+	paginate(w, r, items)
+}
+
+// sortedItem is a response item with the times used to filter and sort the
+// listings
+type sortedItem struct {
+	created time.Time
+	updated time.Time
+	item    interface{}
+}
+
+// sortItems returns the items updated at or after the since parameter,
+// sorted by the sort (created or updated) and direction parameters. The
+// direction is defaultDirection if it is not set.
+func sortItems(r *http.Request, sorted []sortedItem, defaultDirection string) []interface{} {
+	query := r.URL.Query()
+
+	var since time.Time
+	if v := query.Get("since"); v != "" {
+		since, _ = time.Parse(time.RFC3339, v)
+	}
+
+	byUpdated := query.Get("sort") == "updated"
+	direction := query.Get("direction")
+	if direction == "" {
+		direction = defaultDirection
+	}
+
+	key := func(i sortedItem) time.Time {
+		if byUpdated {
+			return i.updated
+		}
+		return i.created
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if direction == "desc" {
+			return key(sorted[i]).After(key(sorted[j]))
+		}
+		return key(sorted[i]).Before(key(sorted[j]))
+	})
+
+	items := []interface{}{}
+	for _, s := range sorted {
+		if s.updated.Before(since) {
+			continue
+		}
+		items = append(items, s.item)
+	}
+
+	return items
+}
+
+func (s *Server) serveRepositoryIssueComments(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	var sorted []sortedItem
+	for _, i := range repo.Issues {
+		for _, c := range i.Comments {
+			sorted = append(sorted, sortedItem{c.CreatedAt, c.UpdatedAt, s.restComment(repo, i.Number, c)})
+		}
+	}
+	for _, pr := range repo.PullRequests {
+		for _, c := range pr.Comments {
+			sorted = append(sorted, sortedItem{c.CreatedAt, c.UpdatedAt, s.restComment(repo, pr.Number, c)})
+		}
+	}
+
+	paginate(w, r, sortItems(r, sorted, "asc"))
+}
+
+func (s *Server) serveRepositoryReviewComments(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	var sorted []sortedItem
+	for _, pr := range repo.PullRequests {
+		for _, review := range pr.Reviews {
+			for _, c := range review.Comments {
+				sorted = append(sorted, sortedItem{c.CreatedAt, c.UpdatedAt, s.restReviewComment(repo, pr.Number, review, c)})
+			}
+		}
+	}
+
+	paginate(w, r, sortItems(r, sorted, "asc"))
+}
+
+func (s *Server) servePullRequests(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	state := r.URL.Query().Get("state")
+
+	// sorted by creation date, newest first. The list endpoint does not
+	// include the fields that are expensive to compute, like merged or the
+	// number of comments
+	items := []interface{}{}
+	for i := len(repo.PullRequests) - 1; i >= 0; i-- {
+		if pr := repo.PullRequests[i]; matchesState(pr.Closed, state) {
+			item := s.restPullRequest(repo, pr)
+			item.Merged = nil
+			item.Comments = nil
+			items = append(items, item)
+		}
+	}
+
+	paginate(w, r, items)
+}
+
+func (s *Server) servePullRequest(w http.ResponseWriter, repo *Repository, number int) {
+	pr := repo.pullRequest(number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.restPullRequest(repo, pr))
+}
+
+func (s *Server) serveReviews(w http.ResponseWriter, r *http.Request, repo *Repository, number int) {
+	pr := repo.pullRequest(number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	items := []interface{}{}
+	for _, review := range pr.Reviews {
+		items = append(items, s.restReview(repo, number, review))
+	}
+
+	paginate(w, r, items)
+}
+
+func (s *Server) serveReviewComments(w http.ResponseWriter, r *http.Request, repo *Repository, number int) {
+	pr := repo.pullRequest(number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	items := []interface{}{}
+	for _, review := range pr.Reviews {
+		for _, c := range review.Comments {
+			items = append(items, s.restReviewComment(repo, number, review, c))
+		}
+	}
+
+	paginate(w, r, items)
+}
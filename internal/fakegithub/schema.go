@@ -0,0 +1,531 @@
+package fakegithub
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// This file maps the synthetic data to the GraphQL objects in
+// https://developer.github.com/v4/object/
+
+const githubURL = "https://github.com"
+
+// nodeID returns a global node ID with the same format used by GitHub
+func nodeID(typename string, id interface{}) string {
+	return base64.StdEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("0%d:%s%v", len(typename), typename, id)))
+}
+
+func gqlTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// schema builds the GraphQL objects for the repositories of a Server. The
+// nodes are indexed by ID as they are created, so they can be queried with
+// node(id:).
+type schema struct {
+	s     *Server
+	nodes map[string]*object
+}
+
+func newSchema(s *Server) *schema {
+	return &schema{s: s, nodes: make(map[string]*object)}
+}
+
+func (sc *schema) node(o *object) *object {
+	if id, ok := o.fields["id"].(string); ok {
+		sc.nodes[id] = o
+	}
+
+	return o
+}
+
+func (sc *schema) query() *object {
+	return &object{
+		typename: "Query",
+		fields: map[string]interface{}{
+			"rateLimit": sc.rateLimit(),
+			"repository": resolver(func(args map[string]interface{}) (interface{}, error) {
+				owner, _ := args["owner"].(string)
+				name, _ := args["name"].(string)
+
+				r := sc.s.repository(owner, name)
+				if r == nil {
+					return nil, &notFoundError{fmt.Sprintf(
+						"Could not resolve to a Repository with the name '%s'.", name)}
+				}
+
+				return sc.repository(r), nil
+			}),
+			"node": resolver(func(args map[string]interface{}) (interface{}, error) {
+				id, _ := args["id"].(string)
+				sc.buildAll()
+
+				n, ok := sc.nodes[id]
+				if !ok {
+					return nil, &notFoundError{fmt.Sprintf(
+						"Could not resolve to a node with the global id of '%s'", id)}
+				}
+
+				return n, nil
+			}),
+			// nodes returns null for the IDs that do not exist
+			"nodes": resolver(func(args map[string]interface{}) (interface{}, error) {
+				ids, _ := args["ids"].([]interface{})
+				sc.buildAll()
+
+				nodes := make([]*object, len(ids))
+				for i, id := range ids {
+					s, _ := id.(string)
+					nodes[i] = sc.nodes[s]
+				}
+
+				return nodes, nil
+			}),
+		},
+	}
+}
+
+// buildAll creates the objects for all the repositories, indexing them by ID
+func (sc *schema) buildAll() {
+	for _, r := range sc.s.repositories() {
+		o := sc.repository(r)
+
+		for _, name := range []string{"issues", "pullRequests"} {
+			c, _ := o.fields[name].(resolver)(nil)
+			for _, n := range c.(*object).fields["nodes"].([]*object) {
+				sc.walk(n)
+			}
+		}
+	}
+}
+
+// walk resolves all the connections of o, so their nodes get indexed
+func (sc *schema) walk(o *object) {
+	for _, v := range o.fields {
+		r, ok := v.(resolver)
+		if !ok {
+			continue
+		}
+
+		c, err := r(nil)
+		if err != nil {
+			continue
+		}
+
+		conn, ok := c.(*object)
+		if !ok || conn == nil {
+			continue
+		}
+
+		if nodes, ok := conn.fields["nodes"].([]*object); ok {
+			for _, n := range nodes {
+				sc.walk(n)
+			}
+		}
+	}
+}
+
+func (sc *schema) rateLimit() *object {
+	remaining, resetAt := sc.s.rateLimit()
+
+	return &object{
+		typename: "RateLimit",
+		fields: map[string]interface{}{
+			"cost":      1,
+			"limit":     rateLimit,
+			"nodeCount": 0,
+			"remaining": remaining,
+			"resetAt":   gqlTime(resetAt),
+		},
+	}
+}
+
+func (sc *schema) actor(login string) *object {
+	if login == "" {
+		return nil
+	}
+
+	id := accountID(login)
+
+	if strings.HasSuffix(login, botSuffix) {
+		return sc.node(&object{
+			typename:   "Bot",
+			interfaces: []string{"Actor", "Node"},
+			fields: map[string]interface{}{
+				"id":           nodeID("Bot", id),
+				"databaseId":   id,
+				"login":        strings.TrimSuffix(login, botSuffix),
+				"createdAt":    gqlTime(epoch),
+				"resourcePath": "/apps/" + strings.TrimSuffix(login, botSuffix),
+				"url":          githubURL + "/apps/" + strings.TrimSuffix(login, botSuffix),
+				"avatarUrl":    fmt.Sprintf("https://avatars.githubusercontent.com/in/%d", id),
+			},
+		})
+	}
+
+	return sc.node(&object{
+		typename:   "User",
+		interfaces: []string{"Actor", "Node"},
+		fields: map[string]interface{}{
+			"id":           nodeID("User", id),
+			"databaseId":   id,
+			"login":        login,
+			"name":         strings.Title(login),
+			"company":      "",
+			"location":     "",
+			"email":        "",
+			"createdAt":    gqlTime(epoch),
+			"resourcePath": "/" + login,
+			"url":          githubURL + "/" + login,
+			"avatarUrl":    fmt.Sprintf("https://avatars.githubusercontent.com/u/%d", id),
+		},
+	})
+}
+
+// organization is the owner of the repositories
+func (sc *schema) organization(login string) *object {
+	id := accountID(login)
+
+	return sc.node(&object{
+		typename:   "Organization",
+		interfaces: []string{"Actor", "RepositoryOwner", "Node"},
+		fields: map[string]interface{}{
+			"id":           nodeID("Organization", id),
+			"databaseId":   id,
+			"login":        login,
+			"name":         login,
+			"description":  fmt.Sprintf("Synthetic organization %s", login),
+			"createdAt":    gqlTime(epoch),
+			"resourcePath": "/" + login,
+			"url":          githubURL + "/" + login,
+		},
+	})
+}
+
+func (sc *schema) repository(r *Repository) *object {
+	path := fmt.Sprintf("/%s/%s", r.Owner, r.Name)
+
+	return sc.node(&object{
+		typename:   "Repository",
+		interfaces: []string{"Node", "RepositoryInfo"},
+		fields: map[string]interface{}{
+			"id":               nodeID("Repository", r.ID),
+			"databaseId":       r.ID,
+			"createdAt":        gqlTime(r.CreatedAt),
+			"updatedAt":        gqlTime(r.CreatedAt),
+			"pushedAt":         gqlTime(r.CreatedAt),
+			"description":      fmt.Sprintf("Synthetic repository %s/%s", r.Owner, r.Name),
+			"hasIssuesEnabled": true,
+			"hasWikiEnabled":   true,
+			"name":             r.Name,
+			"nameWithOwner":    r.Owner + "/" + r.Name,
+			"owner":            sc.organization(r.Owner),
+			"resourcePath":     path,
+			"url":              githubURL + path,
+			"issues": connection("IssueConnection", func() []*object {
+				var nodes []*object
+				for _, i := range r.Issues {
+					nodes = append(nodes, sc.issue(r, i))
+				}
+				return nodes
+			}),
+			"pullRequests": connection("PullRequestConnection", func() []*object {
+				var nodes []*object
+				for _, pr := range r.PullRequests {
+					nodes = append(nodes, sc.pullRequest(r, pr))
+				}
+				return nodes
+			}),
+			"issue": resolver(func(args map[string]interface{}) (interface{}, error) {
+				number := intArg(args, "number")
+				i := r.issue(number)
+				if i == nil {
+					return nil, &notFoundError{fmt.Sprintf(
+						"Could not resolve to an Issue with the number of %d.", number)}
+				}
+
+				return sc.issue(r, i), nil
+			}),
+			"pullRequest": resolver(func(args map[string]interface{}) (interface{}, error) {
+				number := intArg(args, "number")
+				pr := r.pullRequest(number)
+				if pr == nil {
+					return nil, &notFoundError{fmt.Sprintf(
+						"Could not resolve to a PullRequest with the number of %d.", number)}
+				}
+
+				return sc.pullRequest(r, pr), nil
+			}),
+		},
+	})
+}
+
+func (sc *schema) issue(r *Repository, i *Issue) *object {
+	path := fmt.Sprintf("/%s/%s/issues/%d", r.Owner, r.Name, i.Number)
+
+	state := "OPEN"
+	var closedAt time.Time
+	if i.Closed {
+		state = "CLOSED"
+		closedAt = i.CreatedAt.Add(time.Hour)
+	}
+
+	return sc.reactable(i.Reactions, &object{
+		typename:   "Issue",
+		interfaces: []string{"Node", "Comment", "Reactable", "Closable"},
+		fields: map[string]interface{}{
+			"id":           nodeID("Issue", i.ID),
+			"databaseId":   i.ID,
+			"author":       sc.actor(i.Author),
+			"body":         i.Body,
+			"closed":       i.Closed,
+			"closedAt":     gqlTime(closedAt),
+			"createdAt":    gqlTime(i.CreatedAt),
+			"publishedAt":  gqlTime(i.CreatedAt),
+			"updatedAt":    gqlTime(i.UpdatedAt),
+			"number":       i.Number,
+			"resourcePath": path,
+			"state":        state,
+			"title":        i.Title,
+			"url":          githubURL + path,
+			"comments": connection("IssueCommentConnection", func() []*object {
+				return sc.comments(path, i.Comments)
+			}),
+		},
+	})
+}
+
+func (sc *schema) comments(parentPath string, comments []*Comment) []*object {
+	var nodes []*object
+	for _, c := range comments {
+		path := fmt.Sprintf("%s#issuecomment-%d", parentPath, c.ID)
+
+		nodes = append(nodes, sc.reactable(c.Reactions, &object{
+			typename:   "IssueComment",
+			interfaces: []string{"Node", "Comment", "Reactable"},
+			fields: map[string]interface{}{
+				"id":           nodeID("IssueComment", c.ID),
+				"databaseId":   c.ID,
+				"author":       sc.actor(c.Author),
+				"body":         c.Body,
+				"createdAt":    gqlTime(c.CreatedAt),
+				"publishedAt":  gqlTime(c.CreatedAt),
+				"updatedAt":    gqlTime(c.UpdatedAt),
+				"resourcePath": path,
+				"url":          githubURL + path,
+			},
+		}))
+	}
+
+	return nodes
+}
+
+func (sc *schema) pullRequest(r *Repository, pr *PullRequest) *object {
+	path := fmt.Sprintf("/%s/%s/pull/%d", r.Owner, r.Name, pr.Number)
+
+	state := "OPEN"
+	var closedAt, mergedAt time.Time
+	if pr.Closed {
+		state = "CLOSED"
+		closedAt = pr.CreatedAt.Add(time.Hour)
+	}
+	if pr.Merged {
+		state = "MERGED"
+		mergedAt = closedAt
+	}
+
+	ref := func(name string) *object {
+		return &object{
+			typename: "Ref",
+			fields: map[string]interface{}{
+				"id":     nodeID("Ref", name),
+				"name":   name,
+				"prefix": "refs/heads/",
+			},
+		}
+	}
+
+	return sc.reactable(pr.Reactions, &object{
+		typename:   "PullRequest",
+		interfaces: []string{"Node", "Comment", "Reactable", "Closable"},
+		fields: map[string]interface{}{
+			"id":           nodeID("PullRequest", pr.ID),
+			"databaseId":   pr.ID,
+			"author":       sc.actor(pr.Author),
+			"baseRef":      ref(pr.BaseRef),
+			"baseRefName":  pr.BaseRef,
+			"body":         pr.Body,
+			"closed":       pr.Closed,
+			"closedAt":     gqlTime(closedAt),
+			"createdAt":    gqlTime(pr.CreatedAt),
+			"publishedAt":  gqlTime(pr.CreatedAt),
+			"updatedAt":    gqlTime(pr.UpdatedAt),
+			"headRef":      ref(pr.HeadRef),
+			"headRefName":  pr.HeadRef,
+			"mergeable":    "UNKNOWN",
+			"merged":       pr.Merged,
+			"mergedAt":     gqlTime(mergedAt),
+			"number":       pr.Number,
+			"permalink":    githubURL + path,
+			"resourcePath": path,
+			"state":        state,
+			"title":        pr.Title,
+			"url":          githubURL + path,
+			"comments": connection("IssueCommentConnection", func() []*object {
+				return sc.comments(path, pr.Comments)
+			}),
+			"reviews": connection("PullRequestReviewConnection", func() []*object {
+				var nodes []*object
+				for _, review := range pr.Reviews {
+					nodes = append(nodes, sc.review(path, review))
+				}
+				return nodes
+			}),
+			"reviewThreads": connection("PullRequestReviewThreadConnection", func() []*object {
+				var nodes []*object
+				for _, thread := range pr.Threads {
+					nodes = append(nodes, sc.reviewThread(path, thread))
+				}
+				return nodes
+			}),
+		},
+	})
+}
+
+// reviewThread returns a PullRequestReviewThread, the line of outdated
+// threads is null and resolvedBy is null for unresolved threads
+func (sc *schema) reviewThread(prPath string, thread *ReviewThread) *object {
+	var line, resolvedBy interface{}
+	if !thread.Outdated {
+		line = thread.Line
+	}
+	if thread.ResolvedBy != "" {
+		resolvedBy = sc.actor(thread.ResolvedBy)
+	}
+
+	return sc.node(&object{
+		typename:   "PullRequestReviewThread",
+		interfaces: []string{"Node"},
+		fields: map[string]interface{}{
+			"id":           nodeID("PullRequestReviewThread", thread.ID),
+			"path":         thread.Path,
+			"line":         line,
+			"originalLine": thread.Line,
+			"diffSide":     "RIGHT",
+			"isCollapsed":  thread.ResolvedBy != "",
+			"isOutdated":   thread.Outdated,
+			"isResolved":   thread.ResolvedBy != "",
+			"resolvedBy":   resolvedBy,
+			"comments": connection("PullRequestReviewCommentConnection", func() []*object {
+				var nodes []*object
+				for _, c := range thread.Comments {
+					nodes = append(nodes, sc.reviewComment(prPath, c))
+				}
+				return nodes
+			}),
+		},
+	})
+}
+
+func (sc *schema) review(prPath string, review *Review) *object {
+	path := fmt.Sprintf("%s#pullrequestreview-%d", prPath, review.ID)
+
+	return sc.reactable(review.Reactions, &object{
+		typename:   "PullRequestReview",
+		interfaces: []string{"Node", "Comment", "Reactable"},
+		fields: map[string]interface{}{
+			"id":           nodeID("PullRequestReview", review.ID),
+			"databaseId":   review.ID,
+			"author":       sc.actor(review.Author),
+			"body":         review.Body,
+			"createdAt":    gqlTime(review.SubmittedAt),
+			"publishedAt":  gqlTime(review.SubmittedAt),
+			"submittedAt":  gqlTime(review.SubmittedAt),
+			"updatedAt":    gqlTime(review.SubmittedAt),
+			"resourcePath": path,
+			"state":        review.State,
+			"url":          githubURL + path,
+			"comments": connection("PullRequestReviewCommentConnection", func() []*object {
+				var nodes []*object
+				for _, c := range review.Comments {
+					nodes = append(nodes, sc.reviewComment(prPath, c))
+				}
+				return nodes
+			}),
+		},
+	})
+}
+
+func (sc *schema) reviewComment(prPath string, c *ReviewComment) *object {
+	path := fmt.Sprintf("%s#discussion_r%d", strings.TrimSuffix(prPath, "/"), c.ID)
+
+	return sc.reactable(c.Reactions, &object{
+		typename:   "PullRequestReviewComment",
+		interfaces: []string{"Node", "Comment", "Reactable"},
+		fields: map[string]interface{}{
+			"id":           nodeID("PullRequestReviewComment", c.ID),
+			"databaseId":   c.ID,
+			"author":       sc.actor(c.Author),
+			"body":         c.Body,
+			"createdAt":    gqlTime(c.CreatedAt),
+			"publishedAt":  gqlTime(c.CreatedAt),
+			"updatedAt":    gqlTime(c.UpdatedAt),
+			"path":         c.Path,
+			"position":     c.Position,
+			"resourcePath": path,
+			"url":          githubURL + path,
+		},
+	})
+}
+
+// reactable adds the reactionGroups and reactions fields of the Reactable
+// interface to o, and indexes it as a node
+func (sc *schema) reactable(reactions []*Reaction, o *object) *object {
+	var groups []*object
+	for _, content := range allReactionContents {
+		count := 0
+		for _, r := range reactions {
+			if r.Content == content {
+				count++
+			}
+		}
+
+		groups = append(groups, &object{
+			typename: "ReactionGroup",
+			fields: map[string]interface{}{
+				"content": content,
+				"users": &object{
+					typename: "ReactingUserConnection",
+					fields:   map[string]interface{}{"totalCount": count},
+				},
+			},
+		})
+	}
+
+	o.fields["reactionGroups"] = groups
+	o.fields["reactions"] = connection("ReactionConnection", func() []*object {
+		var nodes []*object
+		for _, r := range reactions {
+			nodes = append(nodes, &object{
+				typename:   "Reaction",
+				interfaces: []string{"Node"},
+				fields: map[string]interface{}{
+					"id":         nodeID("Reaction", r.ID),
+					"databaseId": r.ID,
+					"content":    r.Content,
+					"user":       sc.actor(r.User),
+				},
+			})
+		}
+		return nodes
+	})
+
+	return sc.node(o)
+}
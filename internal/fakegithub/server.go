@@ -0,0 +1,233 @@
+// Package fakegithub implements an in-process fake of the GitHub API, serving
+// synthetic repositories through the subset of the GraphQL schema and the REST
+// endpoints used by the downloaders.
+package fakegithub
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rateLimit = 5000
+
+// Server is a fake GitHub API. The REST API is served at URL, and the
+// GraphQL API at GraphQLURL.
+type Server struct {
+	*httptest.Server
+
+	m          sync.Mutex
+	repos      []*Repository
+	remaining  int
+	resetAt    time.Time
+	requests   []string
+	migrations map[int]*migration
+	date       time.Time
+
+	// ExportPolls is the number of status requests a migration is exporting
+	// before it is exported
+	ExportPolls int
+	// ArchiveURLExpiry is how long the archive URLs are valid, like the
+	// signed S3 URLs. Zero means they do not expire.
+	ArchiveURLExpiry time.Duration
+	// InterruptArchives is the number of archive downloads that are cut
+	// after sending half of the requested bytes
+	InterruptArchives int
+}
+
+// NewServer starts a fake GitHub API serving the given repositories. Close
+// must be called when done.
+func NewServer(repos ...*Repository) *Server {
+	s := &Server{
+		repos:     repos,
+		remaining: rateLimit,
+		resetAt:   time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// SetDate sets the time of the Date header of the responses, instead of the
+// current time
+func (s *Server) SetDate(t time.Time) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.date = t
+}
+
+// GraphQLURL returns the URL of the GraphQL endpoint
+func (s *Server) GraphQLURL() string {
+	return s.URL + "/graphql"
+}
+
+// AddRepository adds a repository to the ones served
+func (s *Server) AddRepository(r *Repository) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.repos = append(s.repos, r)
+}
+
+// Requests returns the method and path of all the requests received, except
+// the ones to query the rate limit
+func (s *Server) Requests() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) repository(owner, name string) *Repository {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, r := range s.repos {
+		if strings.EqualFold(r.Owner, owner) && strings.EqualFold(r.Name, name) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) repositories() []*Repository {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]*Repository(nil), s.repos...)
+}
+
+func (s *Server) rateLimit() (int, time.Time) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.remaining, s.resetAt
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if r.URL.Path == "/graphql" {
+		s.countRequest(w, r, true)
+		s.serveGraphQL(w, r)
+		return
+	}
+
+	// the archives are not JSON, and the migrations are not conditional
+	if strings.HasPrefix(r.URL.Path, "/archives/") {
+		s.countRequest(w, r, false)
+		s.serveArchive(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) >= 3 && parts[0] == "orgs" && parts[2] == "migrations" {
+		s.countRequest(w, r, true)
+		s.serveMigrations(w, r, parts[1], parts[3:])
+		return
+	}
+
+	// the REST responses have an ETag, and conditional requests that are not
+	// modified get a 304 that does not count against the rate limit
+	rec := httptest.NewRecorder()
+	s.serveREST(rec, r)
+
+	etag := fmt.Sprintf(`W/"%x"`, sha1.Sum(rec.Body.Bytes()))
+	notModified := rec.Code == http.StatusOK && r.Header.Get("If-None-Match") == etag
+
+	s.countRequest(w, r, !notModified)
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+
+	if rec.Code == http.StatusOK {
+		w.Header().Set("ETag", etag)
+	}
+
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(rec.Code)
+	w.Write(rec.Body.Bytes())
+}
+
+// countRequest records the request and sets the rate limit headers. The
+// remaining rate limit is decreased if charge is true.
+func (s *Server) countRequest(w http.ResponseWriter, r *http.Request, charge bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if r.URL.Path != "/rate_limit" {
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		if charge && s.remaining > 0 {
+			s.remaining--
+		}
+	}
+	w.Header().Set("X-RateLimit-Limit", fmt.Sprint(rateLimit))
+	w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(s.remaining))
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprint(s.resetAt.Unix()))
+	if !s.date.IsZero() {
+		w.Header().Set("Date", s.date.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (s *Server) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "GraphQL queries must use POST")
+		return
+	}
+
+	var req struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	selections, err := parseQuery(req.Query)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"errors": []interface{}{map[string]interface{}{"message": err.Error()}},
+		})
+		return
+	}
+
+	data, err := execute(newSchema(s).query(), selections, req.Variables)
+	if err != nil {
+		gqlErr := map[string]interface{}{"message": err.Error()}
+		if _, ok := err.(*notFoundError); ok {
+			gqlErr["type"] = "NOT_FOUND"
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data":   nil,
+			"errors": []interface{}{gqlErr},
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{
+		"message":           msg,
+		"documentation_url": "https://developer.github.com/v3",
+	})
+}
@@ -0,0 +1,153 @@
+// Package metrics defines the Prometheus metrics of the downloaders. The
+// metrics are registered in Registry, which can be served with Serve while a
+// run is in progress, or sent to a Pushgateway with Push when it finishes.
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const namespace = "metadata"
+
+// Registry contains all the metrics defined in this package
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Requests counts the HTTP requests to the GitHub API, each retry is
+	// counted as a new request
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests to the GitHub API, by API and status code.",
+	}, []string{"api", "code"})
+
+	// RequestDuration observes the duration of the HTTP requests
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the HTTP requests to the GitHub API, by API.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"api"})
+
+	// GraphQLCost counts the rate limit points consumed by GraphQL queries
+	GraphQLCost = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_cost_total",
+		Help:      "Rate limit points consumed by the GraphQL queries.",
+	})
+
+	// RateLimitRemaining is the last X-RateLimit-Remaining value received
+	RateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_remaining",
+		Help:      "Remaining rate limit reported by the last response, by API.",
+	}, []string{"api"})
+
+	// Retries counts the retried requests
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_retries_total",
+		Help:      "Retried HTTP requests, by kind of failure.",
+	}, []string{"reason"})
+
+	// EntitiesSaved counts the entities passed to the storers
+	EntitiesSaved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "entities_saved_total",
+		Help:      "Entities saved, by type.",
+	}, []string{"type"})
+
+	// SaveDuration observes how long the storers take to save each entity,
+	// for the DB storers it is the write latency
+	SaveDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "save_duration_seconds",
+		Help:      "Duration of the storer writes, by storer and entity type.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"storer", "type"})
+
+	// RunDuration is the duration of the last run
+	RunDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of the last run, by command.",
+	}, []string{"command"})
+
+	// RunSuccess is 1 if the last run finished without errors, 0 otherwise
+	RunSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "run_success",
+		Help:      "Whether the last run finished without errors, by command.",
+	}, []string{"command"})
+
+	// RunLastCompletion is the time the last run finished
+	RunLastCompletion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "run_last_completion_timestamp_seconds",
+		Help:      "Unix time of the last run completion, by command.",
+	}, []string{"command"})
+)
+
+func init() {
+	Registry.MustRegister(
+		Requests,
+		RequestDuration,
+		GraphQLCost,
+		RateLimitRemaining,
+		Retries,
+		EntitiesSaved,
+		SaveDuration,
+		RunDuration,
+		RunSuccess,
+		RunLastCompletion,
+	)
+}
+
+// Serve starts an HTTP server in the background exposing the metrics at
+// addr, under /metrics. The returned server must be closed when done.
+func Serve(addr string) (*http.Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+
+	return srv, nil
+}
+
+// Push sends the metrics to the Pushgateway at url, replacing the ones
+// previously pushed for the same job
+func Push(url, job string) error {
+	return push.New(url, job).Gatherer(Registry).Push()
+}
+
+// ObserveSave records an entity saved by a storer, started at t0
+func ObserveSave(storer, typ string, t0 time.Time, err error) {
+	SaveDuration.WithLabelValues(storer, typ).Observe(time.Since(t0).Seconds())
+	if err == nil {
+		EntitiesSaved.WithLabelValues(typ).Inc()
+	}
+}
+
+// ObserveRun records the duration and result of a command run, started at t0
+func ObserveRun(command string, t0 time.Time, err error) {
+	RunDuration.WithLabelValues(command).Set(time.Since(t0).Seconds())
+	RunLastCompletion.WithLabelValues(command).SetToCurrentTime()
+
+	success := 0.0
+	if err == nil {
+		success = 1
+	}
+	RunSuccess.WithLabelValues(command).Set(success)
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport records the request metrics of the HTTP requests done through it.
+// The GraphQL cost is computed from the X-RateLimit-Remaining difference
+// between consecutive GraphQL responses, the cost of the first query is not
+// known.
+type Transport struct {
+	T http.RoundTripper
+
+	m             sync.Mutex
+	lastRemaining int
+	lastReset     string
+}
+
+// NewTransport returns a new Transport wrapping t
+func NewTransport(t http.RoundTripper) *Transport {
+	return &Transport{T: t, lastRemaining: -1}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	api := "rest"
+	if strings.HasSuffix(req.URL.Path, "/graphql") {
+		api = "graphql"
+	}
+
+	t0 := time.Now()
+	resp, err := t.T.RoundTrip(req)
+	RequestDuration.WithLabelValues(api).Observe(time.Since(t0).Seconds())
+
+	if err != nil {
+		Requests.WithLabelValues(api, "error").Inc()
+		return resp, err
+	}
+
+	Requests.WithLabelValues(api, strconv.Itoa(resp.StatusCode)).Inc()
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return resp, nil
+	}
+
+	RateLimitRemaining.WithLabelValues(api).Set(float64(remaining))
+
+	if api == "graphql" {
+		t.observeCost(remaining, resp.Header.Get("X-RateLimit-Reset"))
+	}
+
+	return resp, nil
+}
+
+func (t *Transport) observeCost(remaining int, reset string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.lastRemaining >= 0 && reset == t.lastReset && remaining <= t.lastRemaining {
+		GraphQLCost.Add(float64(t.lastRemaining - remaining))
+	}
+
+	t.lastRemaining = remaining
+	t.lastReset = reset
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	require := require.New(t)
+
+	remaining := 5000
+	costs := []int{1, 3, 2}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			remaining -= costs[0]
+			costs = costs[1:]
+		}
+
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining))
+		w.Header().Set("X-RateLimit-Reset", "1567000000")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cost0 := testutil.ToFloat64(GraphQLCost)
+	graphql0 := testutil.ToFloat64(Requests.WithLabelValues("graphql", "200"))
+	notFound0 := testutil.ToFloat64(Requests.WithLabelValues("rest", "404"))
+
+	c := &http.Client{Transport: NewTransport(http.DefaultTransport)}
+	for _, path := range []string{"/graphql", "/missing", "/graphql", "/graphql"} {
+		resp, err := c.Post(srv.URL+path, "application/json", strings.NewReader("{}"))
+		require.NoError(err)
+		resp.Body.Close()
+	}
+
+	// the cost of the first query is unknown
+	require.Equal(5.0, testutil.ToFloat64(GraphQLCost)-cost0)
+	require.Equal(3.0, testutil.ToFloat64(Requests.WithLabelValues("graphql", "200"))-graphql0)
+	require.Equal(1.0, testutil.ToFloat64(Requests.WithLabelValues("rest", "404"))-notFound0)
+	require.Equal(4994.0, testutil.ToFloat64(RateLimitRemaining.WithLabelValues("graphql")))
+}
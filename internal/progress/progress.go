@@ -0,0 +1,292 @@
+// Package progress reports the progress of a download: the entities saved
+// out of the expected totals, the pages requested, the remaining rate limit
+// and the estimated time to finish.
+//
+// In a terminal the progress is drawn as a bar, otherwise it is logged
+// periodically. The Progress of a run travels in its context, so the
+// storers and transports can update it without knowing about each other.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// Entity types. The totals of all of them but the review comments are known
+// from the start, and are used to estimate the time left.
+const (
+	Issues             = "issue"
+	IssueComments      = "issue_comment"
+	PullRequests       = "pull_request"
+	PullRequestReviews = "pull_request_review"
+	ReviewComments     = "pull_request_review_comment"
+	ReviewThreads      = "pull_request_review_thread"
+)
+
+const (
+	barWidth            = 30
+	defaultDrawInterval = 200 * time.Millisecond
+	defaultLogInterval  = 10 * time.Second
+)
+
+// Progress keeps the counters of a run. All the methods are safe to use
+// concurrently, and on a nil *Progress, which does nothing.
+type Progress struct {
+	out    io.Writer
+	tty    bool
+	logger log.Logger
+
+	// interval is the minimum time between two reports
+	interval time.Duration
+
+	m          sync.Mutex
+	start      time.Time
+	lastReport time.Time
+	totals     map[string]int
+	done       map[string]int
+	pages      int
+	rate       int
+}
+
+// New returns a Progress reporting to out. If out is a terminal the progress
+// is drawn as a bar, otherwise it is logged with logger.
+func New(out *os.File, logger log.Logger) *Progress {
+	tty := isatty.IsTerminal(out.Fd()) || isatty.IsCygwinTerminal(out.Fd())
+
+	interval := defaultLogInterval
+	if tty {
+		interval = defaultDrawInterval
+	}
+
+	return newProgress(out, tty, logger, interval)
+}
+
+func newProgress(out io.Writer, tty bool, logger log.Logger, interval time.Duration) *Progress {
+	return &Progress{
+		out:      out,
+		tty:      tty,
+		logger:   logger,
+		interval: interval,
+		start:    time.Now(),
+		totals:   make(map[string]int),
+		done:     make(map[string]int),
+		rate:     -1,
+	}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying p
+func NewContext(ctx context.Context, p *Progress) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the Progress in ctx, or nil if there is none
+func FromContext(ctx context.Context) *Progress {
+	p, _ := ctx.Value(contextKey{}).(*Progress)
+	return p
+}
+
+// AddTotal adds n to the expected total of an entity type. The totals of
+// nested entities, like review comments, may be added as their parents are
+// fetched.
+func (p *Progress) AddTotal(typ string, n int) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.totals[typ] += n
+	p.report(false)
+}
+
+// Inc counts a saved entity
+func (p *Progress) Inc(typ string) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.done[typ]++
+	p.report(false)
+}
+
+// Page counts a requested page
+func (p *Progress) Page() {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.pages++
+	p.report(false)
+}
+
+// SetRateRemaining sets the remaining rate limit
+func (p *Progress) SetRateRemaining(n int) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.rate = n
+}
+
+// Finish reports the final state
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.report(true)
+	if p.tty {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// estimated are the entity types used to estimate the time left
+var estimated = []string{Issues, IssueComments, PullRequests, PullRequestReviews, ReviewThreads}
+
+// fraction returns the part of the estimated entities done, or -1 if the
+// totals are not known
+func (p *Progress) fraction() float64 {
+	var total, done int
+	for _, typ := range estimated {
+		total += p.totals[typ]
+		done += p.done[typ]
+	}
+
+	if total == 0 {
+		return -1
+	}
+
+	f := float64(done) / float64(total)
+	if f > 1 {
+		f = 1
+	}
+
+	return f
+}
+
+// eta returns the estimated time left, or -1 if it cannot be estimated yet
+func (p *Progress) eta() time.Duration {
+	f := p.fraction()
+	if f <= 0 {
+		return -1
+	}
+
+	elapsed := time.Since(p.start)
+	return time.Duration(float64(elapsed) * (1 - f) / f).Round(time.Second)
+}
+
+func (p *Progress) types() []string {
+	seen := make(map[string]bool)
+	for t := range p.totals {
+		seen[t] = true
+	}
+	for t := range p.done {
+		seen[t] = true
+	}
+
+	order := map[string]int{Issues: 0, IssueComments: 1, PullRequests: 2, PullRequestReviews: 3, ReviewComments: 4, ReviewThreads: 5}
+	var types []string
+	for t := range seen {
+		types = append(types, t)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		oi, ok := order[types[i]]
+		if !ok {
+			oi = len(order)
+		}
+		oj, ok := order[types[j]]
+		if !ok {
+			oj = len(order)
+		}
+		if oi != oj {
+			return oi < oj
+		}
+		return types[i] < types[j]
+	})
+
+	return types
+}
+
+func (p *Progress) report(force bool) {
+	now := time.Now()
+	if !force && now.Sub(p.lastReport) < p.interval {
+		return
+	}
+	p.lastReport = now
+
+	if p.tty {
+		p.draw()
+		return
+	}
+
+	if p.logger == nil {
+		return
+	}
+
+	fields := log.Fields{"pages": p.pages, "elapsed": time.Since(p.start).Round(time.Second)}
+	for _, t := range p.types() {
+		fields[t] = fmt.Sprintf("%d/%d", p.done[t], p.totals[t])
+	}
+	if p.rate >= 0 {
+		fields["rate-limit-remaining"] = p.rate
+	}
+	if eta := p.eta(); eta >= 0 {
+		fields["eta"] = eta
+	}
+
+	p.logger.With(fields).Infof("progress")
+}
+
+func (p *Progress) draw() {
+	var b strings.Builder
+
+	f := p.fraction()
+	if f < 0 {
+		f = 0
+	}
+	filled := int(f * barWidth)
+	fmt.Fprintf(&b, "\r[%s%s] %3.0f%%",
+		strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), f*100)
+
+	for _, t := range p.types() {
+		fmt.Fprintf(&b, " %s %d/%d", t, p.done[t], p.totals[t])
+	}
+
+	fmt.Fprintf(&b, " pages %d", p.pages)
+	if p.rate >= 0 {
+		fmt.Fprintf(&b, " rate %d", p.rate)
+	}
+	if eta := p.eta(); eta >= 0 {
+		fmt.Fprintf(&b, " ETA %v", eta)
+	}
+
+	// clear the rest of the line
+	b.WriteString("\x1b[K")
+
+	io.WriteString(p.out, b.String())
+}
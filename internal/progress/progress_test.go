@@ -0,0 +1,63 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressDraw(t *testing.T) {
+	require := require.New(t)
+
+	var out bytes.Buffer
+	p := newProgress(&out, true, nil, 0)
+	p.start = time.Now().Add(-10 * time.Second)
+
+	p.AddTotal(Issues, 3)
+	p.AddTotal(IssueComments, 4)
+	p.AddTotal(PullRequests, 1)
+	p.Page()
+	p.SetRateRemaining(4990)
+	p.Inc(Issues)
+	p.Inc(IssueComments)
+	p.Finish()
+
+	lines := strings.Split(out.String(), "\r")
+	last := lines[len(lines)-1]
+
+	require.Contains(last, " 25%")
+	require.Contains(last, "issue 1/3 issue_comment 1/4 pull_request 0/1")
+	require.Contains(last, "pages 1 rate 4990 ETA 30s")
+	require.True(strings.HasSuffix(last, "\n"))
+}
+
+func TestProgressUnknownTotals(t *testing.T) {
+	require := require.New(t)
+
+	p := newProgress(&bytes.Buffer{}, true, nil, 0)
+	require.Equal(-1.0, p.fraction())
+	require.Equal(time.Duration(-1), p.eta())
+
+	p.AddTotal(Issues, 1)
+	p.Inc(Issues)
+	p.Inc(Issues)
+	require.Equal(1.0, p.fraction())
+}
+
+func TestProgressContext(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(FromContext(context.Background()))
+
+	// a nil Progress does nothing
+	FromContext(context.Background()).Inc(Issues)
+
+	p := newProgress(&bytes.Buffer{}, false, nil, time.Hour)
+	ctx := NewContext(context.Background(), p)
+	FromContext(ctx).Inc(Issues)
+	require.Equal(1, p.done[Issues])
+}
@@ -0,0 +1,25 @@
+package progress
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Transport updates the remaining rate limit of the Progress in the request
+// context
+type Transport struct {
+	T http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.T.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		FromContext(req.Context()).SetRateRemaining(remaining)
+	}
+
+	return resp, nil
+}
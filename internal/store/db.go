@@ -0,0 +1,300 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Columns of the versioned tables, see the migrations in v4/db/migrations
+const (
+	OrganizationsCols             = "database_id, login, name, description, created_at"
+	UsersCols                     = "database_id, login, type, name, company, location, email, created_at"
+	RepositoriesCols              = "database_id, created_at, description, owner, name"
+	IssuesCols                    = "database_id, title, body, number, repository_owner, repository_name"
+	IssueCommentsCols             = "database_id, author, body, repository_owner, repository_name, issue_number"
+	PullRequestsCols              = "database_id, title, body, number, state, author, repository_owner, repository_name"
+	PullRequestReviewsCols        = "database_id, author, body, state, repository_owner, repository_name, pull_request_number"
+	PullRequestReviewCommentsCols = "database_id, author, body, path, pull_request_review_id, repository_owner, repository_name, pull_request_number"
+	PullRequestReviewThreadsCols  = "node_id, path, line, original_line, diff_side, is_resolved, is_outdated, resolved_by, comment_ids, repository_owner, repository_name, pull_request_number"
+	ReactionsCols                 = "subject_id, subject_type, content, count, users, repository_owner, repository_name, number"
+)
+
+// Columns that identify an entity in its versioned table, used to replace the
+// rows of a merged version, see MergeVersion. The other columns may change
+// between versions.
+const (
+	DatabaseIDKey   = "database_id"
+	ReviewThreadKey = "node_id"
+	ReactionKey     = "subject_id, subject_type, content"
+)
+
+// tables maps each view to its columns. The data is stored in the
+// <view>_versioned table. owner and name are the columns of the repository of
+// each row, empty for the entities that do not belong to a repository.
+var tables = []struct {
+	view        string
+	cols        string
+	owner, name string
+}{
+	{"organizations", OrganizationsCols, "", ""},
+	{"users", UsersCols, "", ""},
+	{"repositories", RepositoriesCols, "owner", "name"},
+	{"issues", IssuesCols, "repository_owner", "repository_name"},
+	{"issue_comments", IssueCommentsCols, "repository_owner", "repository_name"},
+	{"pull_requests", PullRequestsCols, "repository_owner", "repository_name"},
+	{"pull_request_reviews", PullRequestReviewsCols, "repository_owner", "repository_name"},
+	{"pull_request_review_comments", PullRequestReviewCommentsCols, "repository_owner", "repository_name"},
+	{"pull_request_review_threads", PullRequestReviewThreadsCols, "repository_owner", "repository_name"},
+	{"reactions", ReactionsCols, "repository_owner", "repository_name"},
+}
+
+// SetActiveVersion replaces the views of all the tables with the rows that
+// belong to version v
+func SetActiveVersion(db *sql.DB, v string) error {
+	// TODO: for some reason the normal parameter interpolation $1 fails with
+	// pq: got 1 parameters but the statement requires 0
+
+	for _, t := range tables {
+		_, err := db.Exec(fmt.Sprintf(`CREATE OR REPLACE VIEW %s AS
+	SELECT %s
+	FROM %s_versioned WHERE '%s' = ANY(versions)`, t.view, t.cols, t.view, v))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cleanup deletes from all the tables the rows that do not belong to
+// currentVersion
+func Cleanup(db *sql.DB, currentVersion string) error {
+	for _, t := range tables {
+		table := t.view + "_versioned"
+
+		// Delete all entries that do not belong to currentVersion
+		_, err := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE '%s' <> ALL(versions)`, table, currentVersion))
+		if err != nil {
+			return err
+		}
+
+		// All remaining entries belong to currentVersion, replace the list of versions
+		// with an array of 1 entry
+		_, err = db.Exec(fmt.Sprintf(`UPDATE %s SET versions = array['%s']`, table, currentVersion))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DBStorer writes the rows of the versioned tables of a PostgreSQL DB, and
+// keeps the state of the incremental downloads. The entities are mapped to
+// the rows by MetadataDBStorer. All the writes between Begin and Commit are
+// done in one transaction.
+type DBStorer struct {
+	db *sql.DB
+	tx *sql.Tx
+	v  string
+
+	// merged is set when the current version contains the entities of a
+	// previous one, see MergeVersion
+	merged bool
+}
+
+var _ SyncStorer = &DBStorer{}
+
+func NewDBStorer(db *sql.DB) *DBStorer {
+	return &DBStorer{db: db}
+}
+
+func (s *DBStorer) Begin() error {
+	var err error
+	s.tx, err = s.db.Begin()
+	return err
+}
+
+func (s *DBStorer) Commit() error {
+	return s.tx.Commit()
+}
+
+func (s *DBStorer) Rollback() error {
+	return s.tx.Rollback()
+}
+
+func (s *DBStorer) Version(v string) {
+	s.v = v
+	s.merged = false
+}
+
+func (s *DBStorer) SetActiveVersion(v string) error {
+	return SetActiveVersion(s.db, v)
+}
+
+func (s *DBStorer) Cleanup(currentVersion string) error {
+	return Cleanup(s.db, currentVersion)
+}
+
+func (s *DBStorer) LoadSyncState(ctx context.Context, owner, name string) (*SyncState, error) {
+	state := &SyncState{}
+	var endpoints []byte
+
+	err := s.tx.QueryRowContext(ctx,
+		`SELECT version, endpoints FROM sync_states
+		WHERE repository_owner = $1 AND repository_name = $2`,
+		owner, name).Scan(&state.Version, &endpoints)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the version may have been deleted by a cleanup after a full download
+	var exists bool
+	err = s.tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM repositories_versioned
+		WHERE owner = $1 AND name = $2 AND $3 = ANY(versions))`,
+		owner, name, state.Version).Scan(&exists)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(endpoints, &state.Endpoints); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (s *DBStorer) SaveSyncState(ctx context.Context, owner, name string, state *SyncState) error {
+	endpoints, err := json.Marshal(state.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.tx.ExecContext(ctx,
+		`INSERT INTO sync_states
+		(repository_owner, repository_name, version, endpoints)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (repository_owner, repository_name)
+		DO UPDATE
+		SET version = $3, endpoints = $4`,
+		owner, name, state.Version, endpoints)
+
+	return err
+}
+
+func (s *DBStorer) MergeVersion(ctx context.Context, owner, name, from string) error {
+	for _, t := range tables {
+		if t.owner == "" {
+			continue
+		}
+
+		_, err := s.tx.ExecContext(ctx, fmt.Sprintf(
+			`UPDATE %s_versioned
+			SET versions = array_append(versions, $1)
+			WHERE $2 = ANY(versions) AND NOT $1 = ANY(versions)
+			AND %s = $3 AND %s = $4`, t.view, t.owner, t.name),
+			s.v, from, owner, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.merged = true
+	return nil
+}
+
+// insert adds a row to the versioned table of view with the current version,
+// or appends the version to the row if it already exists. values must be
+// in the same order as cols, and key are the columns of cols that identify
+// the entity.
+func (s *DBStorer) insert(ctx context.Context, view, key, cols string, values ...interface{}) error {
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+	}
+
+	// after a merge the current version may have an older row of the same
+	// entity
+	if s.merged {
+		index := make(map[string]int)
+		for i, col := range strings.Split(cols, ", ") {
+			index[col] = i
+		}
+
+		keyCols := strings.Split(key, ", ")
+		keyPlaceholders := make([]string, len(keyCols))
+		keyValues := []interface{}{s.v}
+		for i, col := range keyCols {
+			j, ok := index[col]
+			if !ok {
+				return fmt.Errorf("key column %q is not one of the columns of %s", col, view)
+			}
+
+			keyPlaceholders[i] = fmt.Sprintf("$%d", i+2)
+			keyValues = append(keyValues, values[j])
+		}
+
+		_, err := s.tx.ExecContext(ctx, fmt.Sprintf(
+			`UPDATE %s_versioned
+			SET versions = array_remove(versions, $1)
+			WHERE (%s) = (%s)`, view, key, strings.Join(keyPlaceholders, ", ")),
+			keyValues...)
+		if err != nil {
+			return err
+		}
+	}
+
+	statement := fmt.Sprintf(
+		`INSERT INTO %s_versioned
+		(versions, %s)
+		VALUES (array[$1], %s)
+		ON CONFLICT (%s)
+		DO UPDATE
+		SET versions = array_append(%s_versioned.versions, $1)`,
+		view, cols, strings.Join(placeholders, ", "), cols, view)
+
+	_, err := s.tx.ExecContext(ctx, statement, append([]interface{}{s.v}, values...)...)
+	return err
+}
+
+// ParseAPIURL returns the repository owner, name and the issue or PR number
+// of a REST API URL like https://api.github.com/repos/owner/name/issues/1.
+// The go-github types for issues, PRs and their comments do not include the
+// repository, only these URLs. The host is ignored, so URLs of GitHub
+// Enterprise (/api/v3/repos/...) are also accepted.
+func ParseAPIURL(apiURL string) (owner, name string, number int, err error) {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, p := range parts {
+		if p != "repos" {
+			continue
+		}
+
+		rest := parts[i+1:]
+		if len(rest) != 4 || (rest[2] != "issues" && rest[2] != "pulls") {
+			break
+		}
+
+		number, err = strconv.Atoi(rest[3])
+		if err != nil {
+			break
+		}
+
+		return rest[0], rest[1], number, nil
+	}
+
+	return "", "", 0, fmt.Errorf("unexpected GitHub API URL %q", apiURL)
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAPIURL(t *testing.T) {
+	cases := []struct {
+		url    string
+		owner  string
+		name   string
+		number int
+	}{
+		{"https://api.github.com/repos/src-d/gitbase/issues/1", "src-d", "gitbase", 1},
+		{"https://api.github.com/repos/src-d/gitbase/pulls/123", "src-d", "gitbase", 123},
+		{"https://github.example.com/api/v3/repos/org/repo/issues/7", "org", "repo", 7},
+		{"http://127.0.0.1:1234/repos/org/repo/pulls/2/", "org", "repo", 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.url, func(t *testing.T) {
+			owner, name, number, err := ParseAPIURL(c.url)
+			require.NoError(t, err)
+			require.Equal(t, c.owner, owner)
+			require.Equal(t, c.name, name)
+			require.Equal(t, c.number, number)
+		})
+	}
+
+	for _, url := range []string{
+		"",
+		"https://api.github.com/repos/src-d/gitbase",
+		"https://api.github.com/repos/src-d/gitbase/issues/comments/1",
+		"https://api.github.com/repos/src-d/gitbase/issues/abc",
+		"https://github.com/src-d/gitbase/issues/1",
+	} {
+		_, _, _, err := ParseAPIURL(url)
+		require.Error(t, err, url)
+	}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/metrics"
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
+	"github.com/google/go-github/github"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// instrumentedStorer records the metrics and a span for each save
+type instrumentedStorer struct {
+	Storer
+	name string
+}
+
+// WithInstrumentation wraps s to record the metrics and a span for each save.
+// name is used to label them, e.g. "db" or "stdout".
+func WithInstrumentation(s Storer, name string) Storer {
+	return &instrumentedStorer{Storer: s, name: name}
+}
+
+// Observe runs save in a "save <typ>" span, and records its duration and
+// result in the metrics labeled with the storer name. It is shared by the
+// instrumented storers of all the downloaders.
+func Observe(ctx context.Context, storer, typ string, save func(context.Context) error, attrs ...attribute.KeyValue) error {
+	attrs = append(attrs, attribute.String("storer", storer))
+	ctx, span := tracing.Start(ctx, "save "+typ, attrs...)
+
+	t0 := time.Now()
+	err := save(ctx)
+	metrics.ObserveSave(storer, typ, t0, err)
+
+	tracing.End(span, err)
+	return err
+}
+
+func (s *instrumentedStorer) observe(ctx context.Context, typ string, save func(context.Context) error, attrs ...attribute.KeyValue) error {
+	return Observe(ctx, s.name, typ, save, attrs...)
+}
+
+func (s *instrumentedStorer) SaveOrganization(ctx context.Context, org *github.Organization) error {
+	return s.observe(ctx, "organization", func(ctx context.Context) error {
+		return s.Storer.SaveOrganization(ctx, org)
+	})
+}
+
+func (s *instrumentedStorer) SaveRepository(ctx context.Context, repository *github.Repository) error {
+	return s.observe(ctx, "repository", func(ctx context.Context) error {
+		return s.Storer.SaveRepository(ctx, repository)
+	})
+}
+
+func (s *instrumentedStorer) SaveIssue(ctx context.Context, issue *github.Issue) error {
+	return s.observe(ctx, "issue", func(ctx context.Context) error {
+		return s.Storer.SaveIssue(ctx, issue)
+	}, attribute.Int("issue", issue.GetNumber()))
+}
+
+func (s *instrumentedStorer) SaveIssueComment(ctx context.Context, comment *github.IssueComment) error {
+	return s.observe(ctx, "issue_comment", func(ctx context.Context) error {
+		return s.Storer.SaveIssueComment(ctx, comment)
+	})
+}
+
+func (s *instrumentedStorer) SavePullRequest(ctx context.Context, pr *github.PullRequest) error {
+	return s.observe(ctx, "pull_request", func(ctx context.Context) error {
+		return s.Storer.SavePullRequest(ctx, pr)
+	}, attribute.Int("pr", pr.GetNumber()))
+}
+
+func (s *instrumentedStorer) SavePullRequestComment(ctx context.Context, comment *github.PullRequestComment) error {
+	return s.observe(ctx, "pull_request_review_comment", func(ctx context.Context) error {
+		return s.Storer.SavePullRequestComment(ctx, comment)
+	})
+}
+
+func (s *instrumentedStorer) SavePullRequestReview(ctx context.Context, review *github.PullRequestReview) error {
+	return s.observe(ctx, "pull_request_review", func(ctx context.Context) error {
+		return s.Storer.SavePullRequestReview(ctx, review)
+	})
+}
@@ -0,0 +1,395 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/lib/pq"
+)
+
+// MetadataDBStorer saves the canonical metadata entities to the versioned
+// tables, with DBStorer. The schema has no events table yet, so those are not
+// saved.
+type MetadataDBStorer struct {
+	s *DBStorer
+}
+
+var (
+	_ metadata.Storer = &MetadataDBStorer{}
+	_ SyncStorer      = &MetadataDBStorer{}
+)
+
+func NewMetadataDBStorer(db *sql.DB) *MetadataDBStorer {
+	return &MetadataDBStorer{s: NewDBStorer(db)}
+}
+
+func (m *MetadataDBStorer) Begin() error {
+	return m.s.Begin()
+}
+
+func (m *MetadataDBStorer) Commit() error {
+	return m.s.Commit()
+}
+
+func (m *MetadataDBStorer) Rollback() error {
+	return m.s.Rollback()
+}
+
+func (m *MetadataDBStorer) Version(v string) {
+	m.s.Version(v)
+}
+
+func (m *MetadataDBStorer) SetActiveVersion(v string) error {
+	return m.s.SetActiveVersion(v)
+}
+
+func (m *MetadataDBStorer) Cleanup(currentVersion string) error {
+	return m.s.Cleanup(currentVersion)
+}
+
+func (m *MetadataDBStorer) LoadSyncState(ctx context.Context, owner, name string) (*SyncState, error) {
+	return m.s.LoadSyncState(ctx, owner, name)
+}
+
+func (m *MetadataDBStorer) SaveSyncState(ctx context.Context, owner, name string, state *SyncState) error {
+	return m.s.SaveSyncState(ctx, owner, name, state)
+}
+
+func (m *MetadataDBStorer) MergeVersion(ctx context.Context, owner, name, from string) error {
+	return m.s.MergeVersion(ctx, owner, name, from)
+}
+
+func (m *MetadataDBStorer) SaveOrganization(ctx context.Context, org *metadata.Organization) error {
+	return m.s.insert(ctx, "organizations", DatabaseIDKey, OrganizationsCols,
+		org.DatabaseID, org.Login, org.Name, org.Description, org.CreatedAt)
+}
+
+func (m *MetadataDBStorer) SaveUser(ctx context.Context, user *metadata.User) error {
+	return m.s.insert(ctx, "users", DatabaseIDKey, UsersCols,
+		user.DatabaseID, user.Login, user.Type, user.Name, user.Company,
+		user.Location, user.Email, user.CreatedAt)
+}
+
+func (m *MetadataDBStorer) SaveRepository(ctx context.Context, repository *metadata.Repository) error {
+	return m.s.insert(ctx, "repositories", DatabaseIDKey, RepositoriesCols,
+		repository.DatabaseID, repository.CreatedAt, repository.Description,
+		repository.Owner, repository.Name)
+}
+
+func (m *MetadataDBStorer) SaveIssue(ctx context.Context, issue *metadata.Issue) error {
+	return m.s.insert(ctx, "issues", DatabaseIDKey, IssuesCols,
+		issue.DatabaseID, issue.Title, issue.Body, issue.Number,
+		issue.RepositoryOwner, issue.RepositoryName)
+}
+
+func (m *MetadataDBStorer) SavePullRequest(ctx context.Context, pr *metadata.PullRequest) error {
+	return m.s.insert(ctx, "pull_requests", DatabaseIDKey, PullRequestsCols,
+		pr.DatabaseID, pr.Title, pr.Body, pr.Number, pr.State,
+		pr.Author, pr.RepositoryOwner, pr.RepositoryName)
+}
+
+func (m *MetadataDBStorer) SaveComment(ctx context.Context, comment *metadata.Comment) error {
+	return m.s.insert(ctx, "issue_comments", DatabaseIDKey, IssueCommentsCols,
+		comment.DatabaseID, comment.Author, comment.Body,
+		comment.RepositoryOwner, comment.RepositoryName, comment.Number)
+}
+
+func (m *MetadataDBStorer) SaveReview(ctx context.Context, review *metadata.Review) error {
+	return m.s.insert(ctx, "pull_request_reviews", DatabaseIDKey, PullRequestReviewsCols,
+		review.DatabaseID, review.Author, review.Body, review.State,
+		review.RepositoryOwner, review.RepositoryName, review.PullRequestNumber)
+}
+
+func (m *MetadataDBStorer) SaveReviewComment(ctx context.Context, comment *metadata.ReviewComment) error {
+	return m.s.insert(ctx, "pull_request_review_comments", DatabaseIDKey, PullRequestReviewCommentsCols,
+		comment.DatabaseID, comment.Author, comment.Body, comment.Path,
+		comment.ReviewID, comment.RepositoryOwner, comment.RepositoryName, comment.PullRequestNumber)
+}
+
+func (m *MetadataDBStorer) SaveReviewThread(ctx context.Context, thread *metadata.ReviewThread) error {
+	// comment_ids is part of the unique constraint, see SaveReaction
+	ids := thread.CommentIDs
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	return m.s.insert(ctx, "pull_request_review_threads", ReviewThreadKey, PullRequestReviewThreadsCols,
+		thread.ID, thread.Path, thread.Line, thread.OriginalLine, thread.DiffSide,
+		thread.IsResolved, thread.IsOutdated, thread.ResolvedBy, pq.Array(ids),
+		thread.RepositoryOwner, thread.RepositoryName, thread.PullRequestNumber)
+}
+
+func (m *MetadataDBStorer) SaveEvent(ctx context.Context, event *metadata.Event) error {
+	return nil
+}
+
+func (m *MetadataDBStorer) SaveReaction(ctx context.Context, reaction *metadata.Reaction) error {
+	// users is part of the unique constraint, a NULL would never conflict
+	// and the same reaction would be inserted again in each version
+	users := reaction.Users
+	if users == nil {
+		users = []string{}
+	}
+
+	return m.s.insert(ctx, "reactions", ReactionKey, ReactionsCols,
+		reaction.SubjectID, reaction.SubjectType, reaction.Content, reaction.Count,
+		pq.Array(users), reaction.RepositoryOwner, reaction.RepositoryName,
+		reaction.Number)
+}
+
+// MetadataStdoutStorer prints a line for each canonical entity
+type MetadataStdoutStorer struct{}
+
+var _ metadata.Storer = MetadataStdoutStorer{}
+
+func (s MetadataStdoutStorer) Begin() error {
+	return nil
+}
+
+func (s MetadataStdoutStorer) Commit() error {
+	return nil
+}
+
+func (s MetadataStdoutStorer) Rollback() error {
+	return nil
+}
+
+func (s MetadataStdoutStorer) Version(v string) {
+}
+
+func (s MetadataStdoutStorer) SetActiveVersion(v string) error {
+	return nil
+}
+
+func (s MetadataStdoutStorer) Cleanup(currentVersion string) error {
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveOrganization(ctx context.Context, org *metadata.Organization) error {
+	fmt.Printf("organization data fetched for %s\n", org.Login)
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveUser(ctx context.Context, user *metadata.User) error {
+	fmt.Printf("user data fetched for %s\n", user.Login)
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveRepository(ctx context.Context, repository *metadata.Repository) error {
+	fmt.Printf("repository data fetched for %s/%s\n", repository.Owner, repository.Name)
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveIssue(ctx context.Context, issue *metadata.Issue) error {
+	fmt.Printf("issue data fetched for #%v %s\n", issue.Number, issue.Title)
+	return nil
+}
+
+func (s MetadataStdoutStorer) SavePullRequest(ctx context.Context, pr *metadata.PullRequest) error {
+	fmt.Printf("PR data fetched for #%v %s\n", pr.Number, pr.Title)
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveComment(ctx context.Context, comment *metadata.Comment) error {
+	fmt.Printf("  comment data fetched for #%v by %s: %q\n", comment.Number, comment.Author, trim(comment.Body))
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveReview(ctx context.Context, review *metadata.Review) error {
+	fmt.Printf("  PR review data fetched for #%v by %s %v: %q\n", review.PullRequestNumber, review.Author, review.State, trim(review.Body))
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveReviewComment(ctx context.Context, comment *metadata.ReviewComment) error {
+	fmt.Printf("    PR review comment data fetched for #%v by %s: %q\n", comment.PullRequestNumber, comment.Author, trim(comment.Body))
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveReviewThread(ctx context.Context, thread *metadata.ReviewThread) error {
+	fmt.Printf("    PR review thread data fetched for #%v on %s: %d comments, resolved %v\n", thread.PullRequestNumber, thread.Path, len(thread.CommentIDs), thread.IsResolved)
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveEvent(ctx context.Context, event *metadata.Event) error {
+	fmt.Printf("  event %s fetched for #%v by %s\n", event.Event, event.Number, event.Actor)
+	return nil
+}
+
+func (s MetadataStdoutStorer) SaveReaction(ctx context.Context, reaction *metadata.Reaction) error {
+	fmt.Printf("  %d %s reactions fetched for #%v %s %d\n", reaction.Count, reaction.Content, reaction.Number, reaction.SubjectType, reaction.SubjectID)
+	return nil
+}
+
+// LoadVersion reads the entities of the repository owner/name saved in
+// version v and saves them to s. The users and organizations do not belong to
+// a repository, all the ones of version v are read. Only the columns of the
+// versioned tables are set.
+func LoadVersion(ctx context.Context, db *sql.DB, v, owner, name string, s metadata.Storer) error {
+	// repoCols filters the rows by repository with $2 and $3, if it is not
+	// empty
+	query := func(view, cols, repoCols string, scan func(*sql.Rows) error) error {
+		where := "$1 = ANY(versions)"
+		args := []interface{}{v}
+		if repoCols != "" {
+			where += " AND " + repoCols
+			args = append(args, owner, name)
+		}
+
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(
+			`SELECT %s FROM %s_versioned
+			WHERE %s`, cols, view, where),
+			args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				return err
+			}
+		}
+
+		return rows.Err()
+	}
+
+	byRepository := "repository_owner = $2 AND repository_name = $3"
+
+	err := query("organizations", OrganizationsCols, "", func(rows *sql.Rows) error {
+		var o metadata.Organization
+		err := rows.Scan(&o.DatabaseID, &o.Login, &o.Name, &o.Description, &o.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		return s.SaveOrganization(ctx, &o)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query("users", UsersCols, "", func(rows *sql.Rows) error {
+		var u metadata.User
+		// the rows saved before the type column was added have no type
+		var typ sql.NullString
+		err := rows.Scan(&u.DatabaseID, &u.Login, &typ, &u.Name, &u.Company, &u.Location, &u.Email,
+			&u.CreatedAt)
+		if err != nil {
+			return err
+		}
+		u.Type = typ.String
+
+		return s.SaveUser(ctx, &u)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query("repositories", RepositoriesCols, "owner = $2 AND name = $3", func(rows *sql.Rows) error {
+		var r metadata.Repository
+		err := rows.Scan(&r.DatabaseID, &r.CreatedAt, &r.Description, &r.Owner, &r.Name)
+		if err != nil {
+			return err
+		}
+
+		return s.SaveRepository(ctx, &r)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query("issues", IssuesCols, byRepository, func(rows *sql.Rows) error {
+		var i metadata.Issue
+		err := rows.Scan(&i.DatabaseID, &i.Title, &i.Body, &i.Number, &i.RepositoryOwner, &i.RepositoryName)
+		if err != nil {
+			return err
+		}
+
+		return s.SaveIssue(ctx, &i)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query("pull_requests", PullRequestsCols, byRepository, func(rows *sql.Rows) error {
+		var pr metadata.PullRequest
+		err := rows.Scan(&pr.DatabaseID, &pr.Title, &pr.Body, &pr.Number, &pr.State, &pr.Author,
+			&pr.RepositoryOwner, &pr.RepositoryName)
+		if err != nil {
+			return err
+		}
+
+		return s.SavePullRequest(ctx, &pr)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query("issue_comments", IssueCommentsCols, byRepository, func(rows *sql.Rows) error {
+		var c metadata.Comment
+		err := rows.Scan(&c.DatabaseID, &c.Author, &c.Body, &c.RepositoryOwner, &c.RepositoryName, &c.Number)
+		if err != nil {
+			return err
+		}
+
+		return s.SaveComment(ctx, &c)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query("pull_request_reviews", PullRequestReviewsCols, byRepository, func(rows *sql.Rows) error {
+		var r metadata.Review
+		err := rows.Scan(&r.DatabaseID, &r.Author, &r.Body, &r.State, &r.RepositoryOwner, &r.RepositoryName,
+			&r.PullRequestNumber)
+		if err != nil {
+			return err
+		}
+
+		return s.SaveReview(ctx, &r)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query("pull_request_review_comments", PullRequestReviewCommentsCols, byRepository, func(rows *sql.Rows) error {
+		var c metadata.ReviewComment
+		err := rows.Scan(&c.DatabaseID, &c.Author, &c.Body, &c.Path, &c.ReviewID, &c.RepositoryOwner,
+			&c.RepositoryName, &c.PullRequestNumber)
+		if err != nil {
+			return err
+		}
+
+		return s.SaveReviewComment(ctx, &c)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query("pull_request_review_threads", PullRequestReviewThreadsCols, byRepository, func(rows *sql.Rows) error {
+		var t metadata.ReviewThread
+		err := rows.Scan(&t.ID, &t.Path, &t.Line, &t.OriginalLine, &t.DiffSide, &t.IsResolved, &t.IsOutdated,
+			&t.ResolvedBy, pq.Array(&t.CommentIDs), &t.RepositoryOwner, &t.RepositoryName, &t.PullRequestNumber)
+		if err != nil {
+			return err
+		}
+
+		return s.SaveReviewThread(ctx, &t)
+	})
+	if err != nil {
+		return err
+	}
+
+	return query("reactions", ReactionsCols, byRepository, func(rows *sql.Rows) error {
+		var r metadata.Reaction
+		err := rows.Scan(&r.SubjectID, &r.SubjectType, &r.Content, &r.Count, pq.Array(&r.Users),
+			&r.RepositoryOwner, &r.RepositoryName, &r.Number)
+		if err != nil {
+			return err
+		}
+
+		return s.SaveReaction(ctx, &r)
+	})
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// testDB connects to the PostgreSQL DB of SOURCED_TEST_DB and applies the
+// migrations, the test is skipped if it is not set. The DB must be only for
+// the tests, all the tables are dropped when done.
+func testDB(t *testing.T) (*sql.DB, func()) {
+	url := os.Getenv("SOURCED_TEST_DB")
+	if url == "" {
+		t.Skip("SOURCED_TEST_DB is not set")
+	}
+
+	m, err := migrate.New("file://../../v4/db/migrations", url)
+	require.NoError(t, err)
+	if err := m.Up(); err != migrate.ErrNoChange {
+		require.NoError(t, err)
+	}
+
+	db, err := sql.Open("postgres", url)
+	require.NoError(t, err)
+
+	return db, func() {
+		db.Close()
+		require.NoError(t, m.Down())
+	}
+}
+
+// saveVersion calls save in version v, in one transaction. If from is not
+// empty the version of org/repo is merged from it first.
+func saveVersion(t *testing.T, s *MetadataDBStorer, v, from string, save func(context.Context) error) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	s.Version(v)
+	require.NoError(s.Begin())
+
+	if from != "" {
+		require.NoError(s.MergeVersion(ctx, "org", "repo", from))
+	}
+
+	require.NoError(save(ctx))
+	require.NoError(s.Commit())
+}
+
+// versions returns the versions of the rows of the versioned table of view,
+// by the value of col
+func versions(t *testing.T, db *sql.DB, view, col string) map[string][]string {
+	rows, err := db.Query(fmt.Sprintf(`SELECT %s, versions FROM %s_versioned`, col, view))
+	require.NoError(t, err)
+	defer rows.Close()
+
+	result := make(map[string][]string)
+	for rows.Next() {
+		var value string
+		var versions []string
+		require.NoError(t, rows.Scan(&value, pq.Array(&versions)))
+		result[value] = versions
+	}
+	require.NoError(t, rows.Err())
+
+	return result
+}
+
+func TestSaveReactionVersions(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	s := NewMetadataDBStorer(db)
+	reaction := func(ctx context.Context, name string, id int64, count int) error {
+		return s.SaveReaction(ctx, &metadata.Reaction{
+			RepositoryOwner: "org",
+			RepositoryName:  name,
+			Number:          1,
+			SubjectType:     "issues",
+			SubjectID:       id,
+			Content:         "THUMBS_UP",
+			Count:           count,
+		})
+	}
+	save := func(count int) func(context.Context) error {
+		return func(ctx context.Context) error {
+			return reaction(ctx, "repo", 10, count)
+		}
+	}
+
+	// the same reaction, without users, is one row in both versions
+	saveVersion(t, s, "v1", "", save(1))
+	saveVersion(t, s, "v2", "", func(ctx context.Context) error {
+		if err := reaction(ctx, "other", 20, 5); err != nil {
+			return err
+		}
+
+		return reaction(ctx, "repo", 10, 1)
+	})
+
+	// after a merge the changed reaction replaces the previous row, and the
+	// rows of other repositories are not merged
+	saveVersion(t, s, "v3", "v2", save(2))
+
+	require.Equal(map[string][]string{
+		"1": {"v1", "v2"},
+		"2": {"v3"},
+		"5": {"v2"},
+	}, versions(t, db, "reactions", "count"))
+}
+
+func TestSaveReviewThreadVersions(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	s := NewMetadataDBStorer(db)
+	save := func(resolved bool) func(context.Context) error {
+		return func(ctx context.Context) error {
+			return s.SaveReviewThread(ctx, &metadata.ReviewThread{
+				ID:                "MDI0OlB1bGxSZXF1ZXN0UmV2aWV3VGhyZWFkMQ==",
+				RepositoryOwner:   "org",
+				RepositoryName:    "repo",
+				PullRequestNumber: 1,
+				Path:              "README.md",
+				IsResolved:        resolved,
+			})
+		}
+	}
+
+	// the same thread, without comment IDs, is one row in both versions
+	saveVersion(t, s, "v1", "", save(false))
+	saveVersion(t, s, "v2", "", save(false))
+
+	// after a merge the resolved thread replaces the previous row
+	saveVersion(t, s, "v3", "v2", save(true))
+
+	require.Equal(map[string][]string{
+		"false": {"v1", "v2"},
+		"true":  {"v3"},
+	}, versions(t, db, "pull_request_review_threads", "is_resolved"))
+}
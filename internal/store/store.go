@@ -1,52 +1,116 @@
 package store
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/google/go-github/github"
 )
 
 type Storer interface {
-	SaveRepository(repository *github.Repository) error
-	SaveIssue(issue *github.Issue) error
-	SaveIssueComment(comment *github.IssueComment) error
-	SavePullRequest(issue *github.PullRequest) error
-	SavePullRequestComment(comment *github.PullRequestComment) error
-	SavePullRequestReview(comment *github.PullRequestReview) error
+	SaveOrganization(ctx context.Context, org *github.Organization) error
+	SaveRepository(ctx context.Context, repository *github.Repository) error
+	SaveIssue(ctx context.Context, issue *github.Issue) error
+	SaveIssueComment(ctx context.Context, comment *github.IssueComment) error
+	SavePullRequest(ctx context.Context, issue *github.PullRequest) error
+	SavePullRequestComment(ctx context.Context, comment *github.PullRequestComment) error
+	SavePullRequestReview(ctx context.Context, comment *github.PullRequestReview) error
+
+	Begin() error
+	Commit() error
+	Rollback() error
+	Version(v string)
+	SetActiveVersion(v string) error
+	Cleanup(currentVersion string) error
+
+	SyncStorer
+}
+
+// SyncStorer keeps the state of the incremental downloads
+type SyncStorer interface {
+	// LoadSyncState returns the state of the last incremental download of
+	// the repository, or nil if there is none
+	LoadSyncState(ctx context.Context, owner, name string) (*SyncState, error)
+	SaveSyncState(ctx context.Context, owner, name string, state *SyncState) error
+	// MergeVersion adds the entities of the repository owner/name in version
+	// from to the current version. The entities saved afterwards replace the
+	// ones with the same ID. The users and organizations do not belong to a
+	// repository, they are not merged.
+	MergeVersion(ctx context.Context, owner, name, from string) error
 }
 
 type StdoutStorer struct{}
 
-func (s StdoutStorer) SaveRepository(repository *github.Repository) error {
+func (s StdoutStorer) SaveOrganization(ctx context.Context, org *github.Organization) error {
+	fmt.Printf("organization data fetched for %s\n", org.GetLogin())
+	return nil
+}
+
+func (s StdoutStorer) SaveRepository(ctx context.Context, repository *github.Repository) error {
 	fmt.Printf("repository data fetched for %s/%s\n", repository.GetOwner().GetName(), repository.GetName())
 	return nil
 }
 
-func (s StdoutStorer) SaveIssue(issue *github.Issue) error {
+func (s StdoutStorer) SaveIssue(ctx context.Context, issue *github.Issue) error {
 	fmt.Printf("issue data fetched for #%v %s\n", issue.GetNumber(), issue.GetTitle())
 	return nil
 }
 
-func (s StdoutStorer) SaveIssueComment(comment *github.IssueComment) error {
+func (s StdoutStorer) SaveIssueComment(ctx context.Context, comment *github.IssueComment) error {
 	fmt.Printf("  issue comment data fetched %v by %s: %q\n", comment.GetIssueURL(), comment.GetUser().GetLogin(), trim(comment.GetBody()))
 	return nil
 }
 
-func (s StdoutStorer) SavePullRequest(pr *github.PullRequest) error {
+func (s StdoutStorer) SavePullRequest(ctx context.Context, pr *github.PullRequest) error {
 	fmt.Printf("PR data fetched for #%v %s\n", pr.GetNumber(), pr.GetTitle())
 	return nil
 }
 
-func (s StdoutStorer) SavePullRequestComment(comment *github.PullRequestComment) error {
+func (s StdoutStorer) SavePullRequestComment(ctx context.Context, comment *github.PullRequestComment) error {
 	fmt.Printf("  PR comment data fetched %v by %s: %q\n", comment.GetPullRequestURL(), comment.GetUser().GetLogin(), trim(comment.GetBody()))
 	return nil
 }
 
-func (s StdoutStorer) SavePullRequestReview(review *github.PullRequestReview) error {
+func (s StdoutStorer) SavePullRequestReview(ctx context.Context, review *github.PullRequestReview) error {
 	fmt.Printf("  PR review data fetched %v by %s %v: %q\n", review.GetPullRequestURL(), review.GetUser().GetLogin(), review.GetState(), trim(review.GetBody()))
 	return nil
 }
 
+func (s StdoutStorer) Begin() error {
+	return nil
+}
+
+func (s StdoutStorer) Commit() error {
+	return nil
+}
+
+func (s StdoutStorer) Rollback() error {
+	return nil
+}
+
+func (s StdoutStorer) Version(v string) {
+}
+
+func (s StdoutStorer) SetActiveVersion(v string) error {
+	return nil
+}
+
+func (s StdoutStorer) Cleanup(currentVersion string) error {
+	return nil
+}
+
+func (s StdoutStorer) LoadSyncState(ctx context.Context, owner, name string) (*SyncState, error) {
+	return nil, nil
+}
+
+func (s StdoutStorer) SaveSyncState(ctx context.Context, owner, name string, state *SyncState) error {
+	return nil
+}
+
+func (s StdoutStorer) MergeVersion(ctx context.Context, owner, name, from string) error {
+	return nil
+}
+
 func trim(s string) string {
 	if len(s) > 40 {
 		return s[0:39] + "..."
@@ -0,0 +1,21 @@
+package store
+
+import "time"
+
+// SyncState is the state of the last incremental download of a repository,
+// used to request only the changes in the next one
+type SyncState struct {
+	// Version is the version the repository was saved in
+	Version string
+	// Endpoints is the state of each REST endpoint, by path
+	Endpoints map[string]EndpointState
+}
+
+// EndpointState is the state of a REST endpoint after an incremental download
+type EndpointState struct {
+	// ETag of the response, or of the first page for the listings, sent in
+	// If-None-Match to get a 304 when nothing changed
+	ETag string `json:"etag,omitempty"`
+	// LastSync is the since parameter for the listings in the next download
+	LastSync time.Time `json:"last_sync"`
+}
@@ -0,0 +1,121 @@
+// Package tracing creates the OpenTelemetry spans of the downloaders, and
+// configures where they are exported.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/carlosms/metadata-retrieval-playground"
+
+// Start creates a new span as a child of the one in ctx, if any. When tracing
+// is not configured with Setup the spans are no-ops.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends the span, recording err if it is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Options configures the span exporters
+type Options struct {
+	// OTLPEndpoint is the host:port of an OTLP/HTTP collector
+	OTLPEndpoint string
+	// OTLPInsecure disables TLS for the OTLP collector
+	OTLPInsecure bool
+	// File is the path of a file where the spans are written as JSON
+	File string
+}
+
+// Setup configures the global tracer provider to export the spans as set in
+// opts. The returned function flushes the pending spans and must be called
+// before exiting. If no exporter is set, the spans are not recorded.
+func Setup(opts Options) (func(context.Context) error, error) {
+	var providerOpts []sdktrace.TracerProviderOption
+	var closers []io.Closer
+
+	// the OTLP exporter is shut down if the file exporter fails, to close its
+	// connections
+	var otlpExp *otlp.Exporter
+	shutdownOTLP := func() {
+		if otlpExp != nil {
+			otlpExp.Shutdown(context.Background())
+		}
+	}
+
+	if opts.OTLPEndpoint != "" {
+		driverOpts := []otlphttp.Option{otlphttp.WithEndpoint(opts.OTLPEndpoint)}
+		if opts.OTLPInsecure {
+			driverOpts = append(driverOpts, otlphttp.WithInsecure())
+		}
+
+		var err error
+		otlpExp, err = otlp.NewExporter(context.Background(), otlphttp.NewDriver(driverOpts...))
+		if err != nil {
+			return nil, fmt.Errorf("could not create the OTLP exporter: %v", err)
+		}
+
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(otlpExp))
+	}
+
+	if opts.File != "" {
+		f, err := os.Create(opts.File)
+		if err != nil {
+			shutdownOTLP()
+			return nil, fmt.Errorf("could not create the traces file: %v", err)
+		}
+		closers = append(closers, f)
+
+		exp, err := stdout.NewExporter(stdout.WithWriter(f), stdout.WithoutMetricExport())
+		if err != nil {
+			f.Close()
+			shutdownOTLP()
+			return nil, fmt.Errorf("could not create the file exporter: %v", err)
+		}
+
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exp))
+	}
+
+	if len(providerOpts) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	providerOpts = append(providerOpts, sdktrace.WithResource(resource.NewWithAttributes(
+		semconv.ServiceNameKey.String("metadata"),
+	)))
+
+	tp := sdktrace.NewTracerProvider(providerOpts...)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		for _, c := range closers {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+
+		return err
+	}, nil
+}
@@ -1,7 +1,32 @@
 package metadata
 
+import "context"
+
 type MetadataDownloader interface {
 	DownloadRepository(owner string, name string, version string) error
 	DownloadOrg(name string, version string) error
 	SetCurrent(version string) error
 }
+
+// Storer saves the canonical entities. Any downloader can save to any Storer
+// through the mappers of its package.
+type Storer interface {
+	SaveOrganization(ctx context.Context, org *Organization) error
+	SaveUser(ctx context.Context, user *User) error
+	SaveRepository(ctx context.Context, repository *Repository) error
+	SaveIssue(ctx context.Context, issue *Issue) error
+	SavePullRequest(ctx context.Context, pr *PullRequest) error
+	SaveComment(ctx context.Context, comment *Comment) error
+	SaveReview(ctx context.Context, review *Review) error
+	SaveReviewComment(ctx context.Context, comment *ReviewComment) error
+	SaveReviewThread(ctx context.Context, thread *ReviewThread) error
+	SaveEvent(ctx context.Context, event *Event) error
+	SaveReaction(ctx context.Context, reaction *Reaction) error
+
+	Begin() error
+	Commit() error
+	Rollback() error
+	Version(v string)
+	SetActiveVersion(v string) error
+	Cleanup(currentVersion string) error
+}
@@ -0,0 +1,305 @@
+package migration
+
+import (
+	"context"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+)
+
+// The types of the JSON files of a migration archive. The entities reference
+// each other by their github.com URL, e.g. an issue has the URL of its
+// repository and of its author.
+
+type Email struct {
+	Address  string `json:"address"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type User struct {
+	Type      string    `json:"type"`
+	URL       string    `json:"url"`
+	AvatarURL string    `json:"avatar_url"`
+	Login     string    `json:"login"`
+	Name      string    `json:"name"`
+	Company   string    `json:"company"`
+	Location  string    `json:"location"`
+	Emails    []Email   `json:"emails"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Organization struct {
+	URL         string    `json:"url"`
+	Login       string    `json:"login"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Repository struct {
+	URL           string    `json:"url"`
+	Owner         string    `json:"owner"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Website       string    `json:"website"`
+	Private       bool      `json:"private"`
+	DefaultBranch string    `json:"default_branch"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Issue struct {
+	URL        string     `json:"url"`
+	Repository string     `json:"repository"`
+	User       string     `json:"user"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	Assignees  []string   `json:"assignees"`
+	Labels     []string   `json:"labels"`
+	ClosedAt   *time.Time `json:"closed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type Branch struct {
+	Ref  string `json:"ref"`
+	SHA  string `json:"sha"`
+	User string `json:"user"`
+	Repo string `json:"repo"`
+}
+
+type PullRequest struct {
+	Issue
+
+	Base     Branch     `json:"base"`
+	Head     Branch     `json:"head"`
+	MergedAt *time.Time `json:"merged_at"`
+}
+
+// IssueComment is a comment of an issue or a PR, only one of Issue and
+// PullRequest is set
+type IssueComment struct {
+	URL         string    `json:"url"`
+	Issue       string    `json:"issue"`
+	PullRequest string    `json:"pull_request"`
+	User        string    `json:"user"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type PullRequestReview struct {
+	URL         string     `json:"url"`
+	PullRequest string     `json:"pull_request"`
+	User        string     `json:"user"`
+	Body        string     `json:"body"`
+	HeadSHA     string     `json:"head_sha"`
+	State       int        `json:"state"`
+	CreatedAt   time.Time  `json:"created_at"`
+	SubmittedAt *time.Time `json:"submitted_at"`
+}
+
+type PullRequestReviewComment struct {
+	URL               string    `json:"url"`
+	PullRequest       string    `json:"pull_request"`
+	PullRequestReview string    `json:"pull_request_review"`
+	User              string    `json:"user"`
+	Body              string    `json:"body"`
+	DiffHunk          string    `json:"diff_hunk"`
+	Path              string    `json:"path"`
+	Position          int       `json:"position"`
+	OriginalPosition  int       `json:"original_position"`
+	CommitID          string    `json:"commit_id"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// IssueEvent is an event of an issue or a PR, only one of Issue and
+// PullRequest is set
+type IssueEvent struct {
+	URL         string    `json:"url"`
+	Issue       string    `json:"issue"`
+	PullRequest string    `json:"pull_request"`
+	Actor       string    `json:"actor"`
+	Event       string    `json:"event"`
+	Subject     string    `json:"subject"`
+	LabelName   string    `json:"label_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Attachment is a file uploaded to an issue or a comment. AssetURL is the path
+// of the file in the archive, e.g. tarball://root/attachments/<id>/image.png
+type Attachment struct {
+	URL              string    `json:"url"`
+	Issue            string    `json:"issue"`
+	IssueComment     string    `json:"issue_comment"`
+	User             string    `json:"user"`
+	AssetName        string    `json:"asset_name"`
+	AssetContentType string    `json:"asset_content_type"`
+	AssetURL         string    `json:"asset_url"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Load reads the JSON files of the migration archive extracted in dir, maps
+// the entities to the canonical types and saves them to s. It fails if the
+// format version in schema.json is not supported. The caller is responsible
+// for the version and the transaction of s.
+func Load(ctx context.Context, dir string, s metadata.Storer) error {
+	return LoadWithAttachments(ctx, dir, s, nil)
+}
+
+// LoadWithAttachments is like Load, but the attachment URLs in the bodies of
+// issues, PRs, comments and reviews are rewritten to their paths in a, see
+// StoreAttachments
+func LoadWithAttachments(ctx context.Context, dir string, s metadata.Storer, a Attachments) error {
+	archive, err := openArchive(dir)
+	if err != nil {
+		return err
+	}
+
+	var users []User
+	err = archive.readFiles("users", &users, func() error {
+		for _, u := range users {
+			if err := s.SaveUser(ctx, MapUser(&u)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var orgs []Organization
+	err = archive.readFiles("organizations", &orgs, func() error {
+		for _, o := range orgs {
+			if err := s.SaveOrganization(ctx, MapOrganization(&o)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var repositories []Repository
+	err = archive.readFiles("repositories", &repositories, func() error {
+		for _, r := range repositories {
+			if err := s.SaveRepository(ctx, MapRepository(&r)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var issues []Issue
+	err = archive.readFiles("issues", &issues, func() error {
+		for _, i := range issues {
+			mapped, err := MapIssue(&i)
+			if err != nil {
+				return err
+			}
+			mapped.Body = a.Rewrite(mapped.Body)
+
+			if err := s.SaveIssue(ctx, mapped); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var prs []PullRequest
+	err = archive.readFiles("pull_requests", &prs, func() error {
+		for _, pr := range prs {
+			mapped, err := MapPullRequest(&pr)
+			if err != nil {
+				return err
+			}
+			mapped.Body = a.Rewrite(mapped.Body)
+
+			if err := s.SavePullRequest(ctx, mapped); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var comments []IssueComment
+	err = archive.readFiles("issue_comments", &comments, func() error {
+		for _, c := range comments {
+			mapped, err := MapIssueComment(&c)
+			if err != nil {
+				return err
+			}
+			mapped.Body = a.Rewrite(mapped.Body)
+
+			if err := s.SaveComment(ctx, mapped); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var reviews []PullRequestReview
+	err = archive.readFiles("pull_request_reviews", &reviews, func() error {
+		for _, r := range reviews {
+			mapped, err := MapReview(&r)
+			if err != nil {
+				return err
+			}
+			mapped.Body = a.Rewrite(mapped.Body)
+
+			if err := s.SaveReview(ctx, mapped); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var reviewComments []PullRequestReviewComment
+	err = archive.readFiles("pull_request_review_comments", &reviewComments, func() error {
+		for _, c := range reviewComments {
+			mapped, err := MapReviewComment(&c)
+			if err != nil {
+				return err
+			}
+			mapped.Body = a.Rewrite(mapped.Body)
+
+			if err := s.SaveReviewComment(ctx, mapped); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var events []IssueEvent
+	return archive.readFiles("issue_events", &events, func() error {
+		for _, e := range events {
+			mapped, err := MapEvent(&e)
+			if err != nil {
+				return err
+			}
+
+			if err := s.SaveEvent(ctx, mapped); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
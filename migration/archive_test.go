@@ -0,0 +1,193 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/carlosms/metadata-retrieval-playground/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStorer keeps a copy of all the saved entities in memory
+type memoryStorer struct {
+	store.MetadataStdoutStorer
+
+	users          []metadata.User
+	orgs           []metadata.Organization
+	repositories   []metadata.Repository
+	issues         []metadata.Issue
+	prs            []metadata.PullRequest
+	comments       []metadata.Comment
+	reviews        []metadata.Review
+	reviewComments []metadata.ReviewComment
+	reviewThreads  []metadata.ReviewThread
+	events         []metadata.Event
+	reactions      []metadata.Reaction
+}
+
+func (s *memoryStorer) SaveUser(ctx context.Context, user *metadata.User) error {
+	s.users = append(s.users, *user)
+	return nil
+}
+
+func (s *memoryStorer) SaveOrganization(ctx context.Context, org *metadata.Organization) error {
+	s.orgs = append(s.orgs, *org)
+	return nil
+}
+
+func (s *memoryStorer) SaveRepository(ctx context.Context, repository *metadata.Repository) error {
+	s.repositories = append(s.repositories, *repository)
+	return nil
+}
+
+func (s *memoryStorer) SaveIssue(ctx context.Context, issue *metadata.Issue) error {
+	s.issues = append(s.issues, *issue)
+	return nil
+}
+
+func (s *memoryStorer) SavePullRequest(ctx context.Context, pr *metadata.PullRequest) error {
+	s.prs = append(s.prs, *pr)
+	return nil
+}
+
+func (s *memoryStorer) SaveComment(ctx context.Context, comment *metadata.Comment) error {
+	s.comments = append(s.comments, *comment)
+	return nil
+}
+
+func (s *memoryStorer) SaveReview(ctx context.Context, review *metadata.Review) error {
+	s.reviews = append(s.reviews, *review)
+	return nil
+}
+
+func (s *memoryStorer) SaveReviewComment(ctx context.Context, comment *metadata.ReviewComment) error {
+	s.reviewComments = append(s.reviewComments, *comment)
+	return nil
+}
+
+func (s *memoryStorer) SaveReviewThread(ctx context.Context, thread *metadata.ReviewThread) error {
+	s.reviewThreads = append(s.reviewThreads, *thread)
+	return nil
+}
+
+func (s *memoryStorer) SaveEvent(ctx context.Context, event *metadata.Event) error {
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func (s *memoryStorer) SaveReaction(ctx context.Context, reaction *metadata.Reaction) error {
+	s.reactions = append(s.reactions, *reaction)
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	require := require.New(t)
+
+	s := &memoryStorer{}
+	require.NoError(Load(context.Background(), "../downloads/carlosms-test-org-133497", s))
+
+	require.Len(s.users, 2)
+	require.Equal("carlosms", s.users[0].Login)
+	require.Equal(int64(1469173), s.users[0].DatabaseID)
+	require.Equal("1469173+carlosms@users.noreply.github.com", s.users[0].Email)
+
+	require.Len(s.orgs, 1)
+	require.Equal("carlosms-test-org", s.orgs[0].Login)
+
+	require.Len(s.repositories, 1)
+	require.Equal("carlosms-test-org", s.repositories[0].Owner)
+	require.Equal("test-repo", s.repositories[0].Name)
+
+	require.Len(s.issues, 2)
+	require.Equal(1, s.issues[0].Number)
+	require.Equal("OPEN", s.issues[0].State)
+	require.Equal([]string{"carlosms-bot"}, s.issues[0].Assignees)
+	require.Equal([]string{"bug"}, s.issues[0].Labels)
+	require.Equal("CLOSED", s.issues[1].State)
+
+	require.Len(s.prs, 2)
+	require.Equal(3, s.prs[0].Number)
+	require.Equal("carlosms-patch-1", s.prs[0].HeadRef)
+	require.Equal("34404ea4e7a62af2deb3fdfdf05614dfee6f839e", s.prs[0].HeadSHA)
+
+	require.Len(s.comments, 2)
+	require.Equal(int64(525672410), s.comments[0].DatabaseID)
+	require.Equal(1, s.comments[0].Number)
+	require.Equal(3, s.comments[1].Number)
+
+	require.Len(s.reviews, 3)
+	require.Equal(int64(280876535), s.reviews[0].DatabaseID)
+	require.Equal("COMMENTED", s.reviews[0].State)
+	require.Equal("CHANGES_REQUESTED", s.reviews[1].State)
+
+	require.Len(s.reviewComments, 2)
+	require.Equal(int64(318617070), s.reviewComments[0].DatabaseID)
+	require.Equal(int64(280877061), s.reviewComments[0].ReviewID)
+	require.Equal("README.md", s.reviewComments[0].Path)
+
+	require.Len(s.events, 6)
+	require.Equal("labeled", s.events[0].Event)
+	require.Equal("bug", s.events[0].Label)
+	require.Equal("carlosms", s.events[1].Subject)
+}
+
+func TestLoadSeveralFiles(t *testing.T) {
+	require := require.New(t)
+
+	dir, cleanup := copySample(t)
+	defer cleanup()
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "issues_000001.json"))
+	require.NoError(err)
+
+	var issues []map[string]interface{}
+	require.NoError(json.Unmarshal(b, &issues))
+	require.Len(issues, 2)
+
+	// the closed issue goes first, and the open one in a second file without
+	// its nullable fields
+	open, closed := issues[0], issues[1]
+	delete(open, "closed_at")
+	delete(open, "labels")
+
+	for i, item := range []map[string]interface{}{closed, open} {
+		b, err := json.Marshal([]map[string]interface{}{item})
+		require.NoError(err)
+
+		name := filepath.Join(dir, fmt.Sprintf("issues_%06d.json", i+1))
+		require.NoError(ioutil.WriteFile(name, b, 0644))
+	}
+
+	s := &memoryStorer{}
+	require.NoError(Load(context.Background(), dir, s))
+
+	require.Len(s.issues, 2)
+	require.Equal(2, s.issues[0].Number)
+	require.Equal("CLOSED", s.issues[0].State)
+
+	require.Equal(1, s.issues[1].Number)
+	require.Equal("OPEN", s.issues[1].State)
+	require.Nil(s.issues[1].ClosedAt)
+	require.Empty(s.issues[1].Labels)
+}
+
+func TestMapUserType(t *testing.T) {
+	cases := []struct {
+		user User
+		typ  string
+	}{
+		{User{Type: "user", Login: "carlosms"}, metadata.UserType},
+		{User{Type: "bot", Login: "dependabot[bot]"}, metadata.BotType},
+		{User{Type: "mannequin", Login: "imported"}, metadata.MannequinType},
+		{User{Type: "user", Login: "ghost"}, metadata.GhostType},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.typ, MapUser(&c.user).Type, c.user.Login)
+	}
+}
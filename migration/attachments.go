@@ -0,0 +1,155 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tarballPrefix is the prefix of the attachment paths in the archives
+const tarballPrefix = "tarball://root/"
+
+// Attachments maps the URLs of the attachments to the paths where their
+// files are stored
+type Attachments map[string]string
+
+// Rewrite replaces the attachment URLs in body with their stored paths
+func (a Attachments) Rewrite(body string) string {
+	if len(a) == 0 || body == "" {
+		return body
+	}
+
+	// the longest URLs first, in case one is the prefix of another
+	urls := make([]string, 0, len(a))
+	for u := range a {
+		urls = append(urls, u)
+	}
+	sort.Slice(urls, func(i, j int) bool { return len(urls[i]) > len(urls[j]) })
+
+	pairs := make([]string, 0, 2*len(urls))
+	for _, u := range urls {
+		pairs = append(pairs, u, a[u])
+	}
+
+	return strings.NewReplacer(pairs...).Replace(body)
+}
+
+// StoreAttachments copies the attachment files of the archive extracted in
+// dir to storeDir, and returns the path of each one by URL. The files are
+// content addressed, stored as <storeDir>/<sha256[:2]>/<sha256><ext>, so
+// the same file in several archives is stored once.
+func StoreAttachments(dir string, storeDir string) (Attachments, error) {
+	archive, err := openArchive(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(Attachments)
+
+	var attachments []Attachment
+	err = archive.readFiles("attachments", &attachments, func() error {
+		for _, a := range attachments {
+			src, err := assetPath(dir, a.AssetURL)
+			if err != nil {
+				return fmt.Errorf("%v for attachment %v", err, a.URL)
+			}
+
+			path, err := storeFile(src, storeDir, filepath.Ext(a.AssetName))
+			if err != nil {
+				return fmt.Errorf("could not store attachment %v: %v", a.URL, err)
+			}
+
+			result[a.URL] = path
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// assetPath returns the path of the file of an asset URL in the archive
+// extracted in dir. It fails if the path, with its symbolic links resolved,
+// is not inside dir.
+func assetPath(dir string, assetURL string) (string, error) {
+	if !strings.HasPrefix(assetURL, tarballPrefix) {
+		return "", fmt.Errorf("unexpected asset URL %q", assetURL)
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := filepath.EvalSymlinks(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(assetURL, tarballPrefix))))
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("asset URL %q is outside of the archive", assetURL)
+	}
+
+	return path, nil
+}
+
+// storeFile copies src to the content addressed path in storeDir, with the
+// extension ext, and returns the path
+func storeFile(src string, storeDir string, ext string) (string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	dst := filepath.Join(storeDir, sum[:2], sum+ext)
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	// the file is written to a temporary file first, a file at dst is always
+	// complete
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "tmp-")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(tmp, f)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,133 @@
+package migration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/fakegithub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadRepositoryAttachments(t *testing.T) {
+	repo := fakegithub.NewRepository(fakegithub.Config{Owner: "org", Name: "repo", Issues: 2})
+
+	// the same image is uploaded to both issues
+	for i, issue := range repo.Issues {
+		a := &fakegithub.Attachment{
+			ID:          string('a' + rune(i)),
+			URL:         "https://user-images.githubusercontent.com/1/image-" + string('a'+rune(i)) + ".png",
+			Name:        "image.png",
+			ContentType: "image/png",
+			Content:     []byte("png contents"),
+		}
+		issue.Attachments = []*fakegithub.Attachment{a}
+		issue.Body += "\n\n![image](" + a.URL + ")"
+	}
+
+	srv := fakegithub.NewServer(repo)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("included", func(t *testing.T) {
+		require := require.New(t)
+
+		store := filepath.Join(dir, "attachments")
+
+		d, storer := fakeDownloader(t, srv, dir)
+		d.UseAttachments(store)
+		require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+		require.Len(storer.issues, 2)
+		var paths []string
+		for _, i := range storer.issues {
+			require.NotContains(i.Body, "user-images.githubusercontent.com")
+
+			start := strings.Index(i.Body, "](") + 2
+			path := i.Body[start : len(i.Body)-1]
+			require.True(strings.HasPrefix(path, store), path)
+			require.True(strings.HasSuffix(path, ".png"), path)
+
+			b, err := ioutil.ReadFile(path)
+			require.NoError(err)
+			require.Equal("png contents", string(b))
+
+			paths = append(paths, path)
+		}
+
+		// the files are content addressed
+		require.Equal(paths[0], paths[1])
+	})
+
+	t.Run("excluded", func(t *testing.T) {
+		require := require.New(t)
+
+		d, storer := fakeDownloader(t, srv, dir)
+		require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+		require.Len(storer.issues, 2)
+		for _, i := range storer.issues {
+			require.Contains(i.Body, "https://user-images.githubusercontent.com/1/image-")
+		}
+	})
+}
+
+func TestAttachmentsRewrite(t *testing.T) {
+	require := require.New(t)
+
+	a := Attachments{
+		"https://example.com/files/1":  "store/aa/one.txt",
+		"https://example.com/files/10": "store/bb/ten.txt",
+	}
+
+	require.Equal(
+		"see store/aa/one.txt and store/bb/ten.txt",
+		a.Rewrite("see https://example.com/files/1 and https://example.com/files/10"))
+	require.Equal("no attachments", a.Rewrite("no attachments"))
+	require.Equal("body", Attachments(nil).Rewrite("body"))
+}
+
+func TestStoreAttachmentsOutsideArchive(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "migration")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "secret"), []byte("secret"), 0644))
+
+	cases := []struct {
+		name     string
+		assetURL string
+	}{
+		{"parent", "tarball://root/../secret"},
+		{"nested parent", "tarball://root/attachments/../../secret"},
+		{"symlink", "tarball://root/attachments/link"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require := require.New(t)
+
+			dir := filepath.Join(tmp, "archive")
+			require.NoError(os.RemoveAll(dir))
+			require.NoError(os.MkdirAll(filepath.Join(dir, "attachments"), os.ModePerm))
+			require.NoError(os.Symlink(filepath.Join(tmp, "secret"), filepath.Join(dir, "attachments", "link")))
+
+			require.NoError(ioutil.WriteFile(filepath.Join(dir, "schema.json"),
+				[]byte(`{"version":"1.0.0"}`), 0644))
+			require.NoError(ioutil.WriteFile(filepath.Join(dir, "attachments_000001.json"),
+				[]byte(`[{"url":"https://example.com/files/1","asset_name":"secret.txt","asset_url":"`+c.assetURL+`"}]`), 0644))
+
+			_, err := StoreAttachments(dir, filepath.Join(tmp, "store"))
+			require.Error(err)
+			require.Contains(err.Error(), "outside of the archive")
+
+			_, err = os.Stat(filepath.Join(tmp, "store"))
+			require.True(os.IsNotExist(err))
+		})
+	}
+}
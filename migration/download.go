@@ -0,0 +1,240 @@
+package migration
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// maxDownloadAttempts is the number of times the download of an archive is
+// tried before failing. A failed attempt resumes from the bytes already
+// downloaded.
+const maxDownloadAttempts = 5
+
+// downloadRetryInterval is the wait before retrying an interrupted download
+var downloadRetryInterval = time.Second
+
+// newDownloadClient returns the HTTP client used to download the archives.
+// There is no overall timeout because the archives can be big, but the
+// connection and the response headers have to arrive in time.
+func newDownloadClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: time.Minute,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+// errURLExpired is returned by downloadAttempt when the signed archive URL is
+// not valid anymore
+var errURLExpired = fmt.Errorf("the archive URL expired")
+
+// downloadArchive downloads the archive of the exported migration id to dst.
+// The file is written to dst.part, resuming with a Range request after an
+// interruption, and the archive URL is requested again when the signed URL
+// expires. Once complete the size and the gzip stream are verified, and the
+// file is renamed to dst. If dst already exists and is valid it is not
+// downloaded again.
+func (d GitHubMigrationDownloader) downloadArchive(ctx context.Context, logger log.Logger, owner string, id int64, dst string) error {
+	if err := verifyGzip(dst); err == nil {
+		logger.Infof("archive already downloaded to %v", dst)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp := dst + ".part"
+
+	var url string
+	var err error
+	for attempt := 1; attempt <= maxDownloadAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(downloadRetryInterval):
+			}
+		}
+
+		if url == "" {
+			url, err = d.client.Migrations.MigrationArchiveURL(ctx, owner, id)
+			if err != nil {
+				return fmt.Errorf("could not get the archive URL of migration %v for organization %v: %v", id, owner, err)
+			}
+
+			logger.With(log.Fields{"url": url}).Debugf("archive URL")
+		}
+
+		err = d.downloadAttempt(ctx, url, tmp)
+		if err == errURLExpired {
+			logger.With(log.Fields{"attempt": attempt}).Warningf("archive URL expired, requesting a new one")
+			url = ""
+			continue
+		}
+		if err != nil {
+			logger.With(log.Fields{"attempt": attempt}).Warningf("archive download interrupted: %v", err)
+			continue
+		}
+
+		err = verifyGzip(tmp)
+		if err != nil {
+			// the bytes downloaded are corrupted, start from scratch
+			logger.With(log.Fields{"attempt": attempt}).Warningf("archive verification failed: %v", err)
+			os.Remove(tmp)
+			continue
+		}
+
+		return os.Rename(tmp, dst)
+	}
+
+	return fmt.Errorf("could not download the archive of migration %v for organization %v after %v attempts: %v", id, owner, maxDownloadAttempts, err)
+}
+
+// downloadAttempt downloads url to the file tmp, continuing from its current
+// size. It returns an error if the download is interrupted or the size
+// received does not match the size announced by the server.
+func (d GitHubMigrationDownloader) downloadAttempt(ctx context.Context, url string, tmp string) error {
+	var offset int64
+	if info, err := os.Stat(tmp); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.downloadClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var size int64
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// the server sends the whole file, the Range header is ignored
+		flags |= os.O_TRUNC
+		offset = 0
+		size = resp.ContentLength
+	case http.StatusPartialContent:
+		var start int64
+		start, size, err = parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if start != offset {
+			return fmt.Errorf("unexpected Content-Range %q for offset %v", resp.Header.Get("Content-Range"), offset)
+		}
+		flags |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the file is complete
+		return nil
+	case http.StatusForbidden:
+		// S3 signed URLs return 403 once expired
+		return errURLExpired
+	default:
+		return fmt.Errorf("HTTP status %v", resp.Status)
+	}
+
+	out, err := os.OpenFile(tmp, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(out, resp.Body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if size >= 0 && offset+n != size {
+		return fmt.Errorf("downloaded %v bytes, expected %v", offset+n, size)
+	}
+
+	return nil
+}
+
+// parseContentRange returns the first byte and the total size of a
+// Content-Range header like "bytes 100-199/200". The size is -1 if unknown.
+func parseContentRange(h string) (start int64, size int64, err error) {
+	invalid := fmt.Errorf("invalid Content-Range %q", h)
+
+	if !strings.HasPrefix(h, "bytes ") {
+		return 0, 0, invalid
+	}
+
+	parts := strings.Split(strings.TrimPrefix(h, "bytes "), "/")
+	if len(parts) != 2 {
+		return 0, 0, invalid
+	}
+
+	i := strings.Index(parts[0], "-")
+	if i < 0 {
+		return 0, 0, invalid
+	}
+
+	start, err = strconv.ParseInt(parts[0][:i], 10, 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+
+	if parts[1] == "*" {
+		return start, -1, nil
+	}
+
+	size, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+
+	return start, size, nil
+}
+
+// verifyGzip reads the whole gzip file path, which checks its CRC and size
+func verifyGzip(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(ioutil.Discard, gz); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,157 @@
+package migration
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/fakegithub"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// exportedMigration starts a migration of org/repo in srv, and returns its ID
+// once it is exported
+func exportedMigration(t *testing.T, d *GitHubMigrationDownloader) int64 {
+	ctx := context.Background()
+
+	m, err := d.startMigration(ctx, "org", []string{"repo"})
+	require.NoError(t, err)
+
+	m, err = d.waitMigration(ctx, log.New(nil), "org", m)
+	require.NoError(t, err)
+
+	return m.GetID()
+}
+
+func countArchiveRequests(requests []string) (urls int, downloads int) {
+	for _, r := range requests {
+		switch {
+		case strings.HasPrefix(r, "GET /archives/"):
+			downloads++
+		case strings.HasPrefix(r, "GET /orgs/org/migrations/") && strings.HasSuffix(r, "/archive"):
+			urls++
+		}
+	}
+
+	return urls, downloads
+}
+
+func TestDownloadArchive(t *testing.T) {
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{
+		Owner:            "org",
+		Name:             "repo",
+		Issues:           50,
+		CommentsPerIssue: 3,
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	logger := log.New(nil)
+
+	t.Run("interrupted", func(t *testing.T) {
+		require := require.New(t)
+
+		d, _ := fakeDownloader(t, srv, dir)
+		id := exportedMigration(t, d)
+
+		srv.InterruptArchives = 2
+		_, before := countArchiveRequests(srv.Requests())
+
+		dst := filepath.Join(dir, "interrupted.tar.gz")
+		require.NoError(d.downloadArchive(ctx, logger, "org", id, dst))
+		require.NoError(verifyGzip(dst))
+
+		_, after := countArchiveRequests(srv.Requests())
+		require.Equal(3, after-before)
+
+		_, err := os.Stat(dst + ".part")
+		require.True(os.IsNotExist(err))
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		require := require.New(t)
+
+		d, _ := fakeDownloader(t, srv, dir)
+		id := exportedMigration(t, d)
+
+		url, err := d.client.Migrations.MigrationArchiveURL(ctx, "org", id)
+		require.NoError(err)
+
+		full := filepath.Join(dir, "full.tar.gz")
+		require.NoError(d.downloadAttempt(ctx, url, full))
+		b, err := ioutil.ReadFile(full)
+		require.NoError(err)
+
+		// the rest of a partial file is requested with a Range header, a
+		// full response would be appended and corrupt the gzip stream
+		partial := filepath.Join(dir, "partial.tar.gz")
+		require.NoError(ioutil.WriteFile(partial, b[:len(b)/3], 0644))
+		require.NoError(d.downloadAttempt(ctx, url, partial))
+		require.NoError(verifyGzip(partial))
+
+		// a complete file gets a 416
+		require.NoError(d.downloadAttempt(ctx, url, partial))
+		require.NoError(verifyGzip(partial))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		require := require.New(t)
+
+		d, _ := fakeDownloader(t, srv, dir)
+		id := exportedMigration(t, d)
+
+		srv.ArchiveURLExpiry = 1
+		defer func() { srv.ArchiveURLExpiry = 0 }()
+
+		urlsBefore, _ := countArchiveRequests(srv.Requests())
+
+		err := d.downloadArchive(ctx, logger, "org", id, filepath.Join(dir, "expired.tar.gz"))
+		require.Error(err)
+		require.Contains(err.Error(), "expired")
+
+		// a new URL is requested for each attempt
+		urls, _ := countArchiveRequests(srv.Requests())
+		require.Equal(maxDownloadAttempts, urls-urlsBefore)
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		require := require.New(t)
+
+		d, _ := fakeDownloader(t, srv, dir)
+		id := exportedMigration(t, d)
+
+		dst := filepath.Join(dir, "corrupted.tar.gz")
+		require.NoError(ioutil.WriteFile(dst, []byte("not a gzip file"), 0644))
+		require.NoError(ioutil.WriteFile(dst+".part", []byte("not a gzip file"), 0644))
+
+		require.NoError(d.downloadArchive(ctx, logger, "org", id, dst))
+		require.NoError(verifyGzip(dst))
+	})
+}
+
+func TestParseContentRange(t *testing.T) {
+	require := require.New(t)
+
+	start, size, err := parseContentRange("bytes 100-199/200")
+	require.NoError(err)
+	require.Equal(int64(100), start)
+	require.Equal(int64(200), size)
+
+	start, size, err = parseContentRange("bytes 0-99/*")
+	require.NoError(err)
+	require.Equal(int64(0), start)
+	require.Equal(int64(-1), size)
+
+	for _, h := range []string{"", "bytes", "bytes 100/200", "items 0-1/2", "bytes a-b/c"} {
+		_, _, err := parseContentRange(h)
+		require.Error(err, h)
+	}
+}
@@ -0,0 +1,98 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// UseLockRepositories makes the new migrations lock their repositories while
+// they are exported. They stay locked until UnlockRepositories is called, see
+// UseAutoCleanup.
+func (d *GitHubMigrationDownloader) UseLockRepositories() {
+	d.lockRepositories = true
+}
+
+// UseAutoCleanup makes DownloadRepository and DownloadOrg delete the archive
+// from GitHub, and unlock the repositories if the migration locked them, once
+// its contents are saved
+func (d *GitHubMigrationDownloader) UseAutoCleanup() {
+	d.autoCleanup = true
+}
+
+// ListMigrations returns the most recent migrations of the organization
+func (d GitHubMigrationDownloader) ListMigrations(owner string) ([]*github.Migration, error) {
+	migrations, _, err := d.client.Migrations.ListMigrations(context.Background(), owner)
+	return migrations, err
+}
+
+// DeleteArchive deletes the archive of the migration id. The migration is not
+// deleted, but its archive cannot be downloaded anymore.
+func (d GitHubMigrationDownloader) DeleteArchive(owner string, id int64) error {
+	_, err := d.client.Migrations.DeleteMigration(context.Background(), owner, id)
+	return err
+}
+
+// UnlockRepositories unlocks the repositories repos locked by the migration id.
+// If repos is empty all the repositories of the migration are unlocked.
+func (d GitHubMigrationDownloader) UnlockRepositories(owner string, id int64, repos []string) error {
+	ctx := context.Background()
+
+	if len(repos) == 0 {
+		m, _, err := d.client.Migrations.MigrationStatus(ctx, owner, id)
+		if err != nil {
+			return err
+		}
+
+		if !m.GetLockRepositories() {
+			return nil
+		}
+
+		repos = migrationRepositories(m)
+	}
+
+	return d.unlock(ctx, owner, id, repos)
+}
+
+func (d GitHubMigrationDownloader) unlock(ctx context.Context, owner string, id int64, repos []string) error {
+	var failed []string
+	for _, repo := range repos {
+		_, err := d.client.Migrations.UnlockRepo(ctx, owner, id, repo)
+		if err != nil {
+			log.With(log.Fields{"owner": owner, "repo": repo, "migration-id": id}).Errorf(err, "repository could not be unlocked")
+			failed = append(failed, repo)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d repositories of migration %v could not be unlocked: %v", len(failed), len(repos), id, failed)
+	}
+
+	return nil
+}
+
+// cleanup unlocks the repositories of the migration m if they were locked, and
+// then deletes its archive, when UseAutoCleanup is set. If the unlock fails
+// the archive is kept, so the unlock can be retried with the migration ID.
+func (d GitHubMigrationDownloader) cleanup(ctx context.Context, logger log.Logger, owner string, m *github.Migration) error {
+	if !d.autoCleanup {
+		return nil
+	}
+
+	if m.GetLockRepositories() {
+		err := d.unlock(ctx, owner, m.GetID(), migrationRepositories(m))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := d.client.Migrations.DeleteMigration(ctx, owner, m.GetID())
+	if err != nil {
+		return fmt.Errorf("the archive of migration %v was saved but could not be deleted: %v", m.GetID(), err)
+	}
+
+	logger.Infof("migration archive deleted")
+	return nil
+}
@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/fakegithub"
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHousekeeping(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(
+		fakegithub.NewRepository(fakegithub.Config{Owner: "org", Name: "repo", Issues: 1}),
+		fakegithub.NewRepository(fakegithub.Config{Owner: "org", Name: "other", Issues: 1}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	d, _ := fakeDownloader(t, srv, dir)
+	d.UseLockRepositories()
+	require.NoError(d.DownloadOrg("org", "v0"))
+
+	migrations, err := d.ListMigrations("org")
+	require.NoError(err)
+	require.Len(migrations, 1)
+
+	m := migrations[0]
+	require.Equal("exported", m.GetState())
+	require.True(m.GetLockRepositories())
+	require.ElementsMatch([]string{"repo", "other"}, srv.LockedRepositories(m.GetID()))
+
+	require.NoError(d.UnlockRepositories("org", m.GetID(), []string{"repo"}))
+	require.Equal([]string{"other"}, srv.LockedRepositories(m.GetID()))
+
+	require.NoError(d.UnlockRepositories("org", m.GetID(), nil))
+	require.Empty(srv.LockedRepositories(m.GetID()))
+
+	require.False(srv.ArchiveDeleted(m.GetID()))
+	require.NoError(d.DeleteArchive("org", m.GetID()))
+	require.True(srv.ArchiveDeleted(m.GetID()))
+
+	require.Error(d.DeleteArchive("org", 1))
+	require.Error(d.UnlockRepositories("org", 1, nil))
+}
+
+func TestDownloadRepositoryAutoCleanup(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{Owner: "org", Name: "repo", Issues: 1}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	d, storer := fakeDownloader(t, srv, dir)
+	d.UseLockRepositories()
+	d.UseAutoCleanup()
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+	require.Len(storer.issues, 1)
+
+	migrations, err := d.ListMigrations("org")
+	require.NoError(err)
+	require.Len(migrations, 1)
+
+	id := migrations[0].GetID()
+	require.True(srv.ArchiveDeleted(id))
+	require.Empty(srv.LockedRepositories(id))
+}
+
+// unlockFailingTransport responds with a 500 to the unlock requests
+type unlockFailingTransport struct {
+	T http.RoundTripper
+}
+
+func (t *unlockFailingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodDelete && strings.HasSuffix(req.URL.Path, "/lock") {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "500 Internal Server Error",
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(`{"message":"Server Error"}`)),
+			Request:    req,
+		}, nil
+	}
+
+	return t.T.RoundTrip(req)
+}
+
+func TestDownloadRepositoryAutoCleanupUnlockFails(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{Owner: "org", Name: "repo", Issues: 1}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	d, _ := fakeDownloader(t, srv, dir)
+	d.UseLockRepositories()
+	d.UseAutoCleanup()
+
+	c := d.client
+	d.client = github.NewClient(&http.Client{Transport: &unlockFailingTransport{T: srv.Client().Transport}})
+	d.client.BaseURL = c.BaseURL
+	require.Error(d.DownloadRepository("org", "repo", "v0"))
+	d.client = c
+
+	migrations, err := d.ListMigrations("org")
+	require.NoError(err)
+	require.Len(migrations, 1)
+
+	// the archive is kept, the unlock can be retried with the migration ID
+	id := migrations[0].GetID()
+	require.False(srv.ArchiveDeleted(id))
+	require.Equal([]string{"repo"}, srv.LockedRepositories(id))
+
+	require.NoError(d.UnlockRepositories("org", id, []string{"repo"}))
+	require.Empty(srv.LockedRepositories(id))
+}
@@ -0,0 +1,305 @@
+package migration
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+)
+
+// reviewStates maps the numeric review states of the archives to the GraphQL
+// values. 1 and 30 are confirmed by the reviews of carlosms-test-org/test-repo
+// downloaded with v4.
+var reviewStates = map[int]string{
+	0:  "PENDING",
+	1:  "COMMENTED",
+	30: "CHANGES_REQUESTED",
+	40: "APPROVED",
+	50: "DISMISSED",
+}
+
+// MapUser maps an archive user to the canonical type. The archives do not
+// include the database IDs, but the avatar URLs end in /u/<id>.
+func MapUser(u *User) *metadata.User {
+	var email string
+	for _, e := range u.Emails {
+		if e.Primary {
+			email = e.Address
+		}
+	}
+
+	return &metadata.User{
+		DatabaseID: avatarID(u.AvatarURL),
+		Login:      u.Login,
+		Type:       userType(u),
+		Name:       u.Name,
+		Company:    u.Company,
+		Location:   u.Location,
+		Email:      email,
+		CreatedAt:  u.CreatedAt,
+	}
+}
+
+// MapOrganization maps an archive organization to the canonical type
+func MapOrganization(o *Organization) *metadata.Organization {
+	return &metadata.Organization{
+		Login:       o.Login,
+		Name:        o.Name,
+		Description: o.Description,
+		CreatedAt:   o.CreatedAt,
+	}
+}
+
+// MapRepository maps an archive repository to the canonical type
+func MapRepository(r *Repository) *metadata.Repository {
+	return &metadata.Repository{
+		Owner:         lastSegment(r.Owner),
+		Name:          r.Name,
+		Description:   r.Description,
+		Homepage:      r.Website,
+		DefaultBranch: r.DefaultBranch,
+		Private:       r.Private,
+		CreatedAt:     r.CreatedAt,
+	}
+}
+
+// MapIssue maps an archive issue to the canonical type. The archives do not
+// include the database IDs of issues.
+func MapIssue(i *Issue) (*metadata.Issue, error) {
+	owner, name, number, err := parseURL(i.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	state := "OPEN"
+	if i.ClosedAt != nil {
+		state = "CLOSED"
+	}
+
+	assignees := make([]string, len(i.Assignees))
+	for j, a := range i.Assignees {
+		assignees[j] = lastSegment(a)
+	}
+
+	labels := make([]string, len(i.Labels))
+	for j, l := range i.Labels {
+		labels[j] = lastSegment(l)
+	}
+
+	return &metadata.Issue{
+		RepositoryOwner: owner,
+		RepositoryName:  name,
+		Number:          number,
+		Title:           i.Title,
+		Body:            i.Body,
+		State:           state,
+		Author:          lastSegment(i.User),
+		Assignees:       assignees,
+		Labels:          labels,
+		CreatedAt:       i.CreatedAt,
+		ClosedAt:        i.ClosedAt,
+	}, nil
+}
+
+// MapPullRequest maps an archive PR to the canonical type
+func MapPullRequest(pr *PullRequest) (*metadata.PullRequest, error) {
+	issue, err := MapIssue(&pr.Issue)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.MergedAt != nil {
+		issue.State = "MERGED"
+	}
+
+	return &metadata.PullRequest{
+		Issue:    *issue,
+		BaseRef:  pr.Base.Ref,
+		BaseSHA:  pr.Base.SHA,
+		HeadRef:  pr.Head.Ref,
+		HeadSHA:  pr.Head.SHA,
+		Merged:   pr.MergedAt != nil,
+		MergedAt: pr.MergedAt,
+	}, nil
+}
+
+// MapIssueComment maps an archive comment, of an issue or a PR, to the
+// canonical type. The database ID is taken from the URL fragment.
+func MapIssueComment(c *IssueComment) (*metadata.Comment, error) {
+	owner, name, number, err := parseURL(c.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata.Comment{
+		DatabaseID:      fragmentID(c.URL),
+		RepositoryOwner: owner,
+		RepositoryName:  name,
+		Number:          number,
+		Author:          lastSegment(c.User),
+		Body:            c.Body,
+		CreatedAt:       c.CreatedAt,
+	}, nil
+}
+
+// MapReview maps an archive PR review to the canonical type
+func MapReview(r *PullRequestReview) (*metadata.Review, error) {
+	owner, name, number, err := parseURL(r.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	state, ok := reviewStates[r.State]
+	if !ok {
+		state = strconv.Itoa(r.State)
+	}
+
+	return &metadata.Review{
+		DatabaseID:        fragmentID(r.URL),
+		RepositoryOwner:   owner,
+		RepositoryName:    name,
+		PullRequestNumber: number,
+		Author:            lastSegment(r.User),
+		Body:              r.Body,
+		State:             state,
+		CommitID:          r.HeadSHA,
+		SubmittedAt:       r.SubmittedAt,
+	}, nil
+}
+
+// MapReviewComment maps an archive PR review comment to the canonical type
+func MapReviewComment(c *PullRequestReviewComment) (*metadata.ReviewComment, error) {
+	owner, name, number, err := parseURL(c.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata.ReviewComment{
+		DatabaseID:        fragmentID(c.URL),
+		RepositoryOwner:   owner,
+		RepositoryName:    name,
+		PullRequestNumber: number,
+		ReviewID:          fragmentID(c.PullRequestReview),
+		Author:            lastSegment(c.User),
+		Body:              c.Body,
+		Path:              c.Path,
+		Position:          c.Position,
+		OriginalPosition:  c.OriginalPosition,
+		CommitID:          c.CommitID,
+		DiffHunk:          c.DiffHunk,
+		CreatedAt:         c.CreatedAt,
+	}, nil
+}
+
+// MapEvent maps an archive issue or PR event to the canonical type
+func MapEvent(e *IssueEvent) (*metadata.Event, error) {
+	owner, name, number, err := parseURL(e.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata.Event{
+		DatabaseID:      fragmentID(e.URL),
+		RepositoryOwner: owner,
+		RepositoryName:  name,
+		Number:          number,
+		Event:           e.Event,
+		Actor:           lastSegment(e.Actor),
+		Subject:         lastSegment(e.Subject),
+		Label:           e.LabelName,
+		CreatedAt:       e.CreatedAt,
+	}, nil
+}
+
+// parseURL returns the repository owner, name and the issue or PR number of a
+// github.com URL like https://github.com/owner/name/pull/3/files#r318617070
+func parseURL(u string) (owner, name string, number int, err error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 4 || (parts[2] != "issues" && parts[2] != "pull") {
+		return "", "", 0, fmt.Errorf("unexpected GitHub URL %q", u)
+	}
+
+	number, err = strconv.Atoi(parts[3])
+	if err != nil {
+		return "", "", 0, fmt.Errorf("unexpected GitHub URL %q", u)
+	}
+
+	return parts[0], parts[1], number, nil
+}
+
+// fragmentID returns the database ID at the end of the URL fragment, e.g.
+// 525672410 for #issuecomment-525672410 or 318617070 for #r318617070. It
+// returns 0 if there is none.
+func fragmentID(u string) int64 {
+	i := strings.LastIndex(u, "#")
+	if i < 0 {
+		return 0
+	}
+
+	fragment := u[i+1:]
+	start := len(fragment)
+	for start > 0 && fragment[start-1] >= '0' && fragment[start-1] <= '9' {
+		start--
+	}
+
+	id, err := strconv.ParseInt(fragment[start:], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// userType maps the lowercase type of an archive user to the actor types,
+// the deleted accounts are the ghost user
+func userType(u *User) string {
+	if u.Login == metadata.Ghost.Login {
+		return metadata.GhostType
+	}
+
+	switch u.Type {
+	case "bot":
+		return metadata.BotType
+	case "mannequin":
+		return metadata.MannequinType
+	case "organization":
+		return metadata.OrganizationType
+	}
+
+	return metadata.UserType
+}
+
+// avatarID returns the user ID of an avatar URL like
+// https://avatars0.githubusercontent.com/u/1469173?v=4, or 0 if the URL does
+// not have one
+func avatarID(u string) int64 {
+	parsed, err := url.Parse(u)
+	if err != nil || !strings.HasPrefix(parsed.Path, "/u/") {
+		return 0
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(parsed.Path, "/u/"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// lastSegment returns the last path segment of a user, organization or label
+// URL, e.g. carlosms for https://github.com/carlosms. It returns "" for "".
+func lastSegment(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Path == "" {
+		return ""
+	}
+
+	return parsed.Path[strings.LastIndex(parsed.Path, "/")+1:]
+}
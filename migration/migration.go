@@ -3,136 +3,337 @@ package migration
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/carlosms/metadata-retrieval-playground"
 	"github.com/carlosms/metadata-retrieval-playground/internal/client"
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
 	"github.com/google/go-github/github"
 	"github.com/mholt/archiver"
+	"go.opentelemetry.io/otel/attribute"
 	"gopkg.in/src-d/go-log.v1"
 )
 
+// pollInterval is the initial wait between the migration status requests, it
+// doubles after each request up to maxPollInterval
+var (
+	pollInterval    = time.Second
+	maxPollInterval = 30 * time.Second
+)
+
+// defaultPollTimeout is the default time to wait for a migration to be
+// exported
+const defaultPollTimeout = time.Hour
+
 type GitHubMigrationDownloader struct {
-	//storer
+	storer metadata.Storer
 
-	client *github.Client
-	token  string
+	client         *github.Client
+	downloadClient *http.Client
+	token          string
+	dir            string
+	orgFilter      OrgFilter
+
+	// migrationID is the migration to resume instead of starting a new one
+	migrationID int64
+	pollTimeout time.Duration
+
+	lockRepositories bool
+	autoCleanup      bool
+	// attachmentsDir is where the attachments are stored, if empty they are
+	// excluded from the migrations
+	attachmentsDir string
+	// repositoryStorage is where the git repositories are extracted, if
+	// empty they are ignored
+	repositoryStorage string
 }
 
 var _ metadata.MetadataDownloader = GitHubMigrationDownloader{}
 
-func NewMigrationDownloader(httpClient *http.Client) (*GitHubMigrationDownloader, error) {
+// NewMigrationDownloader returns a downloader that saves the contents of the
+// migration archives to s
+func NewMigrationDownloader(httpClient *http.Client, s metadata.Storer) (*GitHubMigrationDownloader, error) {
 	c, err := client.NewClient(httpClient)
 	if err != nil {
 		return nil, err
 	}
 
 	return &GitHubMigrationDownloader{
-		client: github.NewClient(c),
+		storer:         s,
+		client:         github.NewClient(c),
+		downloadClient: newDownloadClient(),
+		dir:            "downloads",
+		pollTimeout:    defaultPollTimeout,
 	}, nil
 }
 
-func (d GitHubMigrationDownloader) DownloadRepository(owner string, name string, version string) error {
+// UseMigrationID makes DownloadRepository and DownloadOrg resume the existing
+// migration id, waiting for it to be exported or downloading its archive
+// again, instead of starting a new migration
+func (d *GitHubMigrationDownloader) UseMigrationID(id int64) {
+	d.migrationID = id
+}
+
+// SetPollTimeout sets the maximum time to wait for a migration to be exported
+func (d *GitHubMigrationDownloader) SetPollTimeout(timeout time.Duration) {
+	d.pollTimeout = timeout
+}
+
+func (d GitHubMigrationDownloader) DownloadRepository(owner string, name string, version string) (err error) {
 	logger := log.New(log.Fields{"owner": owner, "repo": name})
 
+	ctx, span := tracing.Start(context.Background(), "DownloadRepository",
+		attribute.String("owner", owner),
+		attribute.String("repo", name),
+		attribute.String("version", version))
+	defer func() { tracing.End(span, err) }()
+
 	t0 := time.Now()
 
-	opt := github.MigrationOptions{
-		LockRepositories:   false,
-		ExcludeAttachments: true,
+	var migration *github.Migration
+	if d.migrationID != 0 {
+		migration, err = d.resumeMigration(ctx, owner)
+		if err != nil {
+			return err
+		}
+
+		if !containsRepository(migration, name) {
+			return fmt.Errorf("migration %v for organization %v does not include the repository %v", d.migrationID, owner, name)
+		}
+
+		logger = logger.With(log.Fields{"migration-id": migration.GetID()})
+		logger.With(log.Fields{"state": migration.GetState()}).Infof("migration resumed")
+	} else {
+		migration, err = d.startMigration(ctx, owner, []string{name})
+		if err != nil {
+			return err
+		}
+
+		logger = logger.With(log.Fields{"migration-id": migration.GetID()})
+		logger.With(log.Fields{"state": migration.GetState()}).Infof("migration started")
 	}
-	migration, _, err := d.client.Migrations.StartMigration(context.TODO(), owner, []string{name}, &opt)
+
+	path, err := d.fetchArchive(ctx, logger, owner, migration)
 	if err != nil {
 		return err
 	}
 
-	logger = logger.With(log.Fields{"migration-id": migration.GetID()})
-	logger.With(log.Fields{"state": migration.GetState()}).Infof("migration started")
+	t1 := time.Now()
+
+	err = d.load(ctx, path, version)
+	if err != nil {
+		return err
+	}
+
+	elapsed := time.Since(t1)
+	logger.With(log.Fields{"elapsed": elapsed}).Infof("archive contents saved")
+
+	err = d.cleanup(ctx, logger, owner, migration)
+	if err != nil {
+		return err
+	}
+
+	elapsed = time.Since(t0)
+	logger.With(log.Fields{"total-elapsed": elapsed}).Infof("done")
+
+	return nil
+}
+
+// startMigration starts the export of the repositories repos of the
+// organization owner
+func (d GitHubMigrationDownloader) startMigration(ctx context.Context, owner string, repos []string) (*github.Migration, error) {
+	opt := github.MigrationOptions{
+		LockRepositories:   d.lockRepositories,
+		ExcludeAttachments: d.attachmentsDir == "",
+	}
+
+	migration, _, err := d.client.Migrations.StartMigration(ctx, owner, repos, &opt)
+	return migration, err
+}
+
+// resumeMigration returns the status of the migration set with UseMigrationID
+func (d GitHubMigrationDownloader) resumeMigration(ctx context.Context, owner string) (*github.Migration, error) {
+	migration, _, err := d.client.Migrations.MigrationStatus(ctx, owner, d.migrationID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get migration %v for organization %v: %v", d.migrationID, owner, err)
+	}
+
+	return migration, nil
+}
+
+// waitMigration polls the status of the migration until it is exported. The
+// wait between requests starts at pollInterval and doubles up to
+// maxPollInterval. It fails if the migration fails or is not exported after
+// the poll timeout.
+func (d GitHubMigrationDownloader) waitMigration(ctx context.Context, logger log.Logger, owner string, migration *github.Migration) (*github.Migration, error) {
+	id := migration.GetID()
+	deadline := time.Now().Add(d.pollTimeout)
+	wait := pollInterval
 
 	// pending, which means the migration hasn't started yet.
 	// exporting, which means the migration is in progress.
 	// exported, which means the migration finished successfully.
 	// failed, which means the migration failed.
 
-	for migration.GetState() != "exported" {
-		logger.With(log.Fields{"state": migration.GetState()}).Infof("waiting for migration to be ready")
-		time.Sleep(time.Second)
-		migration, _, err = d.client.Migrations.MigrationStatus(context.TODO(), owner, migration.GetID())
+	for {
+		switch migration.GetState() {
+		case "exported":
+			return migration, nil
+		case "failed":
+			return nil, fmt.Errorf("migration %v for organization %v returned state 'failed'", id, owner)
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			return nil, fmt.Errorf("migration %v for organization %v not exported after %v, state %v", id, owner, d.pollTimeout, migration.GetState())
+		}
+
+		logger.With(log.Fields{"state": migration.GetState(), "wait": wait}).Infof("waiting for migration to be ready")
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
 
-		if migration.GetState() == "failed" {
-			return fmt.Errorf("migration %v for organization %v returned state 'failed'", migration.GetID(), owner)
+		wait *= 2
+		if wait > maxPollInterval {
+			wait = maxPollInterval
+		}
+
+		var err error
+		migration, _, err = d.client.Migrations.MigrationStatus(ctx, owner, id)
+		if err != nil {
+			return nil, fmt.Errorf("could not get the status of migration %v for organization %v: %v", id, owner, err)
 		}
 	}
+}
+
+// fetchArchive waits until the migration is exported, and downloads and
+// extracts its archive. It returns the path of the extracted directory.
+func (d GitHubMigrationDownloader) fetchArchive(ctx context.Context, logger log.Logger, owner string, migration *github.Migration) (string, error) {
+	t0 := time.Now()
 
-	url, err := d.client.Migrations.MigrationArchiveURL(context.TODO(), owner, migration.GetID())
+	migration, err := d.waitMigration(ctx, logger, owner, migration)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	elapsed := time.Since(t0)
-	logger.With(log.Fields{"elapsed": elapsed, "state": migration.GetState(), "url": url}).Infof("migration ready to download")
+	logger.With(log.Fields{"elapsed": elapsed, "state": migration.GetState()}).Infof("migration ready to download")
 
 	t1 := time.Now()
 
-	path := filepath.Join("downloads", fmt.Sprintf("%v-%v", owner, migration.GetID()))
+	path := filepath.Join(d.dir, fmt.Sprintf("%v-%v", owner, migration.GetID()))
 	pathgz := path + ".tar.gz"
 
-	err = downloadURL(url, pathgz)
+	err = d.downloadArchive(ctx, logger, owner, migration.GetID(), pathgz)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	elapsed = time.Since(t1)
 	logger.With(log.Fields{"elapsed": elapsed}).Infof("file downloaded to %v", pathgz)
 	t2 := time.Now()
 
+	// a resumed migration may have been extracted before
+	err = os.RemoveAll(path)
+	if err != nil {
+		return "", err
+	}
+
 	err = archiver.Unarchive(pathgz, path)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	elapsed = time.Since(t2)
 	logger.With(log.Fields{"elapsed": elapsed}).Infof("file uncompressed in %v", path)
 
-	elapsed = time.Since(t0)
-	logger.With(log.Fields{"total-elapsed": elapsed}).Infof("done")
-
-	return nil
+	return path, nil
 }
 
-func (d GitHubMigrationDownloader) DownloadOrg(name string, version string) error {
-	return fmt.Errorf("not implemented")
+// UseAttachments includes the attachments of issues and comments in the new
+// migrations. Their files are stored in dir, see StoreAttachments, and their
+// URLs in the bodies are rewritten to the stored paths.
+func (d *GitHubMigrationDownloader) UseAttachments(dir string) {
+	d.attachmentsDir = dir
 }
 
-func (d GitHubMigrationDownloader) SetCurrent(version string) error {
-	return fmt.Errorf("not implemented")
+// UseRepositoryStorage moves the git repositories of the archives to dir, see
+// ExtractRepositories
+func (d *GitHubMigrationDownloader) UseRepositoryStorage(dir string) {
+	d.repositoryStorage = dir
 }
 
-func downloadURL(url, dst string) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+// load saves the contents of the archive extracted in dir, in one transaction
+func (d GitHubMigrationDownloader) load(ctx context.Context, dir string, version string) (err error) {
+	if d.repositoryStorage != "" {
+		repos, err := ExtractRepositories(dir, d.repositoryStorage)
+		if err != nil {
+			return err
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP status %v", resp.Status)
+		for _, r := range repos {
+			log.With(log.Fields{
+				"owner":           r.Owner,
+				"repo":            r.Name,
+				"path":            r.Path,
+				"pull-requests":   r.PullRequests,
+				"missing-commits": r.MissingCommits,
+			}).Infof("git repository extracted")
+		}
 	}
 
-	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
-		return err
+	var attachments Attachments
+	if d.attachmentsDir != "" {
+		attachments, err = StoreAttachments(dir, d.attachmentsDir)
+		if err != nil {
+			return err
+		}
+
+		log.With(log.Fields{"attachments": len(attachments), "dir": d.attachmentsDir}).Infof("attachments stored")
 	}
 
-	out, err := os.Create(dst)
+	d.storer.Version(version)
+
+	err = d.storer.Begin()
 	if err != nil {
 		return err
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+	defer func() {
+		if err != nil {
+			d.storer.Rollback()
+			return
+		}
+
+		err = d.storer.Commit()
+	}()
+
+	return LoadWithAttachments(ctx, dir, d.storer, attachments)
+}
+
+func (d GitHubMigrationDownloader) SetCurrent(version string) error {
+	return d.storer.SetActiveVersion(version)
+}
+
+// Cleanup deletes from the DB all records that do not belong to the currentVersion
+func (d GitHubMigrationDownloader) Cleanup(currentVersion string) error {
+	return d.storer.Cleanup(currentVersion)
+}
+
+// containsRepository returns true if the migration includes the repository
+// name
+func containsRepository(migration *github.Migration, name string) bool {
+	for _, r := range migration.Repositories {
+		if strings.EqualFold(r.GetName(), name) {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,238 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
+	"github.com/google/go-github/github"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// maxMigrationRepositories is the maximum number of repositories GitHub
+// accepts in one migration, see
+// https://docs.github.com/en/rest/migrations/orgs#start-an-organization-migration
+const maxMigrationRepositories = 100
+
+const listOptionsPerPage = 100
+
+// OrgFilter selects the repositories downloaded by DownloadOrg
+type OrgFilter struct {
+	// Repositories are the names of the repositories to download. Empty
+	// means all the repositories of the organization.
+	Repositories []string
+	// SkipArchived skips the archived repositories
+	SkipArchived bool
+	// SkipForks skips the forks
+	SkipForks bool
+}
+
+// SetOrgFilter sets the filter for the repositories downloaded by DownloadOrg
+func (d *GitHubMigrationDownloader) SetOrgFilter(f OrgFilter) {
+	d.orgFilter = f
+}
+
+func (f OrgFilter) skip(r *github.Repository) bool {
+	if (f.SkipArchived && r.GetArchived()) || (f.SkipForks && r.GetFork()) {
+		return true
+	}
+
+	if len(f.Repositories) == 0 {
+		return false
+	}
+
+	for _, name := range f.Repositories {
+		if strings.EqualFold(name, r.GetName()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DownloadOrg exports the repositories of the organization that pass the
+// filter set with SetOrgFilter, and saves the contents of the archives. The
+// repositories are split in migrations of at most 100 repositories, each
+// archive is saved in its own transaction. A failure in one migration does not
+// stop the rest, the repositories of each archive are logged and an error is
+// returned if any migration failed. With UseMigrationID only the repositories
+// of that migration are downloaded.
+func (d GitHubMigrationDownloader) DownloadOrg(name string, version string) (err error) {
+	logger := log.New(log.Fields{"org": name})
+
+	ctx, span := tracing.Start(context.Background(), "DownloadOrg",
+		attribute.String("org", name),
+		attribute.String("version", version))
+	defer func() { tracing.End(span, err) }()
+
+	t0 := time.Now()
+
+	var (
+		repos      []string
+		groups     [][]string
+		migrations []*github.Migration
+		failed     []string
+	)
+
+	if d.migrationID != 0 {
+		m, err := d.resumeMigration(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		repos = migrationRepositories(m)
+		groups = [][]string{repos}
+		migrations = []*github.Migration{m}
+
+		logger.With(log.Fields{
+			"migration-id": m.GetID(),
+			"state":        m.GetState(),
+			"repositories": repos,
+		}).Infof("migration resumed")
+	} else {
+		repos, err = d.listRepositories(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		if len(repos) == 0 {
+			return fmt.Errorf("organization %s has no repositories to download", name)
+		}
+
+		groups = batches(repos, maxMigrationRepositories)
+		logger.With(log.Fields{
+			"repositories": len(repos),
+			"migrations":   len(groups),
+		}).Infof("organization repositories listed")
+
+		// all the migrations are started first, GitHub exports them
+		// concurrently
+		migrations = make([]*github.Migration, len(groups))
+		for i, batch := range groups {
+			migrations[i], err = d.startMigration(ctx, name, batch)
+			if err != nil {
+				logger.With(log.Fields{"repositories": batch}).Errorf(err, "migration could not be started")
+				failed = append(failed, batch...)
+				continue
+			}
+
+			logger.With(log.Fields{
+				"migration-id": migrations[i].GetID(),
+				"repositories": batch,
+			}).Infof("migration started")
+		}
+	}
+
+	for i, m := range migrations {
+		if m == nil {
+			continue
+		}
+
+		l := logger.With(log.Fields{"migration-id": m.GetID()})
+		err := d.downloadMigration(ctx, l, name, m, version)
+		if err != nil {
+			l.With(log.Fields{"repositories": groups[i]}).Errorf(err, "migration download failed")
+			failed = append(failed, groups[i]...)
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("repositories", len(repos)),
+		attribute.Int("repositories.failed", len(failed)),
+		attribute.Int("migrations", len(groups)))
+
+	logger.With(log.Fields{
+		"repositories":  len(repos),
+		"migrations":    len(groups),
+		"succeeded":     len(repos) - len(failed),
+		"failed":        failed,
+		"total-elapsed": time.Since(t0),
+	}).Infof("All organization metadata fetched")
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d repositories of %s failed: %v", len(failed), len(repos), name, failed)
+	}
+
+	return nil
+}
+
+// downloadMigration downloads the archive of the started migration m and
+// saves its contents
+func (d GitHubMigrationDownloader) downloadMigration(ctx context.Context, logger log.Logger, owner string, m *github.Migration, version string) error {
+	path, err := d.fetchArchive(ctx, logger, owner, m)
+	if err != nil {
+		return err
+	}
+
+	t0 := time.Now()
+
+	err = d.load(ctx, path, version)
+	if err != nil {
+		return err
+	}
+
+	logger.With(log.Fields{
+		"elapsed":      time.Since(t0),
+		"path":         path,
+		"repositories": migrationRepositories(m),
+	}).Infof("archive contents saved")
+
+	return d.cleanup(ctx, logger, owner, m)
+}
+
+// listRepositories returns the names of the repositories of the organization
+// that pass the filter
+func (d GitHubMigrationDownloader) listRepositories(ctx context.Context, name string) ([]string, error) {
+	opts := &github.RepositoryListByOrgOptions{}
+	opts.ListOptions.PerPage = listOptionsPerPage
+
+	var repos []string
+	for {
+		page, r, err := d.client.Repositories.ListByOrg(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range page {
+			if !d.orgFilter.skip(repo) {
+				repos = append(repos, repo.GetName())
+			}
+		}
+
+		if r.NextPage == 0 {
+			break
+		}
+
+		opts.Page = r.NextPage
+	}
+
+	return repos, nil
+}
+
+// batches splits the repository names in groups of at most size names
+func batches(repos []string, size int) [][]string {
+	var result [][]string
+	for len(repos) > size {
+		result = append(result, repos[:size])
+		repos = repos[size:]
+	}
+
+	if len(repos) > 0 {
+		result = append(result, repos)
+	}
+
+	return result
+}
+
+// migrationRepositories returns the names of the repositories of the migration
+func migrationRepositories(m *github.Migration) []string {
+	names := make([]string, len(m.Repositories))
+	for i, r := range m.Repositories {
+		names[i] = r.GetName()
+	}
+
+	return names
+}
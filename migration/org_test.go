@@ -0,0 +1,234 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/fakegithub"
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDownloader returns a downloader for the fake server srv, that extracts
+// the archives in dir
+func fakeDownloader(t *testing.T, srv *fakegithub.Server, dir string) (*GitHubMigrationDownloader, *memoryStorer) {
+	c := github.NewClient(srv.Client())
+
+	var err error
+	c.BaseURL, err = url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+
+	storer := &memoryStorer{}
+	return &GitHubMigrationDownloader{
+		storer:         storer,
+		client:         c,
+		downloadClient: newDownloadClient(),
+		dir:            dir,
+		pollTimeout:    defaultPollTimeout,
+	}, storer
+}
+
+func init() {
+	pollInterval = time.Millisecond
+	maxPollInterval = 4 * time.Millisecond
+	downloadRetryInterval = time.Millisecond
+}
+
+func TestDownloadRepository(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       3,
+		CommentsPerIssue:             2,
+		PullRequests:                 2,
+		CommentsPerPullRequest:       1,
+		ReviewsPerPullRequest:        1,
+		CommentsPerPullRequestReview: 2,
+	}))
+	defer srv.Close()
+	srv.ExportPolls = 3
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	d, storer := fakeDownloader(t, srv, dir)
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	require.Len(storer.orgs, 1)
+	require.Len(storer.repositories, 1)
+	require.Equal("repo", storer.repositories[0].Name)
+	require.Len(storer.issues, 3)
+	require.Len(storer.prs, 2)
+	require.Len(storer.comments, 3*2+2*1)
+	require.Len(storer.reviews, 2)
+	require.Len(storer.reviewComments, 2*2)
+}
+
+func TestDownloadRepositoryResume(t *testing.T) {
+	srv := fakegithub.NewServer(
+		fakegithub.NewRepository(fakegithub.Config{Owner: "org", Name: "repo", Issues: 2}),
+		fakegithub.NewRepository(fakegithub.Config{Owner: "org", Name: "other", Issues: 1}))
+	defer srv.Close()
+	srv.ExportPolls = 3
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d, _ := fakeDownloader(t, srv, dir)
+	m, err := d.startMigration(context.Background(), "org", []string{"repo"})
+	require.NoError(t, err)
+
+	t.Run("resume", func(t *testing.T) {
+		require := require.New(t)
+
+		before := countMigrations(srv.Requests())
+
+		d, storer := fakeDownloader(t, srv, dir)
+		d.UseMigrationID(m.GetID())
+		require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+		require.Equal(before, countMigrations(srv.Requests()))
+		require.Len(storer.repositories, 1)
+		require.Len(storer.issues, 2)
+
+		// an exported migration can be downloaded again
+		d, storer = fakeDownloader(t, srv, dir)
+		d.UseMigrationID(m.GetID())
+		require.NoError(d.DownloadRepository("org", "repo", "v1"))
+		require.Len(storer.issues, 2)
+	})
+
+	t.Run("org", func(t *testing.T) {
+		require := require.New(t)
+
+		d, storer := fakeDownloader(t, srv, dir)
+		d.UseMigrationID(m.GetID())
+		require.NoError(d.DownloadOrg("org", "v0"))
+
+		require.Len(storer.repositories, 1)
+		require.Equal("repo", storer.repositories[0].Name)
+	})
+
+	t.Run("other repository", func(t *testing.T) {
+		d, _ := fakeDownloader(t, srv, dir)
+		d.UseMigrationID(m.GetID())
+
+		err := d.DownloadRepository("org", "other", "v0")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "does not include the repository other")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		d, _ := fakeDownloader(t, srv, dir)
+		d.UseMigrationID(1)
+		require.Error(t, d.DownloadRepository("org", "repo", "v0"))
+	})
+}
+
+func TestDownloadRepositoryTimeout(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{Owner: "org", Name: "repo"}))
+	defer srv.Close()
+	srv.ExportPolls = 1000
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	d, _ := fakeDownloader(t, srv, dir)
+	d.SetPollTimeout(50 * time.Millisecond)
+
+	err = d.DownloadRepository("org", "repo", "v0")
+	require.Error(err)
+	require.Contains(err.Error(), "not exported after 50ms")
+}
+
+func TestDownloadOrg(t *testing.T) {
+	var repos []*fakegithub.Repository
+	for i := 0; i < 150; i++ {
+		repos = append(repos, fakegithub.NewRepository(fakegithub.Config{
+			Owner:  "org",
+			Name:   fmt.Sprintf("repo-%03d", i),
+			Issues: 1,
+		}))
+	}
+	repos[0].Fork = true
+	repos[1].Archived = true
+
+	srv := fakegithub.NewServer(repos...)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name       string
+		filter     OrgFilter
+		repos      int
+		migrations int
+	}{
+		{"all", OrgFilter{}, 150, 2},
+		{"no forks or archived", OrgFilter{SkipForks: true, SkipArchived: true}, 148, 2},
+		{"list", OrgFilter{Repositories: []string{"repo-000", "repo-149"}}, 2, 1},
+		{"list without forks", OrgFilter{Repositories: []string{"repo-000", "repo-149"}, SkipForks: true}, 1, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require := require.New(t)
+
+			before := countMigrations(srv.Requests())
+
+			d, storer := fakeDownloader(t, srv, dir)
+			d.SetOrgFilter(c.filter)
+			require.NoError(d.DownloadOrg("org", "v0"))
+
+			require.Equal(c.migrations, countMigrations(srv.Requests())-before)
+			require.Len(storer.orgs, c.migrations)
+			require.Len(storer.repositories, c.repos)
+			require.Len(storer.issues, c.repos)
+		})
+	}
+
+	t.Run("no repositories", func(t *testing.T) {
+		d, _ := fakeDownloader(t, srv, dir)
+		d.SetOrgFilter(OrgFilter{Repositories: []string{"missing"}})
+		require.Error(t, d.DownloadOrg("org", "v0"))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		d, _ := fakeDownloader(t, srv, dir)
+		require.Error(t, d.DownloadOrg("missing", "v0"))
+	})
+}
+
+func countMigrations(requests []string) int {
+	var n int
+	for _, r := range requests {
+		if strings.HasPrefix(r, "POST /orgs/org/migrations") {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestBatches(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(batches(nil, 2))
+	require.Equal([][]string{{"a", "b"}}, batches([]string{"a", "b"}, 2))
+	require.Equal([][]string{{"a", "b"}, {"c"}}, batches([]string{"a", "b", "c"}, 2))
+}
@@ -0,0 +1,203 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// GitRepository is a bare git repository extracted from a migration archive
+type GitRepository struct {
+	Owner string
+	Name  string
+	Path  string
+	// PullRequests is the number of PRs of the repository in the archive
+	PullRequests int
+	// MissingCommits are the base and head SHAs of the PRs that are not in
+	// the repository, e.g. the head of a PR from a deleted fork
+	MissingCommits []string
+}
+
+// ExtractRepositories moves the bare git repositories of the archive extracted
+// in dir, repositories/<owner>/<name>.git, to <storageDir>/<owner>/<name>.git,
+// replacing any previous copy. Each repository is opened with go-git and all
+// its references must point to existing objects. The base and head commits of
+// the PRs are recorded as the references refs/pull/<number>/base and
+// refs/pull/<number>/head, so the metadata can be joined with the code, e.g.
+// with git log refs/pull/3/base..refs/pull/3/head.
+func ExtractRepositories(dir string, storageDir string) ([]*GitRepository, error) {
+	archive, err := openArchive(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "repositories", "*", "*.git"))
+	if err != nil {
+		return nil, err
+	}
+
+	var prs []PullRequest
+	byRepo := make(map[string][]PullRequest)
+	err = archive.readFiles("pull_requests", &prs, func() error {
+		for _, pr := range prs {
+			owner, name, _, err := parseURL(pr.URL)
+			if err != nil {
+				return err
+			}
+
+			key := strings.ToLower(owner + "/" + name)
+			byRepo[key] = append(byRepo[key], pr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []*GitRepository
+	for _, src := range paths {
+		owner := filepath.Base(filepath.Dir(src))
+		name := strings.TrimSuffix(filepath.Base(src), ".git")
+
+		r := &GitRepository{
+			Owner: owner,
+			Name:  name,
+			Path:  filepath.Join(storageDir, owner, name+".git"),
+		}
+
+		err := moveRepository(src, r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not move repository %s/%s: %v", owner, name, err)
+		}
+
+		repo, err := git.PlainOpen(r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open repository %s/%s: %v", owner, name, err)
+		}
+
+		err = validateRepository(repo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository %s/%s: %v", owner, name, err)
+		}
+
+		for _, pr := range byRepo[strings.ToLower(owner+"/"+name)] {
+			_, _, number, err := parseURL(pr.URL)
+			if err != nil {
+				return nil, err
+			}
+
+			r.PullRequests++
+
+			for kind, sha := range map[string]string{"base": pr.Base.SHA, "head": pr.Head.SHA} {
+				if sha == "" {
+					continue
+				}
+
+				hash := plumbing.NewHash(sha)
+				if _, err := repo.CommitObject(hash); err != nil {
+					r.MissingCommits = append(r.MissingCommits, sha)
+					continue
+				}
+
+				ref := plumbing.NewHashReference(plumbing.ReferenceName(fmt.Sprintf("refs/pull/%d/%s", number, kind)), hash)
+				if err := repo.Storer.SetReference(ref); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		repos = append(repos, r)
+	}
+
+	return repos, nil
+}
+
+// validateRepository checks that all the references of repo point to objects
+// in the repository
+func validateRepository(repo *git.Repository) error {
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	return refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		if _, err := repo.Object(plumbing.AnyObject, ref.Hash()); err != nil {
+			return fmt.Errorf("reference %s points to %s: %v", ref.Name(), ref.Hash(), err)
+		}
+
+		return nil
+	})
+}
+
+// moveRepository moves the bare repository src to dst. The archives do not
+// include the empty directories, the ones required by git are created.
+func moveRepository(src string, dst string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+
+	// rename fails across file systems, then it is copied
+	if err := os.Rename(src, dst); err != nil {
+		if err := copyDir(src, dst); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range []string{"refs/heads", "refs/tags", "objects/info"} {
+		if err := os.MkdirAll(filepath.Join(dst, d), os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyDir copies the directory src to dst recursively
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode()|0700)
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(out, in)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
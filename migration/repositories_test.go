@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const sampleArchive = "../downloads/carlosms-test-org-133497"
+
+// copySample copies the sample archive to a temporary directory, the
+// repositories are moved out of it
+func copySample(t *testing.T) (string, func()) {
+	tmp, err := ioutil.TempDir("", "migration")
+	require.NoError(t, err)
+
+	dir := filepath.Join(tmp, "archive")
+	require.NoError(t, copyDir(sampleArchive, dir))
+
+	return dir, func() { os.RemoveAll(tmp) }
+}
+
+func TestExtractRepositories(t *testing.T) {
+	require := require.New(t)
+
+	dir, done := copySample(t)
+	defer done()
+
+	storage := filepath.Join(filepath.Dir(dir), "repositories")
+	repos, err := ExtractRepositories(dir, storage)
+	require.NoError(err)
+	require.Len(repos, 1)
+
+	r := repos[0]
+	require.Equal("carlosms-test-org", r.Owner)
+	require.Equal("test-repo", r.Name)
+	require.Equal(filepath.Join(storage, "carlosms-test-org", "test-repo.git"), r.Path)
+	require.Equal(2, r.PullRequests)
+	require.Empty(r.MissingCommits)
+
+	_, err = os.Stat(filepath.Join(dir, "repositories", "carlosms-test-org", "test-repo.git"))
+	require.True(os.IsNotExist(err))
+
+	repo, err := git.PlainOpen(r.Path)
+	require.NoError(err)
+
+	for name, sha := range map[string]string{
+		"refs/pull/3/base": "6d15cf8422d7c7cd457dad101d3660b0a0e582a2",
+		"refs/pull/3/head": "34404ea4e7a62af2deb3fdfdf05614dfee6f839e",
+		"refs/pull/4/base": "6d15cf8422d7c7cd457dad101d3660b0a0e582a2",
+		"refs/pull/4/head": "97ae15511a9df035bd457cc62acb866086d55037",
+	} {
+		ref, err := repo.Reference(plumbing.ReferenceName(name), false)
+		require.NoError(err, name)
+		require.Equal(sha, ref.Hash().String(), name)
+	}
+
+	// a second extraction replaces the repository
+	dir2, done2 := copySample(t)
+	defer done2()
+
+	_, err = ExtractRepositories(dir2, storage)
+	require.NoError(err)
+}
+
+func TestExtractRepositoriesInvalid(t *testing.T) {
+	require := require.New(t)
+
+	dir, done := copySample(t)
+	defer done()
+
+	packs, err := filepath.Glob(filepath.Join(dir, "repositories", "*", "*.git", "objects", "pack", "*"))
+	require.NoError(err)
+	require.NotEmpty(packs)
+	for _, p := range packs {
+		require.NoError(os.Remove(p))
+	}
+
+	_, err = ExtractRepositories(dir, filepath.Join(filepath.Dir(dir), "repositories"))
+	require.Error(err)
+	require.Contains(err.Error(), "invalid repository carlosms-test-org/test-repo")
+}
@@ -0,0 +1,153 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is the schema.json file of an archive, with the version of its
+// format
+type Schema struct {
+	Version   string `json:"version"`
+	GitHubSHA string `json:"github_sha"`
+}
+
+// Major returns the major version of the format, e.g. 1 for 1.0.1
+func (s *Schema) Major() (int, error) {
+	major, err := strconv.Atoi(strings.SplitN(s.Version, ".", 2)[0])
+	if err != nil || major < 0 {
+		return 0, fmt.Errorf("invalid migration archive format version %q", s.Version)
+	}
+
+	return major, nil
+}
+
+// ReadSchema reads the schema.json file of the archive extracted in dir
+func ReadSchema(dir string) (*Schema, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "schema.json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s is not a migration archive, it has no schema.json", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s Schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema.json in %s: %v", dir, err)
+	}
+
+	return &s, nil
+}
+
+// decoder decodes the contents b of a file in v, a pointer to a slice of the
+// types of this package
+type decoder func(b []byte, v interface{}) error
+
+// format decodes the files of a major version of the archive format. Minor
+// versions only add fields, they are decoded by the same format.
+type format struct {
+	major int
+	// decoders are the decoders of the kinds whose files differ from the
+	// types of this package, the other kinds are decoded directly
+	decoders map[string]decoder
+}
+
+// formats are the supported archive formats, by major version. The types of
+// this package follow the 1.x format.
+var formats = map[int]*format{
+	1: {major: 1},
+}
+
+// formatFor returns the format that decodes the archives of the schema s
+func formatFor(s *Schema) (*format, error) {
+	major, err := s.Major()
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := formats[major]
+	if !ok {
+		var supported []string
+		for m := range formats {
+			supported = append(supported, fmt.Sprintf("%d.x", m))
+		}
+		sort.Strings(supported)
+
+		return nil, fmt.Errorf("unsupported migration archive format version %s, the supported versions are %s",
+			s.Version, strings.Join(supported, ", "))
+	}
+
+	return f, nil
+}
+
+// decode decodes the contents b of a file of kind in v, a pointer to a slice.
+// The slice is set to nil first: json reuses the elements of a slice with
+// capacity, keeping the fields that are not in the new file.
+func (f *format) decode(kind string, b []byte, v interface{}) error {
+	reflect.ValueOf(v).Elem().Set(reflect.Zero(reflect.TypeOf(v).Elem()))
+
+	if d, ok := f.decoders[kind]; ok {
+		return d(b, v)
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// archiveReader reads the files of an archive extracted in dir with the format of
+// its schema version
+type archiveReader struct {
+	dir    string
+	schema *Schema
+	format *format
+}
+
+// openArchive reads the schema of the archive extracted in dir, and fails if
+// its format version is not supported
+func openArchive(dir string) (*archiveReader, error) {
+	s, err := ReadSchema(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := formatFor(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archiveReader{dir: dir, schema: s, format: f}, nil
+}
+
+// readFiles decodes each <kind>_NNNNNN.json file of the archive in v, a
+// pointer to a slice, and then calls handle. A missing kind is not an error,
+// e.g. an archive without issues has no issues_000001.json.
+func (a *archiveReader) readFiles(kind string, v interface{}, handle func() error) error {
+	paths, err := filepath.Glob(filepath.Join(a.dir, kind+"_[0-9]*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := a.format.decode(kind, b, v); err != nil {
+			return fmt.Errorf("could not decode %s: %v", filepath.Base(path), err)
+		}
+
+		if err := handle(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
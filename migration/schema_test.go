@@ -0,0 +1,122 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSchema(t *testing.T) {
+	require := require.New(t)
+
+	s, err := ReadSchema(sampleArchive)
+	require.NoError(err)
+	require.Equal("1.0.1", s.Version)
+	require.Equal("b10c6f9c8ba686d330001babea39b3f689a76553", s.GitHubSHA)
+
+	major, err := s.Major()
+	require.NoError(err)
+	require.Equal(1, major)
+
+	dir, err := ioutil.TempDir("", "migration")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	_, err = ReadSchema(dir)
+	require.Error(err)
+	require.Contains(err.Error(), "has no schema.json")
+}
+
+// sampleWithVersion copies the sample archive, see copySample, and sets the
+// format version of its schema.json
+func sampleWithVersion(t *testing.T, version string) (string, func()) {
+	dir, cleanup := copySample(t)
+
+	s, err := ReadSchema(dir)
+	require.NoError(t, err)
+	s.Version = version
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "schema.json"), b, 0644))
+
+	return dir, cleanup
+}
+
+func TestLoadMinorVersion(t *testing.T) {
+	require := require.New(t)
+
+	dir, cleanup := sampleWithVersion(t, "1.2.0")
+	defer cleanup()
+
+	s := &memoryStorer{}
+	require.NoError(Load(context.Background(), dir, s))
+
+	require.Len(s.issues, 2)
+	require.Len(s.prs, 2)
+	require.Len(s.comments, 2)
+	require.Len(s.reviews, 3)
+	require.Len(s.reviewComments, 2)
+}
+
+func TestLoadUnsupportedVersion(t *testing.T) {
+	cases := []struct {
+		version string
+		err     string
+	}{
+		{"2.0.0", "unsupported migration archive format version 2.0.0, the supported versions are 1.x"},
+		{"latest", `invalid migration archive format version "latest"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.version, func(t *testing.T) {
+			dir, cleanup := sampleWithVersion(t, c.version)
+			defer cleanup()
+
+			err := Load(context.Background(), dir, &memoryStorer{})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+
+			_, err = StoreAttachments(dir, "")
+			require.Error(t, err)
+
+			_, err = ExtractRepositories(dir, "")
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestFormatDecoders(t *testing.T) {
+	require := require.New(t)
+
+	var decoded []string
+	f := &format{major: 1, decoders: map[string]decoder{
+		"issues": func(b []byte, v interface{}) error {
+			decoded = append(decoded, string(b))
+			return json.Unmarshal([]byte(`[{"title": "adapted"}]`), v)
+		},
+	}}
+
+	var issues []Issue
+	require.NoError(f.decode("issues", []byte(`[{"id": 1}]`), &issues))
+	require.Equal([]string{`[{"id": 1}]`}, decoded)
+	require.Len(issues, 1)
+	require.Equal("adapted", issues[0].Title)
+
+	// the kinds without a decoder are decoded directly
+	var comments []IssueComment
+	require.NoError(f.decode("issue_comments", []byte(`[{"body": "hi"}]`), &comments))
+	require.Len(decoded, 1)
+	require.Equal("hi", comments[0].Body)
+
+	// the fields of the previous file are not kept
+	require.NoError(f.decode("issue_comments", []byte(`[{"user": "alice"}]`), &comments))
+	require.Len(comments, 1)
+	require.Equal("alice", comments[0].User)
+	require.Equal("", comments[0].Body)
+}
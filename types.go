@@ -0,0 +1,191 @@
+package metadata
+
+import "time"
+
+// The canonical entities, shared by the v3, v4 and migration downloaders.
+// Each source has its own mappers to these types (Map* functions in the v3,
+// v4 and migration packages). The fields that a source does not provide are
+// left with their zero value, e.g. the migration archives do not include the
+// database IDs of issues and PRs.
+//
+// Users are referenced by login. The states use the GraphQL API values:
+// OPEN, CLOSED and MERGED for issues and PRs, and PENDING, COMMENTED,
+// APPROVED, CHANGES_REQUESTED and DISMISSED for reviews.
+
+// User is a GitHub user or organization account
+type User struct {
+	DatabaseID int64
+	Login      string
+	// Type is one of the actor types, e.g. BotType for apps
+	Type      string
+	Name      string
+	Company   string
+	Location  string
+	Email     string
+	CreatedAt time.Time
+}
+
+// The actor types of User.Type, the GraphQL __typename of the account, or
+// GhostType for the deleted ones
+const (
+	UserType         = "User"
+	BotType          = "Bot"
+	OrganizationType = "Organization"
+	MannequinType    = "Mannequin"
+	GhostType        = "Ghost"
+)
+
+// Ghost is the account that replaces the deleted users, the same way the REST
+// API and the migration archives show them. GraphQL returns a null actor
+// instead.
+var Ghost = User{DatabaseID: 10137, Login: "ghost", Type: GhostType}
+
+// Organization is a GitHub organization
+type Organization struct {
+	DatabaseID  int64
+	Login       string
+	Name        string
+	Description string
+	CreatedAt   time.Time
+}
+
+// Repository is a GitHub repository
+type Repository struct {
+	DatabaseID    int64
+	Owner         string
+	Name          string
+	Description   string
+	Homepage      string
+	DefaultBranch string
+	Private       bool
+	Fork          bool
+	Archived      bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Issue is an issue of a repository. The PRs are saved as PullRequest, not
+// as an Issue.
+type Issue struct {
+	DatabaseID      int64
+	RepositoryOwner string
+	RepositoryName  string
+	Number          int
+	Title           string
+	Body            string
+	State           string
+	Author          string
+	Assignees       []string
+	Labels          []string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	ClosedAt        *time.Time
+}
+
+// PullRequest is a pull request of a repository
+type PullRequest struct {
+	Issue
+
+	BaseRef  string
+	BaseSHA  string
+	HeadRef  string
+	HeadSHA  string
+	Merged   bool
+	MergedAt *time.Time
+}
+
+// Comment is a comment in the conversation of an issue or a PR. Number is the
+// number of the issue or PR.
+type Comment struct {
+	DatabaseID      int64
+	RepositoryOwner string
+	RepositoryName  string
+	Number          int
+	Author          string
+	Body            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// Review is a PR review, a group of review comments
+type Review struct {
+	DatabaseID        int64
+	RepositoryOwner   string
+	RepositoryName    string
+	PullRequestNumber int
+	Author            string
+	Body              string
+	State             string
+	CommitID          string
+	SubmittedAt       *time.Time
+}
+
+// ReviewComment is a comment on the diff of a PR, part of the review
+// ReviewID
+type ReviewComment struct {
+	DatabaseID        int64
+	RepositoryOwner   string
+	RepositoryName    string
+	PullRequestNumber int
+	ReviewID          int64
+	Author            string
+	Body              string
+	Path              string
+	Position          int
+	OriginalPosition  int
+	CommitID          string
+	DiffHunk          string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// ReviewThread is a conversation on the diff of a PR, a review comment and
+// its replies. Threads do not have a database ID, ID is the node ID.
+// CommentIDs are the database IDs of its review comments, in order. Line is 0
+// for outdated threads, OriginalLine is the line when the thread was started.
+type ReviewThread struct {
+	ID                string
+	RepositoryOwner   string
+	RepositoryName    string
+	PullRequestNumber int
+	Path              string
+	Line              int
+	OriginalLine      int
+	DiffSide          string
+	IsResolved        bool
+	IsOutdated        bool
+	ResolvedBy        string
+	CommentIDs        []int64
+}
+
+// Event is an event of the timeline of an issue or a PR, e.g. closed or
+// labeled. Number is the number of the issue or PR.
+type Event struct {
+	DatabaseID      int64
+	RepositoryOwner string
+	RepositoryName  string
+	Number          int
+	Event           string
+	Actor           string
+	// Subject is the user of assigned, review_requested and similar events
+	Subject string
+	// Label is the label name of labeled and unlabeled events
+	Label     string
+	CreatedAt time.Time
+}
+
+// Reaction is the number of reactions with one content, e.g. THUMBS_UP, on an
+// issue, PR, comment, review or review comment. Number is the number of the
+// issue or PR, SubjectType is the table of the entity reacted to, e.g.
+// "issue_comments", and SubjectID its database ID. Users has the logins that
+// reacted, only if they were downloaded.
+type Reaction struct {
+	RepositoryOwner string
+	RepositoryName  string
+	Number          int
+	SubjectType     string
+	SubjectID       int64
+	Content         string
+	Count           int
+	Users           []string
+}
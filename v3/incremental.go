@@ -0,0 +1,268 @@
+package v3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/store"
+	"github.com/google/go-github/github"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// UseIncrementalSync makes DownloadRepository download only the changes since
+// the last incremental download of the repository, merging them into the
+// entities of its version. The ETags and last sync time of each endpoint are
+// kept with the storer SaveSyncState. If there is no previous state
+// everything is downloaded.
+//
+// The issues and comments are listed with the since parameter, and the
+// comments are listed for the whole repository as in UseRepositoryComments.
+// Deleted entities are not detected. It cannot be used with UseShallowMode.
+func (d *GitHubDownloader) UseIncrementalSync() {
+	d.incremental = true
+	d.repoComments = true
+}
+
+// syncRun has the state of the previous incremental download, and the state
+// of the current one as it progresses. start is the server time of the first
+// response, used as the since of the next download.
+type syncRun struct {
+	prev  *store.SyncState
+	next  *store.SyncState
+	start time.Time
+}
+
+func newSyncRun(prev *store.SyncState, version string) *syncRun {
+	return &syncRun{
+		prev: prev,
+		next: &store.SyncState{
+			Version:   version,
+			Endpoints: make(map[string]store.EndpointState),
+		},
+	}
+}
+
+// endpoint returns the previous state of the endpoint, the zero value if it
+// was never synced
+func (s *syncRun) endpoint(endpoint string) store.EndpointState {
+	if s.prev == nil {
+		return store.EndpointState{}
+	}
+
+	return s.prev.Endpoints[endpoint]
+}
+
+func (d GitHubDownloader) downloadIncremental(ctx context.Context, logger log.Logger, owner string, name string, version string) error {
+	if d.shallow {
+		return fmt.Errorf("the shallow mode cannot be used in incremental downloads")
+	}
+
+	prev, err := d.LoadSyncState(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+
+	s := newSyncRun(prev, version)
+
+	if prev == nil {
+		logger.Infof("no previous incremental download, downloading everything")
+	} else {
+		logger.With(log.Fields{"previous-version": prev.Version}).Infof("merging the changes into the previous version")
+
+		err = d.MergeVersion(ctx, owner, name, prev.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	endpoint := fmt.Sprintf("repos/%s/%s", owner, name)
+	etag := s.endpoint(endpoint).ETag
+
+	repository := new(github.Repository)
+	r, notModified, err := d.get(ctx, endpoint, etag, repository)
+	if err != nil {
+		return err
+	}
+	s.start = serverTime(r)
+
+	if !notModified {
+		etag = r.Header.Get("ETag")
+
+		err = d.SaveRepository(ctx, repository)
+		if err != nil {
+			return err
+		}
+	}
+	s.next.Endpoints[endpoint] = store.EndpointState{ETag: etag, LastSync: s.start}
+
+	var issues []*github.Issue
+	changedIssues, err := d.listChanged(ctx, s, endpoint+"/issues",
+		url.Values{"state": {"all"}, "sort": {"updated"}, "direction": {"asc"}},
+		&issues, func() (int, error) {
+			for _, i := range issues {
+				err := d.downloadIssueItem(ctx, owner, name, i, version)
+				if err != nil {
+					return 0, err
+				}
+			}
+
+			return len(issues), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	var comments []*github.IssueComment
+	changedComments, err := d.listChanged(ctx, s, endpoint+"/issues/comments",
+		url.Values{"sort": {"updated"}, "direction": {"asc"}},
+		&comments, func() (int, error) {
+			for _, c := range comments {
+				err := d.SaveIssueComment(ctx, c)
+				if err != nil {
+					return 0, err
+				}
+			}
+
+			return len(comments), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	var reviewComments []*github.PullRequestComment
+	changedReviewComments, err := d.listChanged(ctx, s, endpoint+"/pulls/comments",
+		url.Values{"sort": {"updated"}, "direction": {"asc"}},
+		&reviewComments, func() (int, error) {
+			for _, c := range reviewComments {
+				err := d.SavePullRequestComment(ctx, c)
+				if err != nil {
+					return 0, err
+				}
+			}
+
+			return len(reviewComments), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	logger.With(log.Fields{
+		"repository-changed":      !notModified,
+		"issues-changed":          changedIssues,
+		"comments-changed":        changedComments,
+		"review-comments-changed": changedReviewComments,
+	}).Infof("incremental download finished")
+
+	return d.SaveSyncState(ctx, owner, name, s.next)
+}
+
+// listChanged pages through the listing endpoint, requesting the items
+// updated since its last sync. The first page is requested with the ETag of
+// the last sync, if it is not modified nothing changed and there are no more
+// pages. Each page is decoded in v, a pointer to a slice, and then handle is
+// called, returning the number of items in the page. It returns the number of
+// items listed.
+func (d GitHubDownloader) listChanged(ctx context.Context, s *syncRun, endpoint string, params url.Values, v interface{}, handle func() (int, error)) (int, error) {
+	prev := s.endpoint(endpoint)
+
+	params.Set("per_page", fmt.Sprint(listOptionsPerPage))
+	if !prev.LastSync.IsZero() {
+		params.Set("since", prev.LastSync.UTC().Format(time.RFC3339))
+	}
+
+	var etag string
+	items := 0
+	for page := 1; ; page++ {
+		params.Set("page", fmt.Sprint(page))
+
+		var ifNoneMatch string
+		if page == 1 {
+			ifNoneMatch = prev.ETag
+		}
+
+		// json reuses the elements of a slice with capacity, keeping the
+		// fields that the new page does not have, so each page is decoded in
+		// a nil slice
+		reflect.ValueOf(v).Elem().Set(reflect.Zero(reflect.TypeOf(v).Elem()))
+
+		r, notModified, err := d.get(ctx, endpoint+"?"+params.Encode(), ifNoneMatch, v)
+		if err != nil {
+			return 0, err
+		}
+
+		if notModified {
+			break
+		}
+
+		if page == 1 {
+			etag = r.Header.Get("ETag")
+		}
+
+		n, err := handle()
+		if err != nil {
+			return 0, err
+		}
+		items += n
+
+		if r.NextPage == 0 {
+			break
+		}
+	}
+
+	if items > 0 {
+		// the next download uses a new since, so the ETag is of no use
+		s.next.Endpoints[endpoint] = store.EndpointState{LastSync: s.start}
+		return items, nil
+	}
+
+	// nothing changed, the next download repeats the same request to get a
+	// 304 if there are still no changes
+	if etag == "" {
+		etag = prev.ETag
+	}
+	s.next.Endpoints[endpoint] = store.EndpointState{ETag: etag, LastSync: prev.LastSync}
+
+	return 0, nil
+}
+
+// serverTime returns the time of the Date header of the response, so the since
+// of the next download does not depend on the local clock. The Date header is
+// truncated to the second, so no change of the same second is missed. If
+// there is no Date header the local time is used.
+func serverTime(r *github.Response) time.Time {
+	t, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return time.Now()
+	}
+
+	return t
+}
+
+// get requests the API path u, decoding the response in v. etag is sent in
+// If-None-Match if it is not empty. notModified is true if the response is a
+// 304, or if it has the same ETag because it comes from the HTTP cache of the
+// client, which replaces 304 responses with the cached ones.
+func (d GitHubDownloader) get(ctx context.Context, u string, etag string, v interface{}) (r *github.Response, notModified bool, err error) {
+	req, err := d.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	r, err = d.client.Do(ctx, req, v)
+	if r != nil && r.StatusCode == http.StatusNotModified {
+		return r, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return r, etag != "" && r.Header.Get("ETag") == etag, nil
+}
@@ -0,0 +1,337 @@
+package v3
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/carlosms/metadata-retrieval-playground/internal/client"
+	"github.com/carlosms/metadata-retrieval-playground/internal/store"
+	"github.com/google/go-github/github"
+)
+
+// NewMetadataDownloader returns a downloader that maps the go-github types
+// to the canonical metadata types and saves them to s. The incremental
+// downloads are only supported if s is also a store.SyncStorer, like
+// store.MetadataDBStorer.
+func NewMetadataDownloader(httpClient *http.Client, s metadata.Storer) (*GitHubDownloader, error) {
+	c, err := client.NewClient(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitHubDownloader{
+		Storer: store.WithInstrumentation(&metadataStorer{s: s}, "metadata"),
+		client: github.NewClient(c),
+	}, nil
+}
+
+// metadataStorer is a store.Storer that saves to a metadata.Storer
+type metadataStorer struct {
+	s metadata.Storer
+}
+
+var _ store.Storer = &metadataStorer{}
+
+func (m *metadataStorer) SaveOrganization(ctx context.Context, org *github.Organization) error {
+	return m.s.SaveOrganization(ctx, MapOrganization(org))
+}
+
+func (m *metadataStorer) SaveRepository(ctx context.Context, repository *github.Repository) error {
+	return m.s.SaveRepository(ctx, MapRepository(repository))
+}
+
+func (m *metadataStorer) SaveIssue(ctx context.Context, issue *github.Issue) error {
+	i, err := MapIssue(issue)
+	if err != nil {
+		return err
+	}
+
+	return m.s.SaveIssue(ctx, i)
+}
+
+func (m *metadataStorer) SaveIssueComment(ctx context.Context, comment *github.IssueComment) error {
+	c, err := MapIssueComment(comment)
+	if err != nil {
+		return err
+	}
+
+	return m.s.SaveComment(ctx, c)
+}
+
+func (m *metadataStorer) SavePullRequest(ctx context.Context, pr *github.PullRequest) error {
+	p, err := MapPullRequest(pr)
+	if err != nil {
+		return err
+	}
+
+	return m.s.SavePullRequest(ctx, p)
+}
+
+func (m *metadataStorer) SavePullRequestComment(ctx context.Context, comment *github.PullRequestComment) error {
+	c, err := MapReviewComment(comment)
+	if err != nil {
+		return err
+	}
+
+	return m.s.SaveReviewComment(ctx, c)
+}
+
+func (m *metadataStorer) SavePullRequestReview(ctx context.Context, review *github.PullRequestReview) error {
+	r, err := MapReview(review)
+	if err != nil {
+		return err
+	}
+
+	return m.s.SaveReview(ctx, r)
+}
+
+func (m *metadataStorer) Begin() error {
+	return m.s.Begin()
+}
+
+func (m *metadataStorer) Commit() error {
+	return m.s.Commit()
+}
+
+func (m *metadataStorer) Rollback() error {
+	return m.s.Rollback()
+}
+
+func (m *metadataStorer) Version(v string) {
+	m.s.Version(v)
+}
+
+func (m *metadataStorer) SetActiveVersion(v string) error {
+	return m.s.SetActiveVersion(v)
+}
+
+func (m *metadataStorer) Cleanup(currentVersion string) error {
+	return m.s.Cleanup(currentVersion)
+}
+
+func (m *metadataStorer) LoadSyncState(ctx context.Context, owner, name string) (*store.SyncState, error) {
+	if s, ok := m.s.(store.SyncStorer); ok {
+		return s.LoadSyncState(ctx, owner, name)
+	}
+
+	return nil, nil
+}
+
+func (m *metadataStorer) SaveSyncState(ctx context.Context, owner, name string, state *store.SyncState) error {
+	if s, ok := m.s.(store.SyncStorer); ok {
+		return s.SaveSyncState(ctx, owner, name, state)
+	}
+
+	return nil
+}
+
+func (m *metadataStorer) MergeVersion(ctx context.Context, owner, name, from string) error {
+	if s, ok := m.s.(store.SyncStorer); ok {
+		return s.MergeVersion(ctx, owner, name, from)
+	}
+
+	return nil
+}
+
+// userType returns the actor type of a go-github user. REST returns the
+// deleted accounts as the ghost user.
+func userType(u *github.User) string {
+	if u.GetLogin() == metadata.Ghost.Login {
+		return metadata.GhostType
+	}
+
+	return u.GetType()
+}
+
+// MapUser maps a go-github user to the canonical type
+func MapUser(u *github.User) *metadata.User {
+	return &metadata.User{
+		DatabaseID: u.GetID(),
+		Login:      u.GetLogin(),
+		Type:       userType(u),
+		Name:       u.GetName(),
+		Company:    u.GetCompany(),
+		Location:   u.GetLocation(),
+		Email:      u.GetEmail(),
+		CreatedAt:  u.GetCreatedAt().Time,
+	}
+}
+
+// MapOrganization maps a go-github organization to the canonical type
+func MapOrganization(org *github.Organization) *metadata.Organization {
+	return &metadata.Organization{
+		DatabaseID:  org.GetID(),
+		Login:       org.GetLogin(),
+		Name:        org.GetName(),
+		Description: org.GetDescription(),
+		CreatedAt:   org.GetCreatedAt(),
+	}
+}
+
+// MapRepository maps a go-github repository to the canonical type
+func MapRepository(r *github.Repository) *metadata.Repository {
+	return &metadata.Repository{
+		DatabaseID:    r.GetID(),
+		Owner:         r.GetOwner().GetLogin(),
+		Name:          r.GetName(),
+		Description:   r.GetDescription(),
+		Homepage:      r.GetHomepage(),
+		DefaultBranch: r.GetDefaultBranch(),
+		Private:       r.GetPrivate(),
+		Fork:          r.GetFork(),
+		Archived:      r.GetArchived(),
+		CreatedAt:     r.GetCreatedAt().Time,
+		UpdatedAt:     r.GetUpdatedAt().Time,
+	}
+}
+
+// MapIssue maps a go-github issue to the canonical type. The repository is
+// taken from the issue API URL.
+func MapIssue(i *github.Issue) (*metadata.Issue, error) {
+	owner, name, _, err := store.ParseAPIURL(i.GetURL())
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, len(i.Labels))
+	for j, l := range i.Labels {
+		labels[j] = l.GetName()
+	}
+
+	return &metadata.Issue{
+		DatabaseID:      i.GetID(),
+		RepositoryOwner: owner,
+		RepositoryName:  name,
+		Number:          i.GetNumber(),
+		Title:           i.GetTitle(),
+		Body:            i.GetBody(),
+		State:           strings.ToUpper(i.GetState()),
+		Author:          i.GetUser().GetLogin(),
+		Assignees:       logins(i.Assignees),
+		Labels:          labels,
+		CreatedAt:       i.GetCreatedAt(),
+		UpdatedAt:       i.GetUpdatedAt(),
+		ClosedAt:        i.ClosedAt,
+	}, nil
+}
+
+// MapPullRequest maps a go-github PR to the canonical type. The repository is
+// taken from the PR API URL.
+func MapPullRequest(pr *github.PullRequest) (*metadata.PullRequest, error) {
+	owner, name, _, err := store.ParseAPIURL(pr.GetURL())
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, len(pr.Labels))
+	for j, l := range pr.Labels {
+		labels[j] = l.GetName()
+	}
+
+	state := strings.ToUpper(pr.GetState())
+	if pr.GetMerged() || pr.MergedAt != nil {
+		state = "MERGED"
+	}
+
+	return &metadata.PullRequest{
+		Issue: metadata.Issue{
+			DatabaseID:      pr.GetID(),
+			RepositoryOwner: owner,
+			RepositoryName:  name,
+			Number:          pr.GetNumber(),
+			Title:           pr.GetTitle(),
+			Body:            pr.GetBody(),
+			State:           state,
+			Author:          pr.GetUser().GetLogin(),
+			Assignees:       logins(pr.Assignees),
+			Labels:          labels,
+			CreatedAt:       pr.GetCreatedAt(),
+			UpdatedAt:       pr.GetUpdatedAt(),
+			ClosedAt:        pr.ClosedAt,
+		},
+		BaseRef:  pr.GetBase().GetRef(),
+		BaseSHA:  pr.GetBase().GetSHA(),
+		HeadRef:  pr.GetHead().GetRef(),
+		HeadSHA:  pr.GetHead().GetSHA(),
+		Merged:   state == "MERGED",
+		MergedAt: pr.MergedAt,
+	}, nil
+}
+
+// MapIssueComment maps a go-github issue comment, of an issue or a PR, to the
+// canonical type. The repository is taken from the issue API URL.
+func MapIssueComment(c *github.IssueComment) (*metadata.Comment, error) {
+	owner, name, number, err := store.ParseAPIURL(c.GetIssueURL())
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata.Comment{
+		DatabaseID:      c.GetID(),
+		RepositoryOwner: owner,
+		RepositoryName:  name,
+		Number:          number,
+		Author:          c.GetUser().GetLogin(),
+		Body:            c.GetBody(),
+		CreatedAt:       c.GetCreatedAt(),
+		UpdatedAt:       c.GetUpdatedAt(),
+	}, nil
+}
+
+// MapReview maps a go-github PR review to the canonical type. The repository
+// is taken from the PR API URL.
+func MapReview(r *github.PullRequestReview) (*metadata.Review, error) {
+	owner, name, number, err := store.ParseAPIURL(r.GetPullRequestURL())
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata.Review{
+		DatabaseID:        r.GetID(),
+		RepositoryOwner:   owner,
+		RepositoryName:    name,
+		PullRequestNumber: number,
+		Author:            r.GetUser().GetLogin(),
+		Body:              r.GetBody(),
+		State:             r.GetState(),
+		CommitID:          r.GetCommitID(),
+		SubmittedAt:       r.SubmittedAt,
+	}, nil
+}
+
+// MapReviewComment maps a go-github PR review comment to the canonical type.
+// The repository is taken from the PR API URL.
+func MapReviewComment(c *github.PullRequestComment) (*metadata.ReviewComment, error) {
+	owner, name, number, err := store.ParseAPIURL(c.GetPullRequestURL())
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata.ReviewComment{
+		DatabaseID:        c.GetID(),
+		RepositoryOwner:   owner,
+		RepositoryName:    name,
+		PullRequestNumber: number,
+		ReviewID:          c.GetPullRequestReviewID(),
+		Author:            c.GetUser().GetLogin(),
+		Body:              c.GetBody(),
+		Path:              c.GetPath(),
+		Position:          c.GetPosition(),
+		OriginalPosition:  c.GetOriginalPosition(),
+		CommitID:          c.GetCommitID(),
+		DiffHunk:          c.GetDiffHunk(),
+		CreatedAt:         c.GetCreatedAt(),
+		UpdatedAt:         c.GetUpdatedAt(),
+	}, nil
+}
+
+func logins(users []*github.User) []string {
+	l := make([]string, len(users))
+	for i, u := range users {
+		l[i] = u.GetLogin()
+	}
+
+	return l
+}
@@ -0,0 +1,134 @@
+package v3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
+	"github.com/google/go-github/github"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// OrgFilter selects the repositories downloaded by DownloadOrg
+type OrgFilter struct {
+	// SkipArchived skips the archived repositories
+	SkipArchived bool
+	// SkipForks skips the forks
+	SkipForks bool
+	// Visibility is all, public or private. Empty means all.
+	Visibility string
+}
+
+// SetOrgFilter sets the filter for the repositories downloaded by DownloadOrg
+func (d *GitHubDownloader) SetOrgFilter(f OrgFilter) {
+	d.orgFilter = f
+}
+
+func (f OrgFilter) skip(r *github.Repository) bool {
+	return (f.SkipArchived && r.GetArchived()) || (f.SkipForks && r.GetFork())
+}
+
+// DownloadOrg saves the organization metadata and downloads all its
+// repositories that pass the filter set with SetOrgFilter. A failure in one
+// repository does not stop the rest, the results are logged at the end and an
+// error is returned if any repository failed.
+func (d GitHubDownloader) DownloadOrg(name string, version string) (err error) {
+	logger := log.New(log.Fields{"org": name})
+
+	ctx, span := tracing.Start(context.Background(), "DownloadOrg",
+		attribute.String("org", name),
+		attribute.String("version", version))
+	defer func() { tracing.End(span, err) }()
+
+	t0 := time.Now()
+
+	repos, err := d.saveOrg(ctx, name, version)
+	if err != nil {
+		return err
+	}
+
+	logger.With(log.Fields{"repositories": len(repos)}).Infof("organization metadata fetched")
+
+	var failed []string
+	for _, r := range repos {
+		// each repository is saved in its own transaction, in a child span
+		err := d.downloadRepository(ctx, name, r.GetName(), version)
+		if err != nil {
+			log.With(log.Fields{"owner": name, "repo": r.GetName()}).Errorf(err, "repository download failed")
+			failed = append(failed, r.GetName())
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("repositories", len(repos)),
+		attribute.Int("repositories.failed", len(failed)))
+
+	logger.With(log.Fields{
+		"repositories":  len(repos),
+		"succeeded":     len(repos) - len(failed),
+		"failed":        failed,
+		"total-elapsed": time.Since(t0),
+	}).Infof("All organization metadata fetched")
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d repositories of %s failed: %v", len(failed), len(repos), name, failed)
+	}
+
+	return nil
+}
+
+// saveOrg saves the organization metadata, and returns its repositories that
+// pass the filter
+func (d GitHubDownloader) saveOrg(ctx context.Context, name string, version string) (repos []*github.Repository, err error) {
+	d.Version(version)
+
+	err = d.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			d.Rollback()
+			return
+		}
+
+		err = d.Commit()
+	}()
+
+	org, _, err := d.client.Organizations.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.SaveOrganization(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &github.RepositoryListByOrgOptions{Type: d.orgFilter.Visibility}
+	opts.ListOptions.PerPage = listOptionsPerPage
+
+	for {
+		page, r, err := d.client.Repositories.ListByOrg(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range page {
+			if !d.orgFilter.skip(repo) {
+				repos = append(repos, repo)
+			}
+		}
+
+		if r.NextPage == 0 {
+			break
+		}
+
+		opts.Page = r.NextPage
+	}
+
+	return repos, nil
+}
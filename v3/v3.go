@@ -2,21 +2,27 @@ package v3
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
 	"net/http"
 	"time"
 
 	"github.com/carlosms/metadata-retrieval-playground"
 	"github.com/carlosms/metadata-retrieval-playground/internal/client"
 	"github.com/carlosms/metadata-retrieval-playground/internal/store"
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
 	"github.com/google/go-github/github"
+	"go.opentelemetry.io/otel/attribute"
 	"gopkg.in/src-d/go-log.v1"
 )
 
 type GitHubDownloader struct {
 	store.Storer
 
-	client *github.Client
+	client       *github.Client
+	repoComments bool
+	shallow      bool
+	incremental  bool
+	orgFilter    OrgFilter
 }
 
 var _ metadata.MetadataDownloader = GitHubDownloader{}
@@ -28,27 +34,128 @@ func NewStdoutDownloader(httpClient *http.Client) (*GitHubDownloader, error) {
 	}
 
 	return &GitHubDownloader{
-		Storer: store.StdoutStorer{},
+		Storer: store.WithInstrumentation(store.StdoutStorer{}, "stdout"),
 		client: github.NewClient(c),
 	}, nil
 }
 
+func NewDBDownloader(httpClient *http.Client, db *sql.DB) (*GitHubDownloader, error) {
+	c, err := client.NewClient(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitHubDownloader{
+		Storer: store.WithInstrumentation(&metadataStorer{s: store.NewMetadataDBStorer(db)}, "db"),
+		client: github.NewClient(c),
+	}, nil
+}
+
+// UseRepositoryComments makes DownloadRepository list the issue comments and
+// the PR review comments once for the whole repository, instead of once per
+// issue and PR. The comments are joined back to the downloaded issues and PRs
+// by their URL.
+func (d *GitHubDownloader) UseRepositoryComments() {
+	d.repoComments = true
+}
+
+// ShallowMissingFields are the fields of each entity type that are not set
+// with UseShallowMode, because the list endpoints do not return them. See the
+// comparison of list and individual endpoints in the README.
+var ShallowMissingFields = map[string][]string{
+	"issue": {"closed_by"},
+	"pull_request": {
+		"merged",
+		"mergeable",
+		"rebaseable",
+		"mergeable_state",
+		"merged_by",
+		"comments",
+		"review_comments",
+		"maintainer_can_modify",
+		"commits",
+		"additions",
+		"deletions",
+		"changed_files",
+	},
+}
+
+// UseShallowMode makes DownloadRepository save the issues and PRs as returned
+// by the list endpoints, instead of requesting each one individually. The
+// fields in ShallowMissingFields are not set in this mode.
+func (d *GitHubDownloader) UseShallowMode() {
+	d.shallow = true
+}
+
 func (d GitHubDownloader) DownloadRepository(owner string, name string, version string) error {
+	return d.downloadRepository(context.Background(), owner, name, version)
+}
+
+// downloadRepository is DownloadRepository, with its span started from ctx
+func (d GitHubDownloader) downloadRepository(ctx context.Context, owner string, name string, version string) (err error) {
 	logger := log.New(log.Fields{"owner": owner, "repo": name})
 
-	rate0, err := d.rateRemaining()
+	ctx, span := tracing.Start(ctx, "DownloadRepository",
+		attribute.String("owner", owner),
+		attribute.String("repo", name),
+		attribute.String("version", version))
+	defer func() { tracing.End(span, err) }()
+
+	d.Version(version)
+
+	err = d.Begin()
 	if err != nil {
 		return err
 	}
 
+	defer func() {
+		if err != nil {
+			d.Rollback()
+			return
+		}
+
+		err = d.Commit()
+	}()
+
+	rate0, err := d.rateRemaining(ctx)
+	if err != nil {
+		return err
+	}
+
+	t0 := time.Now()
+
+	if d.incremental {
+		err = d.downloadIncremental(ctx, logger, owner, name, version)
+	} else {
+		err = d.download(ctx, logger, owner, name, version)
+	}
+	if err != nil {
+		return err
+	}
+
+	elapsed := time.Since(t0)
+	rate1, err := d.rateRemaining(ctx)
+	if err != nil {
+		return err
+	}
+	rateUsed := rate0 - rate1
+	span.SetAttributes(attribute.Int("github.rate_limit.used", rateUsed))
+
+	logger.With(log.Fields{"rate-limit-used": rateUsed, "total-elapsed": elapsed}).Infof("All metadata fetched")
+
+	return nil
+}
+
+// download downloads all the repository metadata
+func (d GitHubDownloader) download(ctx context.Context, logger log.Logger, owner string, name string, version string) error {
 	t0 := time.Now()
 
-	repository, _, err := d.client.Repositories.Get(context.TODO(), owner, name)
+	repository, _, err := d.client.Repositories.Get(ctx, owner, name)
 	if err != nil {
 		return err
 	}
 
-	err = d.SaveRepository(repository)
+	err = d.SaveRepository(ctx, repository)
 	if err != nil {
 		return err
 	}
@@ -59,28 +166,35 @@ func (d GitHubDownloader) DownloadRepository(owner string, name string, version
 	t1 := time.Now()
 
 	// issues, PRs and comments
-	err = d.downloadIssues(logger, owner, name, version)
+	urls, err := d.downloadIssues(ctx, logger, owner, name, version)
 	if err != nil {
 		return err
 	}
 
-	elapsed = time.Since(t1)
-	logger.With(log.Fields{"elapsed": elapsed}).Infof("issues & issue comments fetched")
+	if d.shallow {
+		logger.With(log.Fields{"missing-fields": ShallowMissingFields}).Infof("shallow mode, some fields are not downloaded")
 
-	elapsed = time.Since(t0)
-	rate1, err := d.rateRemaining()
-	if err != nil {
-		return err
+		err = d.downloadPullRequests(ctx, owner, name)
+		if err != nil {
+			return err
+		}
 	}
-	rateUsed := rate0 - rate1
 
-	logger.With(log.Fields{"rate-limit-used": rateUsed, "total-elapsed": elapsed}).Infof("All metadata fetched")
+	if d.repoComments {
+		err = d.downloadRepositoryComments(ctx, logger, owner, name, urls)
+		if err != nil {
+			return err
+		}
+	}
+
+	elapsed = time.Since(t1)
+	logger.With(log.Fields{"elapsed": elapsed}).Infof("issues & issue comments fetched")
 
 	return nil
 }
 
-func (d GitHubDownloader) rateRemaining() (int, error) {
-	limit, _, err := d.client.RateLimits(context.TODO())
+func (d GitHubDownloader) rateRemaining(ctx context.Context) (int, error) {
+	limit, _, err := d.client.RateLimits(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -90,50 +204,31 @@ func (d GitHubDownloader) rateRemaining() (int, error) {
 
 const listOptionsPerPage = 100
 
-func (d GitHubDownloader) downloadIssues(logger log.Logger, owner string, repo string, version string) error {
+// downloadIssues downloads the issues and PRs, with their reviews. The
+// comments are downloaded for each one, unless repoComments is set. In
+// shallow mode the issues are saved from the list, and the PRs are left to
+// downloadPullRequests. It returns the API URLs of the issues and PRs.
+func (d GitHubDownloader) downloadIssues(ctx context.Context, logger log.Logger, owner string, repo string, version string) (map[string]bool, error) {
 	opts := &github.IssueListByRepoOptions{}
 	opts.ListOptions.PerPage = listOptionsPerPage
 	opts.State = "all"
 
+	urls := make(map[string]bool)
 	for {
-		issues, r, err := d.client.Issues.ListByRepo(context.TODO(), owner, repo, opts)
+		issues, r, err := d.client.Issues.ListByRepo(ctx, owner, repo, opts)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		// TODO: there is an endpoint to get all repo issue comments,
-		// instead of calling for each issue/PR
-
 		for _, i := range issues {
+			urls[i.GetURL()] = true
 			if i.IsPullRequest() {
-				err := d.downloadPullRequest(owner, repo, i.GetNumber(), version)
-				if err != nil {
-					return err
-				}
-				// PRs have: normal issue comments, individual review comments, and Reviews (groups of Review comments)
-				err = d.downloadIssueComments(owner, repo, i.GetNumber(), version)
-				if err != nil {
-					return err
-				}
-
-				err = d.downloadPullRequestReviews(owner, repo, i.GetNumber(), version)
-				if err != nil {
-					return err
-				}
-
-				err = d.downloadPullRequestComments(owner, repo, i.GetNumber(), version)
-				if err != nil {
-					return err
-				}
-			} else {
-				err := d.downloadIssue(owner, repo, i.GetNumber(), version)
-				if err != nil {
-					return err
-				}
-				err = d.downloadIssueComments(owner, repo, i.GetNumber(), version)
-				if err != nil {
-					return err
-				}
+				urls[i.GetPullRequestLinks().GetURL()] = true
+			}
+
+			err = d.downloadIssueItem(ctx, owner, repo, i, version)
+			if err != nil {
+				return nil, err
 			}
 		}
 
@@ -144,36 +239,155 @@ func (d GitHubDownloader) downloadIssues(logger log.Logger, owner string, repo s
 		opts.Page = r.NextPage
 	}
 
+	return urls, nil
+}
+
+// downloadIssueItem downloads an issue or PR returned by the issues list
+// endpoint, with its reviews and comments
+func (d GitHubDownloader) downloadIssueItem(ctx context.Context, owner string, repo string, i *github.Issue, version string) error {
+	if i.IsPullRequest() {
+		// in shallow mode the PRs are saved by downloadPullRequests
+		if !d.shallow {
+			err := d.downloadPullRequest(ctx, owner, repo, i.GetNumber(), version)
+			if err != nil {
+				return err
+			}
+		}
+
+		// PRs have: normal issue comments, individual review comments, and Reviews (groups of Review comments)
+		if !d.repoComments {
+			err := d.downloadIssueComments(ctx, owner, repo, i.GetNumber(), version)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := d.downloadPullRequestReviews(ctx, owner, repo, i.GetNumber(), version)
+		if err != nil {
+			return err
+		}
+
+		if !d.repoComments {
+			return d.downloadPullRequestComments(ctx, owner, repo, i.GetNumber(), version)
+		}
+
+		return nil
+	}
+
+	var err error
+	if d.shallow {
+		err = d.SaveIssue(ctx, i)
+	} else {
+		err = d.downloadIssue(ctx, owner, repo, i.GetNumber(), version)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !d.repoComments {
+		return d.downloadIssueComments(ctx, owner, repo, i.GetNumber(), version)
+	}
+
+	return nil
+}
+
+// downloadRepositoryComments downloads all the issue comments and PR review
+// comments of the repository, using the number 0 in ListComments. Only the
+// comments of the issues and PRs in urls are saved, the rest belong to
+// issues created after they were listed.
+func (d GitHubDownloader) downloadRepositoryComments(ctx context.Context, logger log.Logger, owner string, repo string, urls map[string]bool) error {
+	skipped := 0
+
+	issueOpts := &github.IssueListCommentsOptions{Sort: "updated", Direction: "asc"}
+	issueOpts.ListOptions.PerPage = listOptionsPerPage
+
+	for {
+		comments, r, err := d.client.Issues.ListComments(ctx, owner, repo, 0, issueOpts)
+		if err != nil {
+			return err
+		}
+
+		for _, comment := range comments {
+			if !urls[comment.GetIssueURL()] {
+				skipped++
+				continue
+			}
+
+			err = d.SaveIssueComment(ctx, comment)
+			if err != nil {
+				return err
+			}
+		}
+
+		if r.NextPage == 0 {
+			break
+		}
+
+		issueOpts.Page = r.NextPage
+	}
+
+	prOpts := &github.PullRequestListCommentsOptions{Sort: "updated", Direction: "asc"}
+	prOpts.ListOptions.PerPage = listOptionsPerPage
+
+	for {
+		comments, r, err := d.client.PullRequests.ListComments(ctx, owner, repo, 0, prOpts)
+		if err != nil {
+			return err
+		}
+
+		for _, comment := range comments {
+			if !urls[comment.GetPullRequestURL()] {
+				skipped++
+				continue
+			}
+
+			err = d.SavePullRequestComment(ctx, comment)
+			if err != nil {
+				return err
+			}
+		}
+
+		if r.NextPage == 0 {
+			break
+		}
+
+		prOpts.Page = r.NextPage
+	}
+
+	if skipped > 0 {
+		logger.Warningf("%d comments of issues or PRs not downloaded were skipped", skipped)
+	}
+
 	return nil
 }
 
-func (d GitHubDownloader) downloadIssue(owner string, repo string, number int, version string) error {
-	issue, _, err := d.client.Issues.Get(context.TODO(), owner, repo, number)
+func (d GitHubDownloader) downloadIssue(ctx context.Context, owner string, repo string, number int, version string) error {
+	issue, _, err := d.client.Issues.Get(ctx, owner, repo, number)
 	if err != nil {
 		return err
 	}
 
-	return d.SaveIssue(issue)
+	return d.SaveIssue(ctx, issue)
 }
 
-func (d GitHubDownloader) downloadIssueComments(owner string, repo string, number int, version string) error {
+func (d GitHubDownloader) downloadIssueComments(ctx context.Context, owner string, repo string, number int, version string) error {
 	opts := &github.IssueListCommentsOptions{}
 	opts.ListOptions.PerPage = listOptionsPerPage
 
 	for {
-		comments, r, err := d.client.Issues.ListComments(context.TODO(), owner, repo, number, opts)
+		comments, r, err := d.client.Issues.ListComments(ctx, owner, repo, number, opts)
 		if err != nil {
 			return err
 		}
 
 		for _, comment := range comments {
 			// No need to do
-			// d.client.Issues.GetComment(context.TODO(), owner, repo, comment.GetID())
+			// d.client.Issues.GetComment(ctx, owner, repo, comment.GetID())
 			// the contents are the same, see
 			// https://developer.github.com/v3/issues/comments/#get-a-single-comment
 			// https://developer.github.com/v3/issues/comments/#list-comments-on-an-issue
 
-			err = d.SaveIssueComment(comment)
+			err = d.SaveIssueComment(ctx, comment)
 			if err != nil {
 				return err
 			}
@@ -189,33 +403,62 @@ func (d GitHubDownloader) downloadIssueComments(owner string, repo string, numbe
 	return nil
 }
 
-func (d GitHubDownloader) downloadPullRequest(owner string, repo string, number int, version string) error {
-	issue, _, err := d.client.PullRequests.Get(context.TODO(), owner, repo, number)
+func (d GitHubDownloader) downloadPullRequest(ctx context.Context, owner string, repo string, number int, version string) error {
+	issue, _, err := d.client.PullRequests.Get(ctx, owner, repo, number)
 	if err != nil {
 		return err
 	}
 
-	return d.SavePullRequest(issue)
+	return d.SavePullRequest(ctx, issue)
 }
 
-func (d GitHubDownloader) downloadPullRequestReviews(owner string, repo string, number int, version string) error {
+// downloadPullRequests saves all the PRs from the list endpoint, used in
+// shallow mode
+func (d GitHubDownloader) downloadPullRequests(ctx context.Context, owner string, repo string) error {
+	opts := &github.PullRequestListOptions{State: "all"}
+	opts.ListOptions.PerPage = listOptionsPerPage
+
+	for {
+		prs, r, err := d.client.PullRequests.List(ctx, owner, repo, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, pr := range prs {
+			err = d.SavePullRequest(ctx, pr)
+			if err != nil {
+				return err
+			}
+		}
+
+		if r.NextPage == 0 {
+			break
+		}
+
+		opts.Page = r.NextPage
+	}
+
+	return nil
+}
+
+func (d GitHubDownloader) downloadPullRequestReviews(ctx context.Context, owner string, repo string, number int, version string) error {
 	opts := &github.ListOptions{}
 	opts.PerPage = listOptionsPerPage
 
 	for {
-		reviews, r, err := d.client.PullRequests.ListReviews(context.TODO(), owner, repo, number, opts)
+		reviews, r, err := d.client.PullRequests.ListReviews(ctx, owner, repo, number, opts)
 		if err != nil {
 			return err
 		}
 
 		for _, review := range reviews {
 			// No need to do
-			// d.client.Issues.GetComment(context.TODO(), owner, repo, comment.GetID())
+			// d.client.Issues.GetComment(ctx, owner, repo, comment.GetID())
 			// the contents are the same, see
 			// https://developer.github.com/v3/issues/comments/#get-a-single-comment
 			// https://developer.github.com/v3/issues/comments/#list-comments-on-an-issue
 
-			err = d.SavePullRequestReview(review)
+			err = d.SavePullRequestReview(ctx, review)
 			if err != nil {
 				return err
 			}
@@ -231,24 +474,24 @@ func (d GitHubDownloader) downloadPullRequestReviews(owner string, repo string,
 	return nil
 }
 
-func (d GitHubDownloader) downloadPullRequestComments(owner string, repo string, number int, version string) error {
+func (d GitHubDownloader) downloadPullRequestComments(ctx context.Context, owner string, repo string, number int, version string) error {
 	opts := &github.PullRequestListCommentsOptions{}
 	opts.ListOptions.PerPage = listOptionsPerPage
 
 	for {
-		comments, r, err := d.client.PullRequests.ListComments(context.TODO(), owner, repo, number, opts)
+		comments, r, err := d.client.PullRequests.ListComments(ctx, owner, repo, number, opts)
 		if err != nil {
 			return err
 		}
 
 		for _, comment := range comments {
 			// No need to do
-			// d.client.Issues.GetComment(context.TODO(), owner, repo, comment.GetID())
+			// d.client.Issues.GetComment(ctx, owner, repo, comment.GetID())
 			// the contents are the same, see
 			// https://developer.github.com/v3/issues/comments/#get-a-single-comment
 			// https://developer.github.com/v3/issues/comments/#list-comments-on-an-issue
 
-			err = d.SavePullRequestComment(comment)
+			err = d.SavePullRequestComment(ctx, comment)
 			if err != nil {
 				return err
 			}
@@ -264,10 +507,6 @@ func (d GitHubDownloader) downloadPullRequestComments(owner string, repo string,
 	return nil
 }
 
-func (d GitHubDownloader) DownloadOrg(name string, version string) error {
-	return fmt.Errorf("not implemented")
-}
-
 func (d GitHubDownloader) SetCurrent(version string) error {
-	return fmt.Errorf("not implemented")
+	return d.SetActiveVersion(version)
 }
@@ -0,0 +1,503 @@
+package v3
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/client"
+	"github.com/carlosms/metadata-retrieval-playground/internal/fakegithub"
+	"github.com/carlosms/metadata-retrieval-playground/internal/store"
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// memoryStorer keeps a copy of all the saved entities in memory
+type memoryStorer struct {
+	store.StdoutStorer
+
+	orgs          []*github.Organization
+	repositories  []*github.Repository
+	issues        []*github.Issue
+	issueComments []*github.IssueComment
+	prs           []*github.PullRequest
+	prComments    []*github.PullRequestComment
+	prReviews     []*github.PullRequestReview
+
+	syncState  *store.SyncState
+	mergedFrom string
+}
+
+func (s *memoryStorer) SaveOrganization(ctx context.Context, org *github.Organization) error {
+	s.orgs = append(s.orgs, org)
+	return nil
+}
+
+func (s *memoryStorer) SaveRepository(ctx context.Context, repository *github.Repository) error {
+	s.repositories = append(s.repositories, repository)
+	return nil
+}
+
+func (s *memoryStorer) SaveIssue(ctx context.Context, issue *github.Issue) error {
+	s.issues = append(s.issues, issue)
+	return nil
+}
+
+func (s *memoryStorer) SaveIssueComment(ctx context.Context, comment *github.IssueComment) error {
+	s.issueComments = append(s.issueComments, comment)
+	return nil
+}
+
+func (s *memoryStorer) SavePullRequest(ctx context.Context, pr *github.PullRequest) error {
+	s.prs = append(s.prs, pr)
+	return nil
+}
+
+func (s *memoryStorer) SavePullRequestComment(ctx context.Context, comment *github.PullRequestComment) error {
+	s.prComments = append(s.prComments, comment)
+	return nil
+}
+
+func (s *memoryStorer) SavePullRequestReview(ctx context.Context, review *github.PullRequestReview) error {
+	s.prReviews = append(s.prReviews, review)
+	return nil
+}
+
+func (s *memoryStorer) LoadSyncState(ctx context.Context, owner, name string) (*store.SyncState, error) {
+	return s.syncState, nil
+}
+
+func (s *memoryStorer) SaveSyncState(ctx context.Context, owner, name string, state *store.SyncState) error {
+	s.syncState = state
+	return nil
+}
+
+func (s *memoryStorer) MergeVersion(ctx context.Context, owner, name, from string) error {
+	s.mergedFrom = from
+	return nil
+}
+
+func replayDownloader(t *testing.T, path string) (*GitHubDownloader, *memoryStorer) {
+	cassette, err := client.LoadCassette(path)
+	require.NoError(t, err)
+
+	storer := &memoryStorer{}
+	return &GitHubDownloader{
+		Storer: storer,
+		client: github.NewClient(&http.Client{Transport: client.NewReplayer(cassette)}),
+	}, storer
+}
+
+func fakeDownloader(t *testing.T, srv *fakegithub.Server) (*GitHubDownloader, *memoryStorer) {
+	c := github.NewClient(srv.Client())
+
+	var err error
+	c.BaseURL, err = url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+
+	storer := &memoryStorer{}
+	return &GitHubDownloader{
+		Storer: storer,
+		client: c,
+	}, storer
+}
+
+func TestDownloadRepositoryReplay(t *testing.T) {
+	require := require.New(t)
+
+	d, storer := replayDownloader(t, "testdata/test-repo.json")
+	require.NoError(d.DownloadRepository("carlosms-test-org", "test-repo", "v0"))
+
+	require.Len(storer.repositories, 1)
+	require.Equal("carlosms-test-org/test-repo", storer.repositories[0].GetFullName())
+
+	require.Len(storer.issues, 2)
+	require.Len(storer.prs, 2)
+	require.Len(storer.issueComments, 2)
+	require.Len(storer.prReviews, 3)
+	require.Len(storer.prComments, 2)
+
+	// the individual endpoint includes closed_by, the list one does not
+	for _, issue := range storer.issues {
+		if issue.GetNumber() == 2 {
+			require.Equal("carlosms", issue.GetClosedBy().GetLogin())
+		}
+	}
+}
+
+func TestDownloadRepositoryPagination(t *testing.T) {
+	require := require.New(t)
+
+	cfg := fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       listOptionsPerPage + 1,
+		CommentsPerIssue:             2,
+		PullRequests:                 2,
+		CommentsPerPullRequest:       listOptionsPerPage + 1,
+		ReviewsPerPullRequest:        listOptionsPerPage + 1,
+		CommentsPerPullRequestReview: 1,
+	}
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(cfg))
+	defer srv.Close()
+
+	d, storer := fakeDownloader(t, srv)
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	require.Len(storer.repositories, 1)
+	require.Len(storer.issues, cfg.Issues)
+	require.Len(storer.prs, cfg.PullRequests)
+	require.Len(storer.issueComments, cfg.Issues*cfg.CommentsPerIssue+cfg.PullRequests*cfg.CommentsPerPullRequest)
+	require.Len(storer.prReviews, cfg.PullRequests*cfg.ReviewsPerPullRequest)
+	require.Len(storer.prComments, cfg.PullRequests*cfg.ReviewsPerPullRequest*cfg.CommentsPerPullRequestReview)
+
+	ids := make(map[int64]bool)
+	for _, c := range storer.issueComments {
+		ids[c.GetID()] = true
+	}
+	require.Equal(len(storer.issueComments), len(ids))
+}
+
+// countingTransport counts the requests done
+type countingTransport struct {
+	T     http.RoundTripper
+	count int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count++
+	return t.T.RoundTrip(req)
+}
+
+func TestDownloadRepositoryRepositoryComments(t *testing.T) {
+	require := require.New(t)
+
+	cfg := fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       20,
+		CommentsPerIssue:             10,
+		PullRequests:                 20,
+		CommentsPerPullRequest:       10,
+		ReviewsPerPullRequest:        2,
+		CommentsPerPullRequestReview: 5,
+	}
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(cfg))
+	defer srv.Close()
+
+	download := func(repoComments bool) (*memoryStorer, int) {
+		d, storer := fakeDownloader(t, srv)
+		transport := &countingTransport{T: srv.Client().Transport}
+		d.client = github.NewClient(&http.Client{Transport: transport})
+		d.client.BaseURL, _ = url.Parse(srv.URL + "/")
+		if repoComments {
+			d.UseRepositoryComments()
+		}
+
+		require.NoError(d.DownloadRepository("org", "repo", "v0"))
+		return storer, transport.count
+	}
+
+	perIssue, perIssueRequests := download(false)
+	perRepo, perRepoRequests := download(true)
+
+	require.Len(perRepo.issues, cfg.Issues)
+	require.Len(perRepo.prs, cfg.PullRequests)
+	require.Len(perRepo.prReviews, cfg.PullRequests*cfg.ReviewsPerPullRequest)
+	require.ElementsMatch(perIssue.issueComments, perRepo.issueComments)
+	require.ElementsMatch(perIssue.prComments, perRepo.prComments)
+
+	// one request less per issue, and two per PR, plus the pages of all the
+	// comments: 400 issue comments and 200 review comments
+	require.Equal(perIssueRequests-cfg.Issues-2*cfg.PullRequests+4+2, perRepoRequests)
+}
+
+func TestDownloadRepositoryShallow(t *testing.T) {
+	require := require.New(t)
+
+	cfg := fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       listOptionsPerPage + 1,
+		CommentsPerIssue:             1,
+		PullRequests:                 listOptionsPerPage + 1,
+		CommentsPerPullRequest:       1,
+		ReviewsPerPullRequest:        1,
+		CommentsPerPullRequestReview: 1,
+	}
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(cfg))
+	defer srv.Close()
+
+	d, storer := fakeDownloader(t, srv)
+	transport := &countingTransport{T: srv.Client().Transport}
+	d.client = github.NewClient(&http.Client{Transport: transport})
+	d.client.BaseURL, _ = url.Parse(srv.URL + "/")
+	d.UseShallowMode()
+
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	require.Len(storer.issues, cfg.Issues)
+	require.Len(storer.prs, cfg.PullRequests)
+	require.Len(storer.issueComments, cfg.Issues+cfg.PullRequests)
+	require.Len(storer.prReviews, cfg.PullRequests)
+	require.Len(storer.prComments, cfg.PullRequests)
+
+	for _, issue := range storer.issues {
+		require.False(issue.IsPullRequest())
+	}
+	for _, pr := range storer.prs {
+		require.NotEmpty(pr.GetBase().GetRef())
+		require.Nil(pr.Merged)
+	}
+
+	// rate limit twice, repository, 3 pages of issues, 2 pages of PRs, the
+	// comments of each issue, and the comments, reviews and review comments of
+	// each PR
+	require.Equal(2+1+3+2+cfg.Issues+3*cfg.PullRequests, transport.count)
+}
+
+func TestDownloadRepositoryIncremental(t *testing.T) {
+	require := require.New(t)
+
+	cfg := fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       3,
+		CommentsPerIssue:             2,
+		PullRequests:                 listOptionsPerPage,
+		CommentsPerPullRequest:       1,
+		ReviewsPerPullRequest:        1,
+		CommentsPerPullRequestReview: 1,
+	}
+
+	repo := fakegithub.NewRepository(cfg)
+
+	// the issues are updated after the PRs, so the first page of the issues
+	// listing has only PRs and the second one only issues
+	last := repo.PullRequests[len(repo.PullRequests)-1].UpdatedAt
+	for i, issue := range repo.Issues {
+		issue.UpdatedAt = last.Add(time.Duration(i+1) * time.Minute)
+	}
+
+	srv := fakegithub.NewServer(repo)
+	defer srv.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var state *store.SyncState
+	download := func(version string) (*memoryStorer, int) {
+		start = start.Add(time.Hour)
+		srv.SetDate(start)
+
+		d, storer := fakeDownloader(t, srv)
+		d.UseIncrementalSync()
+		storer.syncState = state
+
+		rate0, err := d.rateRemaining(context.Background())
+		require.NoError(err)
+
+		require.NoError(d.DownloadRepository("org", "repo", version))
+
+		rate1, err := d.rateRemaining(context.Background())
+		require.NoError(err)
+
+		require.NotNil(storer.syncState)
+		require.Equal(version, storer.syncState.Version)
+		state = storer.syncState
+
+		return storer, rate0 - rate1
+	}
+
+	// without a previous state everything is downloaded
+	storer, _ := download("v1")
+	require.Equal("", storer.mergedFrom)
+	require.Len(storer.repositories, 1)
+	require.Len(storer.issues, cfg.Issues)
+	require.Len(storer.prs, cfg.PullRequests)
+	require.Len(storer.prReviews, cfg.PullRequests)
+	require.Len(storer.issueComments, cfg.Issues*cfg.CommentsPerIssue+cfg.PullRequests)
+	require.Len(storer.prComments, cfg.PullRequests)
+
+	// one issue is edited and another one gets a new comment
+	updated := start.Add(time.Minute)
+	repo.Issues[0].Title = "Edited"
+	repo.Issues[0].UpdatedAt = updated
+	repo.Issues[1].Comments = append(repo.Issues[1].Comments, &fakegithub.Comment{
+		ID:        1,
+		Author:    "alice",
+		Body:      "New comment",
+		CreatedAt: updated,
+		UpdatedAt: updated,
+	})
+
+	storer, _ = download("v2")
+	require.Equal("v1", storer.mergedFrom)
+	require.Len(storer.repositories, 0)
+	require.Len(storer.issues, 1)
+	require.Equal("Edited", storer.issues[0].GetTitle())
+	require.Len(storer.issueComments, 1)
+	require.Equal("New comment", storer.issueComments[0].GetBody())
+	require.Len(storer.prs, 0)
+	require.Len(storer.prComments, 0)
+
+	// the listings that changed are requested again with a new since
+	storer, _ = download("v3")
+	require.Equal("v2", storer.mergedFrom)
+	require.Len(storer.issues, 0)
+	require.Len(storer.issueComments, 0)
+
+	// and then all the requests are not modified
+	storer, used := download("v4")
+	require.Equal("v3", storer.mergedFrom)
+	require.Len(storer.issues, 0)
+	require.Len(storer.issueComments, 0)
+	require.Equal(0, used)
+}
+
+// failingTransport responds with a 500 to the requests with the path prefix
+type failingTransport struct {
+	T      http.RoundTripper
+	prefix string
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(req.URL.Path, t.prefix) {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "500 Internal Server Error",
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(`{"message":"Server Error"}`)),
+			Request:    req,
+		}, nil
+	}
+
+	return t.T.RoundTrip(req)
+}
+
+func TestDownloadOrg(t *testing.T) {
+	newRepo := func(owner, name string) *fakegithub.Repository {
+		return fakegithub.NewRepository(fakegithub.Config{
+			Owner:            owner,
+			Name:             name,
+			Issues:           2,
+			CommentsPerIssue: 1,
+		})
+	}
+
+	fork := newRepo("org", "fork")
+	fork.Fork = true
+	archived := newRepo("org", "archived")
+	archived.Archived = true
+	private := newRepo("org", "private")
+	private.Private = true
+
+	srv := fakegithub.NewServer(
+		newRepo("org", "public"), fork, archived, private,
+		newRepo("other", "repo"))
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		filter OrgFilter
+		repos  []string
+	}{
+		{"all", OrgFilter{}, []string{"public", "fork", "archived", "private"}},
+		{"no forks", OrgFilter{SkipForks: true}, []string{"public", "archived", "private"}},
+		{"no archived", OrgFilter{SkipArchived: true}, []string{"public", "fork", "private"}},
+		{"public", OrgFilter{Visibility: "public"}, []string{"public", "fork", "archived"}},
+		{"private", OrgFilter{Visibility: "private"}, []string{"private"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require := require.New(t)
+
+			d, storer := fakeDownloader(t, srv)
+			d.SetOrgFilter(c.filter)
+			require.NoError(d.DownloadOrg("org", "v0"))
+
+			require.Len(storer.orgs, 1)
+			require.Equal("org", storer.orgs[0].GetLogin())
+
+			var names []string
+			for _, r := range storer.repositories {
+				names = append(names, r.GetName())
+			}
+			require.ElementsMatch(c.repos, names)
+			require.Len(storer.issues, 2*len(c.repos))
+		})
+	}
+
+	t.Run("failure", func(t *testing.T) {
+		require := require.New(t)
+
+		d, storer := fakeDownloader(t, srv)
+		d.client = github.NewClient(&http.Client{Transport: &failingTransport{
+			T:      srv.Client().Transport,
+			prefix: "/repos/org/fork/issues",
+		}})
+		d.client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+		err := d.DownloadOrg("org", "v0")
+		require.Error(err)
+		require.Contains(err.Error(), "1 of 4 repositories")
+		require.Contains(err.Error(), "fork")
+
+		// the memory storer does not roll back, the other repositories are
+		// downloaded completely
+		require.Len(storer.repositories, 4)
+		require.Len(storer.issues, 2*3)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		d, _ := fakeDownloader(t, srv)
+		require.Error(t, d.DownloadOrg("missing", "v0"))
+	})
+
+	t.Run("tracing", func(t *testing.T) {
+		require := require.New(t)
+
+		exporter := tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+		d, _ := fakeDownloader(t, srv)
+		require.NoError(d.DownloadOrg("org", "v0"))
+
+		var org *sdktrace.SpanSnapshot
+		var repos []*sdktrace.SpanSnapshot
+		for _, s := range exporter.GetSpans() {
+			switch s.Name {
+			case "DownloadOrg":
+				org = s
+			case "DownloadRepository":
+				repos = append(repos, s)
+			}
+		}
+
+		require.NotNil(org)
+		require.Len(repos, 4)
+		for _, s := range repos {
+			require.Equal(org.SpanContext.SpanID(), s.Parent.SpanID())
+		}
+	})
+}
+
+func TestDownloadRepositoryNotFound(t *testing.T) {
+	srv := fakegithub.NewServer()
+	defer srv.Close()
+
+	d, _ := fakeDownloader(t, srv)
+	require.Error(t, d.DownloadRepository("org", "missing", "v0"))
+}
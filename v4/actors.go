@@ -0,0 +1,172 @@
+package v4
+
+import (
+	"context"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/carlosms/metadata-retrieval-playground/internal/progress"
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
+	"github.com/shurcooL/githubv4"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// maxNodeIDs is the maximum number of IDs accepted by nodes(ids:)
+const maxNodeIDs = 100
+
+// actorCollector is a storer that records the node IDs of the actors of all
+// the saved entities, in the order they are seen, so their accounts can be
+// downloaded at the end with downloadActors. ghost is set if any author is
+// null.
+type actorCollector struct {
+	storer
+
+	ids   []string
+	seen  map[string]bool
+	ghost bool
+}
+
+func newActorCollector(s storer) *actorCollector {
+	return &actorCollector{storer: s, seen: make(map[string]bool)}
+}
+
+// author records the author of an entity, which is only null for deleted
+// accounts
+func (c *actorCollector) author(a Actor) {
+	if a.IsNull() {
+		c.ghost = true
+	}
+
+	c.add(a)
+}
+
+func (c *actorCollector) add(actors ...Actor) {
+	for _, a := range actors {
+		c.addID(a.Node.Id)
+	}
+}
+
+func (c *actorCollector) addID(id string) {
+	if id == "" || c.seen[id] {
+		return
+	}
+
+	c.seen[id] = true
+	c.ids = append(c.ids, id)
+}
+
+func (c *actorCollector) saveRepository(ctx context.Context, repository *RepositoryFields) error {
+	c.add(repository.Owner)
+	return c.storer.saveRepository(ctx, repository)
+}
+
+func (c *actorCollector) saveIssue(ctx context.Context, repositoryOwner, repositoryName string, issue *Issue) error {
+	c.author(issue.Author)
+	return c.storer.saveIssue(ctx, repositoryOwner, repositoryName, issue)
+}
+
+func (c *actorCollector) saveIssueComment(ctx context.Context, repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error {
+	c.author(comment.Author)
+	c.add(comment.Editor)
+	return c.storer.saveIssueComment(ctx, repositoryOwner, repositoryName, issueNumber, comment)
+}
+
+func (c *actorCollector) savePullRequest(ctx context.Context, repositoryOwner, repositoryName string, pr *PullRequest) error {
+	c.author(pr.Author)
+	c.add(pr.Editor, pr.MergedBy)
+	return c.storer.savePullRequest(ctx, repositoryOwner, repositoryName, pr)
+}
+
+func (c *actorCollector) savePullRequestReview(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, review *PullRequestReview) error {
+	c.author(review.Author)
+	c.add(review.Editor)
+	return c.storer.savePullRequestReview(ctx, repositoryOwner, repositoryName, pullRequestNumber, review)
+}
+
+func (c *actorCollector) saveReviewComment(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, reviewID int, comment *PullRequestReviewComment) error {
+	c.author(comment.Author)
+	c.add(comment.Editor)
+	return c.storer.saveReviewComment(ctx, repositoryOwner, repositoryName, pullRequestNumber, reviewID, comment)
+}
+
+func (c *actorCollector) saveReviewThread(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, thread *PullRequestReviewThread) error {
+	c.addID(thread.ResolvedBy.Id)
+	return c.storer.saveReviewThread(ctx, repositoryOwner, repositoryName, pullRequestNumber, thread)
+}
+
+// ghost is saved as the user of the null authors
+var ghost = UserFields{
+	Typename:   metadata.GhostType,
+	DatabaseId: int(metadata.Ghost.DatabaseID),
+	Login:      metadata.Ghost.Login,
+}
+
+// downloadActors looks up the node ids in batches of maxNodeIDs with
+// nodes(ids:), and saves the organizations, and the users, bots and
+// mannequins as users. Other actor types, and the IDs that do not resolve to
+// a node anymore, are skipped.
+func (d GitHubDownloader) downloadActors(ctx context.Context, logger log.Logger, ids []string) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadActors", attribute.Int("actors", len(ids)))
+	defer func() { tracing.End(span, err) }()
+
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > maxNodeIDs {
+			n = maxNodeIDs
+		}
+
+		batch := make([]githubv4.ID, n)
+		for i, id := range ids[:n] {
+			batch[i] = githubv4.ID(id)
+		}
+		ids = ids[n:]
+
+		var q struct {
+			Nodes []struct {
+				Typename     string             `graphql:"__typename"`
+				User         UserFields         `graphql:"... on User"`
+				Bot          BotFields          `graphql:"... on Bot"`
+				Mannequin    MannequinFields    `graphql:"... on Mannequin"`
+				Organization OrganizationFields `graphql:"... on Organization"`
+			} `graphql:"nodes(ids: $ids)"`
+		}
+
+		err := d.client.Query(ctx, &q, map[string]interface{}{"ids": batch})
+		if err != nil {
+			return err
+		}
+		progress.FromContext(ctx).Page()
+
+		for _, node := range q.Nodes {
+			switch node.Typename {
+			case "User":
+				err = d.storer.saveUser(ctx, &node.User)
+			case "Bot":
+				err = d.storer.saveUser(ctx, &UserFields{
+					Typename:   node.Typename,
+					CreatedAt:  node.Bot.CreatedAt,
+					DatabaseId: node.Bot.DatabaseId,
+					Login:      node.Bot.Login,
+				})
+			case "Mannequin":
+				err = d.storer.saveUser(ctx, &UserFields{
+					Typename:   node.Typename,
+					CreatedAt:  node.Mannequin.CreatedAt,
+					DatabaseId: node.Mannequin.DatabaseId,
+					Email:      node.Mannequin.Email,
+					Login:      node.Mannequin.Login,
+				})
+			case "Organization":
+				err = d.storer.saveOrganization(ctx, &node.Organization)
+			default:
+				logger.With(log.Fields{"type": node.Typename}).Debugf("actor skipped")
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP VIEW IF EXISTS pull_request_review_comments;
+DROP VIEW IF EXISTS pull_request_reviews;
+DROP VIEW IF EXISTS pull_requests;
+
+DROP TABLE IF EXISTS pull_request_review_comments_versioned;
+DROP TABLE IF EXISTS pull_request_reviews_versioned;
+DROP TABLE IF EXISTS pull_requests_versioned;
+
+COMMIT;
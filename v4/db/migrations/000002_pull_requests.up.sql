@@ -0,0 +1,56 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS pull_requests_versioned (
+  pk                SERIAL PRIMARY KEY,
+  versions          text ARRAY,
+
+  database_id       bigint,
+  title             text,
+  body              text,
+  number            int,
+  state             text,
+  author            text,
+  repository_owner  text,
+  repository_name   text,
+
+  UNIQUE(database_id, title, body, number, state, author, repository_owner, repository_name)
+);
+
+CREATE INDEX IF NOT EXISTS pull_requests_versioned_versions ON pull_requests_versioned (versions);
+
+CREATE TABLE IF NOT EXISTS pull_request_reviews_versioned (
+  pk                   SERIAL PRIMARY KEY,
+  versions             text ARRAY,
+
+  database_id          bigint,
+  author               text,
+  body                 text,
+  state                text,
+  repository_owner     text,
+  repository_name      text,
+  pull_request_number  int,
+
+  UNIQUE(database_id, author, body, state, repository_owner, repository_name, pull_request_number)
+);
+
+CREATE INDEX IF NOT EXISTS pull_request_reviews_versioned_versions ON pull_request_reviews_versioned (versions);
+
+CREATE TABLE IF NOT EXISTS pull_request_review_comments_versioned (
+  pk                      SERIAL PRIMARY KEY,
+  versions                text ARRAY,
+
+  database_id             bigint,
+  author                  text,
+  body                    text,
+  path                    text,
+  pull_request_review_id  bigint,
+  repository_owner        text,
+  repository_name         text,
+  pull_request_number     int,
+
+  UNIQUE(database_id, author, body, path, pull_request_review_id, repository_owner, repository_name, pull_request_number)
+);
+
+CREATE INDEX IF NOT EXISTS pull_request_review_comments_versioned_versions ON pull_request_review_comments_versioned (versions);
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS sync_states (
+  repository_owner  text,
+  repository_name   text,
+  version           text,
+  endpoints         jsonb,
+
+  PRIMARY KEY(repository_owner, repository_name)
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP VIEW IF EXISTS organizations;
+DROP TABLE IF EXISTS organizations_versioned;
+
+COMMIT;
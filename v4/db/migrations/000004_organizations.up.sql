@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS organizations_versioned (
+  pk           SERIAL PRIMARY KEY,
+  versions     text ARRAY,
+
+  database_id  bigint,
+  login        text,
+  name         text,
+  description  text,
+  created_at   timestamptz,
+
+  UNIQUE(database_id, login, name, description, created_at)
+);
+
+CREATE INDEX IF NOT EXISTS organizations_versioned_versions ON organizations_versioned (versions);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP VIEW IF EXISTS users;
+DROP TABLE IF EXISTS users_versioned;
+
+COMMIT;
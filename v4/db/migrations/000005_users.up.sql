@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS users_versioned (
+  pk           SERIAL PRIMARY KEY,
+  versions     text ARRAY,
+
+  database_id  bigint,
+  login        text,
+  name         text,
+  company      text,
+  location     text,
+  email        text,
+  created_at   timestamptz,
+
+  UNIQUE(database_id, login, name, company, location, email, created_at)
+);
+
+CREATE INDEX IF NOT EXISTS users_versioned_versions ON users_versioned (versions);
+
+COMMIT;
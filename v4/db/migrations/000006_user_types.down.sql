@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE users_versioned DROP CONSTRAINT IF EXISTS users_versioned_unique;
+ALTER TABLE users_versioned DROP COLUMN IF EXISTS type;
+ALTER TABLE users_versioned
+  ADD UNIQUE(database_id, login, name, company, location, email, created_at);
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+ALTER TABLE users_versioned ADD COLUMN IF NOT EXISTS type text;
+
+-- the type is part of the row, replace the unique constraint of the columns
+DO $$
+DECLARE c text;
+BEGIN
+  FOR c IN SELECT conname FROM pg_constraint
+    WHERE conrelid = 'users_versioned'::regclass AND contype = 'u'
+  LOOP
+    EXECUTE format('ALTER TABLE users_versioned DROP CONSTRAINT %I', c);
+  END LOOP;
+END $$;
+
+ALTER TABLE users_versioned ADD CONSTRAINT users_versioned_unique
+  UNIQUE(database_id, login, type, name, company, location, email, created_at);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP VIEW IF EXISTS reactions;
+DROP TABLE IF EXISTS reactions_versioned;
+
+COMMIT;
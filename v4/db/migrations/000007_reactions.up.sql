@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS reactions_versioned (
+  pk                SERIAL PRIMARY KEY,
+  versions          text ARRAY,
+
+  subject_id        bigint,
+  subject_type      text,
+  content           text,
+  count             int,
+  users             text ARRAY,
+  repository_owner  text,
+  repository_name   text,
+  number            int,
+
+  UNIQUE(subject_id, subject_type, content, count, users, repository_owner, repository_name, number)
+);
+
+CREATE INDEX IF NOT EXISTS reactions_versioned_versions ON reactions_versioned (versions);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP VIEW IF EXISTS pull_request_review_threads;
+DROP TABLE IF EXISTS pull_request_review_threads_versioned;
+
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS pull_request_review_threads_versioned (
+  pk                   SERIAL PRIMARY KEY,
+  versions             text ARRAY,
+
+  node_id              text,
+  path                 text,
+  line                 int,
+  original_line        int,
+  diff_side            text,
+  is_resolved          boolean,
+  is_outdated          boolean,
+  resolved_by          text,
+  comment_ids          bigint ARRAY,
+  repository_owner     text,
+  repository_name      text,
+  pull_request_number  int,
+
+  UNIQUE(node_id, path, line, original_line, diff_side, is_resolved, is_outdated, resolved_by, comment_ids, repository_owner, repository_name, pull_request_number)
+);
+
+CREATE INDEX IF NOT EXISTS pull_request_review_threads_versioned_versions ON pull_request_review_threads_versioned (versions);
+
+COMMIT;
@@ -0,0 +1,85 @@
+package v4
+
+import (
+	"context"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/progress"
+	"github.com/carlosms/metadata-retrieval-playground/internal/store"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// instrumentedStorer records the metrics, a span and the progress for each
+// save
+type instrumentedStorer struct {
+	storer
+	name string
+}
+
+func (s *instrumentedStorer) observe(ctx context.Context, typ string, save func(context.Context) error, attrs ...attribute.KeyValue) error {
+	err := store.Observe(ctx, s.name, typ, save, attrs...)
+	if err == nil {
+		progress.FromContext(ctx).Inc(typ)
+	}
+
+	return err
+}
+
+func (s *instrumentedStorer) saveRepository(ctx context.Context, repository *RepositoryFields) error {
+	return s.observe(ctx, "repository", func(ctx context.Context) error {
+		return s.storer.saveRepository(ctx, repository)
+	})
+}
+
+func (s *instrumentedStorer) saveIssue(ctx context.Context, repositoryOwner, repositoryName string, issue *Issue) error {
+	return s.observe(ctx, progress.Issues, func(ctx context.Context) error {
+		return s.storer.saveIssue(ctx, repositoryOwner, repositoryName, issue)
+	}, attribute.Int("issue", issue.Number))
+}
+
+func (s *instrumentedStorer) saveIssueComment(ctx context.Context, repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error {
+	return s.observe(ctx, progress.IssueComments, func(ctx context.Context) error {
+		return s.storer.saveIssueComment(ctx, repositoryOwner, repositoryName, issueNumber, comment)
+	}, attribute.Int("issue", issueNumber))
+}
+
+func (s *instrumentedStorer) savePullRequest(ctx context.Context, repositoryOwner, repositoryName string, pr *PullRequest) error {
+	return s.observe(ctx, progress.PullRequests, func(ctx context.Context) error {
+		return s.storer.savePullRequest(ctx, repositoryOwner, repositoryName, pr)
+	}, attribute.Int("pr", pr.Number))
+}
+
+func (s *instrumentedStorer) savePullRequestReview(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, review *PullRequestReview) error {
+	return s.observe(ctx, progress.PullRequestReviews, func(ctx context.Context) error {
+		return s.storer.savePullRequestReview(ctx, repositoryOwner, repositoryName, pullRequestNumber, review)
+	}, attribute.Int("pr", pullRequestNumber))
+}
+
+func (s *instrumentedStorer) saveReviewComment(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, reviewID int, comment *PullRequestReviewComment) error {
+	return s.observe(ctx, progress.ReviewComments, func(ctx context.Context) error {
+		return s.storer.saveReviewComment(ctx, repositoryOwner, repositoryName, pullRequestNumber, reviewID, comment)
+	}, attribute.Int("pr", pullRequestNumber))
+}
+
+func (s *instrumentedStorer) saveReviewThread(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, thread *PullRequestReviewThread) error {
+	return s.observe(ctx, progress.ReviewThreads, func(ctx context.Context) error {
+		return s.storer.saveReviewThread(ctx, repositoryOwner, repositoryName, pullRequestNumber, thread)
+	}, attribute.Int("pr", pullRequestNumber))
+}
+
+func (s *instrumentedStorer) saveUser(ctx context.Context, user *UserFields) error {
+	return s.observe(ctx, "user", func(ctx context.Context) error {
+		return s.storer.saveUser(ctx, user)
+	})
+}
+
+func (s *instrumentedStorer) saveOrganization(ctx context.Context, org *OrganizationFields) error {
+	return s.observe(ctx, "organization", func(ctx context.Context) error {
+		return s.storer.saveOrganization(ctx, org)
+	})
+}
+
+func (s *instrumentedStorer) saveReactions(ctx context.Context, repositoryOwner, repositoryName string, reactions *Reactions) error {
+	return s.observe(ctx, "reaction", func(ctx context.Context) error {
+		return s.storer.saveReactions(ctx, repositoryOwner, repositoryName, reactions)
+	}, attribute.String("subject", reactions.SubjectType), attribute.Int("number", reactions.Number))
+}
@@ -0,0 +1,310 @@
+package v4
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/carlosms/metadata-retrieval-playground/internal/client"
+	"github.com/shurcooL/githubv4"
+)
+
+// NewMetadataDownloader returns a downloader that maps the GraphQL types to
+// the canonical metadata types and saves them to s
+func NewMetadataDownloader(httpClient *http.Client, s metadata.Storer) (*GitHubDownloader, error) {
+	c, err := client.NewClient(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitHubDownloader{
+		storer: &instrumentedStorer{storer: &metadataStorer{s: s}, name: "metadata"},
+		client: githubv4.NewClient(c),
+	}, nil
+}
+
+// metadataStorer is a storer that saves to a metadata.Storer
+type metadataStorer struct {
+	s metadata.Storer
+}
+
+func (m *metadataStorer) saveRepository(ctx context.Context, repository *RepositoryFields) error {
+	return m.s.SaveRepository(ctx, MapRepository(repository))
+}
+
+func (m *metadataStorer) saveIssue(ctx context.Context, repositoryOwner, repositoryName string, issue *Issue) error {
+	return m.s.SaveIssue(ctx, MapIssue(repositoryOwner, repositoryName, &issue.IssueFields))
+}
+
+func (m *metadataStorer) saveIssueComment(ctx context.Context, repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error {
+	return m.s.SaveComment(ctx, MapIssueComment(repositoryOwner, repositoryName, issueNumber, comment))
+}
+
+func (m *metadataStorer) savePullRequest(ctx context.Context, repositoryOwner, repositoryName string, pr *PullRequest) error {
+	return m.s.SavePullRequest(ctx, MapPullRequest(repositoryOwner, repositoryName, &pr.PullRequestFields))
+}
+
+func (m *metadataStorer) savePullRequestReview(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, review *PullRequestReview) error {
+	return m.s.SaveReview(ctx, MapReview(repositoryOwner, repositoryName, pullRequestNumber, review))
+}
+
+func (m *metadataStorer) saveReviewComment(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, reviewID int, comment *PullRequestReviewComment) error {
+	return m.s.SaveReviewComment(ctx, MapReviewComment(repositoryOwner, repositoryName, pullRequestNumber, reviewID, comment))
+}
+
+func (m *metadataStorer) saveReviewThread(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, thread *PullRequestReviewThread) error {
+	return m.s.SaveReviewThread(ctx, MapReviewThread(repositoryOwner, repositoryName, pullRequestNumber, thread))
+}
+
+func (m *metadataStorer) saveUser(ctx context.Context, user *UserFields) error {
+	return m.s.SaveUser(ctx, MapUser(user))
+}
+
+func (m *metadataStorer) saveOrganization(ctx context.Context, org *OrganizationFields) error {
+	return m.s.SaveOrganization(ctx, MapOrganization(org))
+}
+
+func (m *metadataStorer) saveReactions(ctx context.Context, repositoryOwner, repositoryName string, reactions *Reactions) error {
+	for _, r := range MapReactions(repositoryOwner, repositoryName, reactions) {
+		if err := m.s.SaveReaction(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *metadataStorer) begin() error {
+	return m.s.Begin()
+}
+
+func (m *metadataStorer) commit() error {
+	return m.s.Commit()
+}
+
+func (m *metadataStorer) rollback() error {
+	return m.s.Rollback()
+}
+
+func (m *metadataStorer) version(v string) {
+	m.s.Version(v)
+}
+
+func (m *metadataStorer) setActiveVersion(v string) error {
+	return m.s.SetActiveVersion(v)
+}
+
+func (m *metadataStorer) cleanup(currentVersion string) error {
+	return m.s.Cleanup(currentVersion)
+}
+
+// MapUser maps a GraphQL user, bot or mannequin to the canonical type
+func MapUser(u *UserFields) *metadata.User {
+	login := u.Login
+	if u.Typename == metadata.BotType {
+		login = botLogin(login)
+	}
+
+	return &metadata.User{
+		DatabaseID: int64(u.DatabaseId),
+		Login:      login,
+		Type:       u.Typename,
+		Name:       u.Name,
+		Company:    u.Company,
+		Location:   u.Location,
+		Email:      u.Email,
+		CreatedAt:  u.CreatedAt,
+	}
+}
+
+// MapOrganization maps a GraphQL organization to the canonical type
+func MapOrganization(o *OrganizationFields) *metadata.Organization {
+	return &metadata.Organization{
+		DatabaseID:  int64(o.DatabaseId),
+		Login:       o.Login,
+		Name:        o.Name,
+		Description: o.Description,
+		CreatedAt:   o.CreatedAt,
+	}
+}
+
+// MapActor returns the login of an actor. Null actors are deleted accounts,
+// mapped to metadata.Ghost.
+func MapActor(a Actor) string {
+	switch {
+	case a.IsNull():
+		return metadata.Ghost.Login
+	case a.Typename == metadata.BotType:
+		return botLogin(a.Login)
+	}
+
+	return a.Login
+}
+
+// botLogin returns the login of a bot used by the REST API and the migration
+// archives, GraphQL returns it without the [bot] suffix
+func botLogin(login string) string {
+	return login + "[bot]"
+}
+
+// MapRepository maps a GraphQL repository to the canonical type
+func MapRepository(r *RepositoryFields) *metadata.Repository {
+	return &metadata.Repository{
+		DatabaseID:  int64(r.DatabaseId),
+		Owner:       r.Owner.Login,
+		Name:        r.Name,
+		Description: r.Description,
+		Homepage:    r.HomepageUrl,
+		Private:     r.IsPrivate,
+		Fork:        r.IsFork,
+		Archived:    r.IsArchived,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// MapIssue maps a GraphQL issue of the repository owner/name to the
+// canonical type. The assignees and labels are not queried.
+func MapIssue(owner, name string, i *IssueFields) *metadata.Issue {
+	return &metadata.Issue{
+		DatabaseID:      int64(i.DatabaseId),
+		RepositoryOwner: owner,
+		RepositoryName:  name,
+		Number:          i.Number,
+		Title:           i.Title,
+		Body:            i.Body,
+		State:           i.State,
+		Author:          MapActor(i.Author),
+		CreatedAt:       i.CreatedAt,
+		UpdatedAt:       i.UpdatedAt,
+		ClosedAt:        optionalTime(i.ClosedAt),
+	}
+}
+
+// MapPullRequest maps a GraphQL PR of the repository owner/name to the
+// canonical type. The assignees, labels and commit SHAs are not queried.
+func MapPullRequest(owner, name string, pr *PullRequestFields) *metadata.PullRequest {
+	return &metadata.PullRequest{
+		Issue: metadata.Issue{
+			DatabaseID:      int64(pr.DatabaseId),
+			RepositoryOwner: owner,
+			RepositoryName:  name,
+			Number:          pr.Number,
+			Title:           pr.Title,
+			Body:            pr.Body,
+			State:           pr.State,
+			Author:          MapActor(pr.Author),
+			CreatedAt:       pr.CreatedAt,
+			UpdatedAt:       pr.UpdatedAt,
+			ClosedAt:        optionalTime(pr.ClosedAt),
+		},
+		BaseRef:  pr.BaseRefName,
+		HeadRef:  pr.HeadRefName,
+		Merged:   pr.Merged,
+		MergedAt: optionalTime(pr.MergedAt),
+	}
+}
+
+// MapIssueComment maps a GraphQL comment of the issue or PR number to the
+// canonical type
+func MapIssueComment(owner, name string, number int, c *IssueComment) *metadata.Comment {
+	return &metadata.Comment{
+		DatabaseID:      int64(c.DatabaseId),
+		RepositoryOwner: owner,
+		RepositoryName:  name,
+		Number:          number,
+		Author:          MapActor(c.Author),
+		Body:            c.Body,
+		CreatedAt:       c.CreatedAt,
+		UpdatedAt:       c.UpdatedAt,
+	}
+}
+
+// MapReview maps a GraphQL review of the PR number to the canonical type
+func MapReview(owner, name string, number int, r *PullRequestReview) *metadata.Review {
+	return &metadata.Review{
+		DatabaseID:        int64(r.DatabaseId),
+		RepositoryOwner:   owner,
+		RepositoryName:    name,
+		PullRequestNumber: number,
+		Author:            MapActor(r.Author),
+		Body:              r.Body,
+		State:             r.State,
+		SubmittedAt:       optionalTime(r.SubmittedAt),
+	}
+}
+
+// MapReviewComment maps a GraphQL comment of the review reviewID, of the PR
+// number, to the canonical type. The diff fields are not queried.
+func MapReviewComment(owner, name string, number int, reviewID int, c *PullRequestReviewComment) *metadata.ReviewComment {
+	return &metadata.ReviewComment{
+		DatabaseID:        int64(c.DatabaseId),
+		RepositoryOwner:   owner,
+		RepositoryName:    name,
+		PullRequestNumber: number,
+		ReviewID:          int64(reviewID),
+		Author:            MapActor(c.Author),
+		Body:              c.Body,
+		CreatedAt:         c.CreatedAt,
+		UpdatedAt:         c.UpdatedAt,
+	}
+}
+
+// MapReviewThread maps a review thread of the PR number of the repository
+// owner/name. ResolvedBy is empty for unresolved threads.
+func MapReviewThread(owner, name string, number int, t *PullRequestReviewThread) *metadata.ReviewThread {
+	ids := make([]int64, len(t.Comments.Nodes))
+	for i, c := range t.Comments.Nodes {
+		ids[i] = int64(c.DatabaseId)
+	}
+
+	return &metadata.ReviewThread{
+		ID:                t.Id,
+		RepositoryOwner:   owner,
+		RepositoryName:    name,
+		PullRequestNumber: number,
+		Path:              t.Path,
+		Line:              t.Line,
+		OriginalLine:      t.OriginalLine,
+		DiffSide:          t.DiffSide,
+		IsResolved:        t.IsResolved,
+		IsOutdated:        t.IsOutdated,
+		ResolvedBy:        t.ResolvedBy.Login,
+		CommentIDs:        ids,
+	}
+}
+
+// optionalTime returns nil for the zero time, the value of null GraphQL
+// fields
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// MapReactions maps the reaction groups of an entity of the repository
+// owner/name to the canonical type, one for each content with reactions
+func MapReactions(owner, name string, r *Reactions) []*metadata.Reaction {
+	var reactions []*metadata.Reaction
+	for _, g := range r.Groups {
+		if g.Users.TotalCount == 0 {
+			continue
+		}
+
+		reactions = append(reactions, &metadata.Reaction{
+			RepositoryOwner: owner,
+			RepositoryName:  name,
+			Number:          r.Number,
+			SubjectType:     r.SubjectType,
+			SubjectID:       int64(r.SubjectId),
+			Content:         g.Content,
+			Count:           g.Users.TotalCount,
+			Users:           r.Users[g.Content],
+		})
+	}
+
+	return reactions
+}
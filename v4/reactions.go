@@ -0,0 +1,226 @@
+package v4
+
+import (
+	"context"
+
+	"github.com/carlosms/metadata-retrieval-playground"
+	"github.com/carlosms/metadata-retrieval-playground/internal/progress"
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
+	"github.com/shurcooL/githubv4"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// The subject types of Reactions, the tables of the entities reacted to
+const (
+	IssueSubject         = "issues"
+	PullRequestSubject   = "pull_requests"
+	IssueCommentSubject  = "issue_comments"
+	ReviewSubject        = "pull_request_reviews"
+	ReviewCommentSubject = "pull_request_review_comments"
+)
+
+// Reactions are the reaction groups of an issue, PR, comment, review or
+// review comment. Number is the issue or PR number. Users has the logins that
+// reacted by content, only with UseReactionUsers.
+type Reactions struct {
+	Number      int
+	SubjectType string
+	SubjectId   int
+	Groups      []ReactionGroup
+	Users       map[string][]string
+
+	// subjectNode is the node ID used to query the users
+	subjectNode string
+}
+
+// total returns the number of reactions of all the groups
+func (r *Reactions) total() int {
+	var n int
+	for _, g := range r.Groups {
+		n += g.Users.TotalCount
+	}
+
+	return n
+}
+
+// UseReactionUsers enables the download of the users of each reaction, which
+// needs one more request for every 100 entities with reactions. By default
+// only the count of each reaction content is saved.
+func (d *GitHubDownloader) UseReactionUsers() {
+	d.reactionUsers = true
+}
+
+// reactionCollector is a storer that records the reaction groups of the saved
+// entities, to save them at the end with downloadReactions once the users are
+// downloaded
+type reactionCollector struct {
+	storer
+
+	owner, name string
+	reactions   []*Reactions
+}
+
+func (c *reactionCollector) add(owner, name string, number int, typ string, id int, node string, groups []ReactionGroup) {
+	c.owner, c.name = owner, name
+	c.reactions = append(c.reactions, &Reactions{
+		Number:      number,
+		SubjectType: typ,
+		SubjectId:   id,
+		Groups:      groups,
+		subjectNode: node,
+	})
+}
+
+func (c *reactionCollector) saveIssue(ctx context.Context, repositoryOwner, repositoryName string, issue *Issue) error {
+	c.add(repositoryOwner, repositoryName, issue.Number, IssueSubject, issue.DatabaseId, issue.Id, issue.ReactionGroups)
+	return c.storer.saveIssue(ctx, repositoryOwner, repositoryName, issue)
+}
+
+func (c *reactionCollector) saveIssueComment(ctx context.Context, repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error {
+	c.add(repositoryOwner, repositoryName, issueNumber, IssueCommentSubject, comment.DatabaseId, comment.Id, comment.ReactionGroups)
+	return c.storer.saveIssueComment(ctx, repositoryOwner, repositoryName, issueNumber, comment)
+}
+
+func (c *reactionCollector) savePullRequest(ctx context.Context, repositoryOwner, repositoryName string, pr *PullRequest) error {
+	c.add(repositoryOwner, repositoryName, pr.Number, PullRequestSubject, pr.DatabaseId, pr.Id, pr.ReactionGroups)
+	return c.storer.savePullRequest(ctx, repositoryOwner, repositoryName, pr)
+}
+
+func (c *reactionCollector) savePullRequestReview(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, review *PullRequestReview) error {
+	c.add(repositoryOwner, repositoryName, pullRequestNumber, ReviewSubject, review.DatabaseId, review.Id, review.ReactionGroups)
+	return c.storer.savePullRequestReview(ctx, repositoryOwner, repositoryName, pullRequestNumber, review)
+}
+
+func (c *reactionCollector) saveReviewComment(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, reviewID int, comment *PullRequestReviewComment) error {
+	c.add(repositoryOwner, repositoryName, pullRequestNumber, ReviewCommentSubject, comment.DatabaseId, comment.Id, comment.ReactionGroups)
+	return c.storer.saveReviewComment(ctx, repositoryOwner, repositoryName, pullRequestNumber, reviewID, comment)
+}
+
+// downloadReactions saves the collected reactions. With UseReactionUsers the
+// users of the entities with reactions are queried first, in batches of
+// maxNodeIDs with nodes(ids:), and the entities with more than pageList
+// reactions are paginated one by one.
+func (d GitHubDownloader) downloadReactions(ctx context.Context, logger log.Logger, c *reactionCollector) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadReactions", attribute.Int("subjects", len(c.reactions)))
+	defer func() { tracing.End(span, err) }()
+
+	if d.reactionUsers {
+		var pending []*Reactions
+		for _, r := range c.reactions {
+			if r.total() > 0 {
+				pending = append(pending, r)
+			}
+		}
+
+		for len(pending) > 0 {
+			n := len(pending)
+			if n > maxNodeIDs {
+				n = maxNodeIDs
+			}
+
+			err := d.downloadReactionUsers(ctx, logger, pending[:n])
+			if err != nil {
+				return err
+			}
+
+			pending = pending[n:]
+		}
+	}
+
+	for _, r := range c.reactions {
+		if r.total() == 0 {
+			continue
+		}
+
+		err := d.storer.saveReactions(ctx, c.owner, c.name, r)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downloadReactionUsers sets the Users of a batch of at most maxNodeIDs
+// reactions
+func (d GitHubDownloader) downloadReactionUsers(ctx context.Context, logger log.Logger, batch []*Reactions) error {
+	ids := make([]githubv4.ID, len(batch))
+	for i, r := range batch {
+		ids[i] = githubv4.ID(r.subjectNode)
+	}
+
+	var q struct {
+		Nodes []struct {
+			Reactable struct {
+				Reactions ReactionConnection `graphql:"reactions(first: $pageList)"`
+			} `graphql:"... on Reactable"`
+		} `graphql:"nodes(ids: $ids)"`
+	}
+
+	err := d.client.Query(ctx, &q, map[string]interface{}{
+		"ids":      ids,
+		"pageList": githubv4.Int(pageList),
+	})
+	if err != nil {
+		return err
+	}
+	progress.FromContext(ctx).Page()
+
+	for i, node := range q.Nodes {
+		if i >= len(batch) {
+			break
+		}
+
+		r := batch[i]
+		r.Users = make(map[string][]string)
+		addReactionUsers(r, node.Reactable.Reactions.Nodes)
+
+		// if there are more reactions, loop over all the pages
+		hasNextPage := node.Reactable.Reactions.PageInfo.HasNextPage
+		endCursor := node.Reactable.Reactions.PageInfo.EndCursor
+
+		for hasNextPage {
+			logger.With(log.Fields{"subject": r.SubjectType, "id": r.SubjectId}).Debugf("reactions loop")
+
+			var q struct {
+				Node struct {
+					Reactable struct {
+						Reactions ReactionConnection `graphql:"reactions(first: $pageList, after: $reactionsCursor)"`
+					} `graphql:"... on Reactable"`
+				} `graphql:"node(id: $id)"`
+			}
+
+			variables := map[string]interface{}{
+				"id":              githubv4.ID(r.subjectNode),
+				"pageList":        githubv4.Int(pageList),
+				"reactionsCursor": githubv4.String(endCursor),
+			}
+
+			err := d.queryPage(ctx, "reactionsCursor", endCursor, &q, variables)
+			if err != nil {
+				return err
+			}
+
+			addReactionUsers(r, q.Node.Reactable.Reactions.Nodes)
+
+			hasNextPage = q.Node.Reactable.Reactions.PageInfo.HasNextPage
+			endCursor = q.Node.Reactable.Reactions.PageInfo.EndCursor
+		}
+	}
+
+	return nil
+}
+
+// addReactionUsers adds the logins of the reactions to r.Users, the reactions
+// of deleted accounts are added as metadata.Ghost
+func addReactionUsers(r *Reactions, reactions []Reaction) {
+	for _, reaction := range reactions {
+		login := reaction.User.Login
+		if login == "" {
+			login = metadata.Ghost.Login
+		}
+
+		r.Users[reaction.Content] = append(r.Users[reaction.Content], login)
+	}
+}
@@ -1,38 +1,59 @@
 package v4
 
 import (
+	"context"
 	"fmt"
 )
 
 type stdoutStorer struct{}
 
-func (s *stdoutStorer) saveRepository(repository *RepositoryFields) error {
+func (s *stdoutStorer) saveRepository(ctx context.Context, repository *RepositoryFields) error {
 	fmt.Printf("repository data fetched for %s/%s\n", repository.Owner.Login, repository.Name)
 	return nil
 }
 
-func (s *stdoutStorer) saveIssue(repositoryOwner, repositoryName string, issue *Issue) error {
+func (s *stdoutStorer) saveIssue(ctx context.Context, repositoryOwner, repositoryName string, issue *Issue) error {
 	fmt.Printf("issue data fetched for #%v %s\n", issue.Number, issue.Title)
 	return nil
 }
 
-func (s *stdoutStorer) saveIssueComment(repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error {
-	fmt.Printf("  issue comment data fetched by %s at %v: %q\n", comment.Author.Login, comment.CreatedAt, trim(comment.Body))
+func (s *stdoutStorer) saveIssueComment(ctx context.Context, repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error {
+	fmt.Printf("  issue comment data fetched by %s at %v: %q\n", MapActor(comment.Author), comment.CreatedAt, trim(comment.Body))
 	return nil
 }
 
-func (s *stdoutStorer) savePullRequest(pr *PullRequest) error {
+func (s *stdoutStorer) savePullRequest(ctx context.Context, repositoryOwner, repositoryName string, pr *PullRequest) error {
 	fmt.Printf("PR data fetched for #%v %s\n", pr.Number, pr.Title)
 	return nil
 }
 
-func (s *stdoutStorer) savePullRequestReview(review *PullRequestReview) error {
-	fmt.Printf("  PR Review data fetched by %s at %v: %q\n", review.Author.Login, review.CreatedAt, trim(review.Body))
+func (s *stdoutStorer) savePullRequestReview(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, review *PullRequestReview) error {
+	fmt.Printf("  PR Review data fetched by %s at %v: %q\n", MapActor(review.Author), review.CreatedAt, trim(review.Body))
 	return nil
 }
 
-func (s *stdoutStorer) saveReviewComment(comment *PullRequestReviewComment) error {
-	fmt.Printf("    PR review comment data fetched by %s at %v: %q\n", comment.Author.Login, comment.CreatedAt, trim(comment.Body))
+func (s *stdoutStorer) saveReviewComment(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, reviewID int, comment *PullRequestReviewComment) error {
+	fmt.Printf("    PR review comment data fetched by %s at %v: %q\n", MapActor(comment.Author), comment.CreatedAt, trim(comment.Body))
+	return nil
+}
+
+func (s *stdoutStorer) saveReviewThread(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, thread *PullRequestReviewThread) error {
+	fmt.Printf("    PR review thread data fetched on %s: %d comments, resolved %v\n", thread.Path, len(thread.Comments.Nodes), thread.IsResolved)
+	return nil
+}
+
+func (s *stdoutStorer) saveUser(ctx context.Context, user *UserFields) error {
+	fmt.Printf("user data fetched for %s\n", user.Login)
+	return nil
+}
+
+func (s *stdoutStorer) saveOrganization(ctx context.Context, org *OrganizationFields) error {
+	fmt.Printf("organization data fetched for %s\n", org.Login)
+	return nil
+}
+
+func (s *stdoutStorer) saveReactions(ctx context.Context, repositoryOwner, repositoryName string, reactions *Reactions) error {
+	fmt.Printf("  %d reactions fetched for #%v %s %d\n", reactions.total(), reactions.Number, reactions.SubjectType, reactions.SubjectId)
 	return nil
 }
 
@@ -0,0 +1,103 @@
+package v4
+
+import (
+	"context"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/progress"
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
+	"github.com/shurcooL/githubv4"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// pageCount is the page size of the count query, which only asks for the
+// totalCount of the nested connections
+const pageCount = 100
+
+// totals are the number of entities of a repository, by progress type
+type totals map[string]int
+
+// countTotals queries the totalCount of the issues and PRs of the repository,
+// and of their comments, reviews and review threads, so the progress is
+// estimated from the start. The review comments are only known as the
+// reviews are fetched.
+func (d GitHubDownloader) countTotals(ctx context.Context, logger log.Logger, owner, name string) (t totals, err error) {
+	ctx, span := tracing.Start(ctx, "countTotals")
+	defer func() { tracing.End(span, err) }()
+
+	variables := map[string]interface{}{
+		"owner":              githubv4.String(owner),
+		"name":               githubv4.String(name),
+		"pageCount":          githubv4.Int(pageCount),
+		"issuesCursor":       (*githubv4.String)(nil),
+		"pullRequestsCursor": (*githubv4.String)(nil),
+	}
+
+	t = make(totals)
+
+	// both connections are paginated in the same query, the one that ends
+	// first is queried after its last node, and returns no nodes
+	for {
+		var q struct {
+			Repository struct {
+				Issues struct {
+					TotalCount int
+					PageInfo   PageInfo
+					Nodes      []struct {
+						Comments struct {
+							TotalCount int
+						}
+					}
+				} `graphql:"issues(first: $pageCount, after: $issuesCursor)"`
+				PullRequests struct {
+					TotalCount int
+					PageInfo   PageInfo
+					Nodes      []struct {
+						Comments struct {
+							TotalCount int
+						}
+						Reviews struct {
+							TotalCount int
+						}
+						ReviewThreads struct {
+							TotalCount int
+						}
+					}
+				} `graphql:"pullRequests(first: $pageCount, after: $pullRequestsCursor)"`
+			} `graphql:"repository(owner: $owner, name: $name)"`
+		}
+
+		err := d.client.Query(ctx, &q, variables)
+		if err != nil {
+			return nil, err
+		}
+		progress.FromContext(ctx).Page()
+
+		t[progress.Issues] = q.Repository.Issues.TotalCount
+		for _, issue := range q.Repository.Issues.Nodes {
+			t[progress.IssueComments] += issue.Comments.TotalCount
+		}
+
+		t[progress.PullRequests] = q.Repository.PullRequests.TotalCount
+		for _, pr := range q.Repository.PullRequests.Nodes {
+			t[progress.IssueComments] += pr.Comments.TotalCount
+			t[progress.PullRequestReviews] += pr.Reviews.TotalCount
+			t[progress.ReviewThreads] += pr.ReviewThreads.TotalCount
+		}
+
+		issues, prs := q.Repository.Issues.PageInfo, q.Repository.PullRequests.PageInfo
+		if !issues.HasNextPage && !prs.HasNextPage {
+			break
+		}
+
+		logger.Debugf("count loop")
+
+		if issues.EndCursor != "" {
+			variables["issuesCursor"] = githubv4.String(issues.EndCursor)
+		}
+		if prs.EndCursor != "" {
+			variables["pullRequestsCursor"] = githubv4.String(prs.EndCursor)
+		}
+	}
+
+	return t, nil
+}
@@ -2,8 +2,115 @@ package v4
 
 import "time"
 
+// Actor represents https://developer.github.com/v4/interface/actor/. The node
+// ID is used to look up the accounts in batches, see downloadActors. Deleted
+// accounts are returned as null, see IsNull.
 type Actor struct {
-	Login string
+	Typename string `graphql:"__typename"`
+	Login    string
+	Node     struct {
+		Id string
+	} `graphql:"... on Node"`
+	User struct {
+		DatabaseId int
+	} `graphql:"... on User"`
+	Bot struct {
+		DatabaseId int
+	} `graphql:"... on Bot"`
+	Organization struct {
+		DatabaseId int
+	} `graphql:"... on Organization"`
+	Mannequin struct {
+		DatabaseId int
+	} `graphql:"... on Mannequin"`
+}
+
+// IsNull returns true if the actor is null, for a deleted account or an
+// entity that was never edited
+func (a Actor) IsNull() bool {
+	return a.Typename == ""
+}
+
+// DatabaseId returns the database ID of the type of the actor
+func (a Actor) DatabaseId() int {
+	switch a.Typename {
+	case "User":
+		return a.User.DatabaseId
+	case "Bot":
+		return a.Bot.DatabaseId
+	case "Organization":
+		return a.Organization.DatabaseId
+	case "Mannequin":
+		return a.Mannequin.DatabaseId
+	}
+
+	return 0
+}
+
+// UserFields defines the fields for https://developer.github.com/v4/object/user/.
+// Email is empty unless the user made it public. Bots and mannequins are also
+// saved as UserFields, with the fields they have.
+type UserFields struct {
+	Typename   string `graphql:"__typename"`
+	Company    string
+	CreatedAt  time.Time
+	DatabaseId int
+	Email      string
+	Location   string
+	Login      string
+	Name       string
+}
+
+// BotFields defines the fields for https://developer.github.com/v4/object/bot/
+type BotFields struct {
+	CreatedAt  time.Time
+	DatabaseId int
+	Login      string
+}
+
+// MannequinFields defines the fields for
+// https://developer.github.com/v4/object/mannequin/, the placeholders of the
+// users of imported repositories
+type MannequinFields struct {
+	CreatedAt  time.Time
+	DatabaseId int
+	Email      string
+	Login      string
+}
+
+// OrganizationFields defines the fields for
+// https://developer.github.com/v4/object/organization/
+type OrganizationFields struct {
+	CreatedAt   time.Time
+	DatabaseId  int
+	Description string
+	Login       string
+	Name        string
+}
+
+// ReactionGroup represents https://developer.github.com/v4/object/reactiongroup/,
+// the number of reactions of one content
+type ReactionGroup struct {
+	Content string
+	Users   struct {
+		TotalCount int
+	}
+}
+
+// ReactionConnection represents https://developer.github.com/v4/object/reactionconnection/
+type ReactionConnection struct {
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []Reaction
+} // `graphql:"reactions(first: $pageList, after: $reactionsCursor)"`
+
+// Reaction represents https://developer.github.com/v4/object/reaction/. User
+// is null for deleted accounts.
+type Reaction struct {
+	Content string
+	User    struct {
+		Login string
+	}
 }
 
 // PageInfo represents https://developer.github.com/v4/object/pageinfo/
@@ -59,14 +166,15 @@ type RepositoryFields struct {
 
 // IssueConnection represents https://developer.github.com/v4/object/issueconnection/
 type IssueConnection struct {
-	PageInfo PageInfo
-	Nodes    []Issue
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []Issue
 } //`graphql:"issues(first: $pageList, after: $issuesCursor)"`
 
 type IssueCommentsConnection struct {
-	//TotalCount int
-	PageInfo PageInfo
-	Nodes    []IssueComment
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []IssueComment
 } // `graphql:"comments(first: $pageList, after: $issueCommentsCursor)"`
 
 // Issue represents https://developer.github.com/v4/object/issue/
@@ -92,7 +200,7 @@ type IssueFields struct {
 	CreatedViaEmail bool
 	DatabaseId      int
 	//Editor: Actor
-	//Id                  string
+	Id                  string
 	IncludesCreatedEdit bool
 	//labels
 	LastEditedAt time.Time
@@ -101,8 +209,8 @@ type IssueFields struct {
 	Number int
 	//participants
 	//projectCards
-	PublishedAt time.Time
-	//ReactionGroups
+	PublishedAt    time.Time
+	ReactionGroups []ReactionGroup
 	//reactions
 	//Repository: Repository!
 	ResourcePath string
@@ -126,17 +234,17 @@ type IssueComment struct {
 	Body string
 	//bodyHTML: HTML!
 	//BodyText            string
-	CreatedAt       time.Time
-	CreatedViaEmail bool
-	DatabaseId      int
-	Editor          Actor
-	//Id                  string
+	CreatedAt           time.Time
+	CreatedViaEmail     bool
+	DatabaseId          int
+	Editor              Actor
+	Id                  string
 	IncludesCreatedEdit bool
 	IsMinimized         bool
 	LastEditedAt        time.Time
 	MinimizedReason     string
 	PublishedAt         time.Time
-	//reactionGroups: [ReactionGroup!]
+	ReactionGroups      []ReactionGroup
 	//reactions
 	ResourcePath string
 	UpdatedAt    time.Time
@@ -151,14 +259,16 @@ type IssueComment struct {
 }
 
 type PullRequestConnection struct {
-	PageInfo PageInfo
-	Nodes    []PullRequest
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []PullRequest
 } //`graphql:"pullRequests(first: $pageList, after: $pullRequestsCursor)"`
 
 type PullRequest struct {
 	PullRequestFields
-	Comments IssueCommentsConnection     `graphql:"comments(first: $pageList, after: $issueCommentsCursor)"`
-	Reviews  PullRequestReviewConnection `graphql:"reviews(first: $pageList, after: $pullRequestReviewsCursor)"`
+	Comments      IssueCommentsConnection           `graphql:"comments(first: $pageList, after: $issueCommentsCursor)"`
+	Reviews       PullRequestReviewConnection       `graphql:"reviews(first: $pageList, after: $pullRequestReviewsCursor)"`
+	ReviewThreads PullRequestReviewThreadConnection `graphql:"reviewThreads(first: $pageList)"`
 } // `graphql:"pullRequest(number: $issueNumber)"`
 
 type PullRequestFields struct {
@@ -207,8 +317,8 @@ type PullRequestFields struct {
 	Permalink string
 	//PotentialMergeCommit: Commit
 	//projectCards
-	PublishedAt time.Time
-	//ReactionGroups: [ReactionGroup!]
+	PublishedAt    time.Time
+	ReactionGroups []ReactionGroup
 	//reactions
 	ResourcePath       string
 	RevertResourcePath string
@@ -233,9 +343,9 @@ type PullRequestFields struct {
 }
 
 type PullRequestReviewConnection struct {
-	//TotalCount int
-	PageInfo PageInfo
-	Nodes    []PullRequestReview
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []PullRequestReview
 } // `graphql:"reviews(first: $pageList, after: $pullRequestReviewsCursor)"`
 
 type PullRequestReview struct {
@@ -256,7 +366,7 @@ type PullRequestReview struct {
 	//onBehalfOf(
 	PublishedAt time.Time
 	//PullRequest: PullRequest!
-	//ReactionGroups: [ReactionGroup!]
+	ReactionGroups []ReactionGroup
 	//reactions
 	//Repository: Repository!
 	ResourcePath string
@@ -273,9 +383,9 @@ type PullRequestReview struct {
 }
 
 type PullRequestReviewCommentConnection struct {
-	//TotalCount int
-	PageInfo PageInfo
-	Nodes    []PullRequestReviewComment
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []PullRequestReviewComment
 }
 
 type PullRequestReviewComment struct {
@@ -294,7 +404,7 @@ type PullRequestReviewComment struct {
 	LastEditedAt        time.Time
 	MinimizedReason     string
 	PublishedAt         time.Time
-	//reactionGroups: [ReactionGroup!]
+	ReactionGroups      []ReactionGroup
 	//reactions
 	ResourcePath string
 	UpdatedAt    time.Time
@@ -307,3 +417,44 @@ type PullRequestReviewComment struct {
 	// viewerCannotUpdateReasons: [CommentCannotUpdateReason!]!
 	// ViewerDidAuthor bool
 }
+
+type PullRequestReviewThreadConnection struct {
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []PullRequestReviewThread
+} // `graphql:"reviewThreads(first: $pageList, after: $pullRequestReviewThreadsCursor)"`
+
+// PullRequestReviewThread represents
+// https://developer.github.com/v4/object/pullrequestreviewthread/. Line is 0
+// for outdated threads. The comments are the same ones downloaded with the
+// reviews, only their IDs are queried.
+type PullRequestReviewThread struct {
+	Comments PullRequestReviewThreadCommentConnection `graphql:"comments(first: $pageList)"`
+	DiffSide string
+	Id       string
+	//IsCollapsed bool
+	IsOutdated   bool
+	IsResolved   bool
+	Line         int
+	OriginalLine int
+	Path         string
+	//PullRequest: PullRequest!
+	//Repository: Repository!
+	// ResolvedBy is a User, it can not be queried as an Actor
+	ResolvedBy struct {
+		Login string
+		Id    string
+	}
+	//StartLine int
+	//ViewerCanReply bool
+	//ViewerCanResolve bool
+	//ViewerCanUnresolve bool
+}
+
+type PullRequestReviewThreadCommentConnection struct {
+	TotalCount int
+	PageInfo   PageInfo
+	Nodes      []struct {
+		DatabaseId int
+	}
+} // `graphql:"comments(first: $pageList, after: $pullRequestReviewThreadCommentsCursor)"`
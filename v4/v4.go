@@ -5,11 +5,16 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/carlosms/metadata-retrieval-playground"
 	"github.com/carlosms/metadata-retrieval-playground/internal/client"
+	"github.com/carlosms/metadata-retrieval-playground/internal/progress"
+	"github.com/carlosms/metadata-retrieval-playground/internal/store"
+	"github.com/carlosms/metadata-retrieval-playground/internal/tracing"
 	"github.com/shurcooL/githubv4"
+	"go.opentelemetry.io/otel/attribute"
 	"gopkg.in/src-d/go-log.v1"
 )
 
@@ -34,12 +39,16 @@ import (
 const pageList = 40
 
 type storer interface {
-	saveRepository(repository *RepositoryFields) error
-	saveIssue(repositoryOwner, repositoryName string, issue *Issue) error
-	saveIssueComment(repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error
-	savePullRequest(pr *PullRequest) error
-	savePullRequestReview(review *PullRequestReview) error
-	saveReviewComment(comment *PullRequestReviewComment) error
+	saveRepository(ctx context.Context, repository *RepositoryFields) error
+	saveIssue(ctx context.Context, repositoryOwner, repositoryName string, issue *Issue) error
+	saveIssueComment(ctx context.Context, repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error
+	savePullRequest(ctx context.Context, repositoryOwner, repositoryName string, pr *PullRequest) error
+	savePullRequestReview(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, review *PullRequestReview) error
+	saveReviewComment(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, reviewID int, comment *PullRequestReviewComment) error
+	saveReviewThread(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, thread *PullRequestReviewThread) error
+	saveUser(ctx context.Context, user *UserFields) error
+	saveOrganization(ctx context.Context, org *OrganizationFields) error
+	saveReactions(ctx context.Context, repositoryOwner, repositoryName string, reactions *Reactions) error
 
 	begin() error
 	commit() error
@@ -52,7 +61,11 @@ type storer interface {
 type GitHubDownloader struct {
 	storer
 
-	client *githubv4.Client
+	client      *githubv4.Client
+	progressOut *os.File
+
+	// reactionUsers enables the download of the users of the reactions
+	reactionUsers bool
 }
 
 var _ metadata.MetadataDownloader = GitHubDownloader{}
@@ -64,7 +77,7 @@ func NewStdoutDownloader(httpClient *http.Client) (*GitHubDownloader, error) {
 	}
 
 	return &GitHubDownloader{
-		storer: &stdoutStorer{},
+		storer: &instrumentedStorer{storer: &stdoutStorer{}, name: "stdout"},
 		client: githubv4.NewClient(c),
 	}, nil
 }
@@ -76,17 +89,42 @@ func NewDBDownloader(httpClient *http.Client, db *sql.DB) (*GitHubDownloader, er
 	}
 
 	return &GitHubDownloader{
-		storer: &dbStorer{db: db},
+		storer: &instrumentedStorer{storer: &metadataStorer{s: store.NewMetadataDBStorer(db)}, name: "db"},
 		client: githubv4.NewClient(c),
 	}, nil
 }
 
+// ReportProgress enables the progress reports of DownloadRepository to out,
+// as a bar if out is a terminal, or as periodic log lines otherwise
+func (d *GitHubDownloader) ReportProgress(out *os.File) {
+	d.progressOut = out
+}
+
 func (d GitHubDownloader) DownloadRepository(owner string, name string, version string) error {
 	logger := log.New(log.Fields{"owner": owner, "repo": name})
 
-	d.storer.version(version)
+	ctx, span := tracing.Start(context.Background(), "DownloadRepository",
+		attribute.String("owner", owner),
+		attribute.String("repo", name),
+		attribute.String("version", version))
 
 	var err error
+	defer func() { tracing.End(span, err) }()
+
+	var p *progress.Progress
+	if d.progressOut != nil {
+		p = progress.New(d.progressOut, logger)
+		defer p.Finish()
+	}
+	ctx = progress.NewContext(ctx, p)
+
+	d.storer.version(version)
+
+	// d is a copy, the collectors only live for this download
+	actors := newActorCollector(d.storer)
+	reactions := &reactionCollector{storer: actors}
+	d.storer = reactions
+
 	err = d.storer.begin()
 	if err != nil {
 		return err
@@ -101,12 +139,25 @@ func (d GitHubDownloader) DownloadRepository(owner string, name string, version
 		d.storer.commit()
 	}()
 
-	rate0, err := d.rateRemaining()
+	rate0, err := d.rateRemaining(ctx)
 	if err != nil {
 		return err
 	}
 	t0 := time.Now()
 
+	// the totals are only queried to report the progress
+	if p != nil {
+		var totals totals
+		totals, err = d.countTotals(ctx, logger, owner, name)
+		if err != nil {
+			return err
+		}
+
+		for typ, n := range totals {
+			p.AddTotal(typ, n)
+		}
+	}
+
 	var q struct {
 		Repository `graphql:"repository(owner: $owner, name: $name)"`
 	}
@@ -122,12 +173,14 @@ func (d GitHubDownloader) DownloadRepository(owner string, name string, version
 		"pullRequestReviewCommentsCursor": (*githubv4.String)(nil),
 	}
 
-	err = d.client.Query(context.TODO(), &q, variables)
+	err = d.client.Query(ctx, &q, variables)
 	if err != nil {
 		return err
 	}
 
-	err = d.storer.saveRepository(&q.Repository.RepositoryFields)
+	p.Page()
+
+	err = d.storer.saveRepository(ctx, &q.Repository.RepositoryFields)
 	if err != nil {
 		return err
 	}
@@ -138,7 +191,7 @@ func (d GitHubDownloader) DownloadRepository(owner string, name string, version
 	t1 := time.Now()
 
 	// issues and comments
-	err = d.downloadIssues(logger, owner, name, &q.Repository)
+	err = d.downloadIssues(ctx, logger, owner, name, &q.Repository)
 	if err != nil {
 		return err
 	}
@@ -149,7 +202,7 @@ func (d GitHubDownloader) DownloadRepository(owner string, name string, version
 	t2 := time.Now()
 
 	// PRs and comments
-	err = d.downloadPullRequests(logger, owner, name, &q.Repository)
+	err = d.downloadPullRequests(ctx, logger, owner, name, &q.Repository)
 	if err != nil {
 		return err
 	}
@@ -157,27 +210,56 @@ func (d GitHubDownloader) DownloadRepository(owner string, name string, version
 	elapsed = time.Since(t2)
 	logger.With(log.Fields{"elapsed": elapsed}).Infof("issues & issue comments fetched")
 
+	t3 := time.Now()
+
+	// users and organizations of all the actors seen
+	err = d.downloadActors(ctx, logger, actors.ids)
+	if err != nil {
+		return err
+	}
+
+	if actors.ghost {
+		err = d.storer.saveUser(ctx, &ghost)
+		if err != nil {
+			return err
+		}
+	}
+
+	elapsed = time.Since(t3)
+	logger.With(log.Fields{"elapsed": elapsed, "actors": len(actors.ids)}).Infof("users & organizations fetched")
+
+	t4 := time.Now()
+
+	err = d.downloadReactions(ctx, logger, reactions)
+	if err != nil {
+		return err
+	}
+
+	elapsed = time.Since(t4)
+	logger.With(log.Fields{"elapsed": elapsed}).Infof("reactions fetched")
+
 	elapsed = time.Since(t0)
 
-	rate1, err := d.rateRemaining()
+	rate1, err := d.rateRemaining(ctx)
 	if err != nil {
 		return err
 	}
 	rateUsed := rate0 - rate1
+	span.SetAttributes(attribute.Int("github.cost", rateUsed))
 
 	logger.With(log.Fields{"rate-limit-used": rateUsed, "total-elapsed": elapsed}).Infof("All metadata fetched")
 
 	return nil
 }
 
-func (d GitHubDownloader) rateRemaining() (int, error) {
+func (d GitHubDownloader) rateRemaining(ctx context.Context) (int, error) {
 	var q struct {
 		RateLimit struct {
 			Remaining int
 		}
 	}
 
-	err := d.client.Query(context.TODO(), &q, nil)
+	err := d.client.Query(ctx, &q, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -185,13 +267,16 @@ func (d GitHubDownloader) rateRemaining() (int, error) {
 	return q.RateLimit.Remaining, nil
 }
 
-func (d GitHubDownloader) downloadIssues(logger log.Logger, owner string, name string, repository *Repository) error {
+func (d GitHubDownloader) downloadIssues(ctx context.Context, logger log.Logger, owner string, name string, repository *Repository) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadIssues")
+	defer func() { tracing.End(span, err) }()
+
 	process := func(issue *Issue) error {
-		err := d.storer.saveIssue(owner, name, issue)
+		err := d.storer.saveIssue(ctx, owner, name, issue)
 		if err != nil {
 			return err
 		}
-		return d.downloadIssueComments(logger.With(log.Fields{"issue": issue.Number}), owner, name, issue)
+		return d.downloadIssueComments(ctx, logger.With(log.Fields{"issue": issue.Number}), owner, name, issue)
 	}
 
 	// Save issues included in the first page
@@ -225,7 +310,7 @@ func (d GitHubDownloader) downloadIssues(logger log.Logger, owner string, name s
 
 		variables["issuesCursor"] = githubv4.String(endCursor)
 
-		err := d.client.Query(context.TODO(), &q, variables)
+		err := d.queryPage(ctx, "issuesCursor", endCursor, &q, variables)
 		if err != nil {
 			return err
 		}
@@ -244,10 +329,13 @@ func (d GitHubDownloader) downloadIssues(logger log.Logger, owner string, name s
 	return nil
 }
 
-func (d GitHubDownloader) downloadIssueComments(logger log.Logger, owner string, name string, issue *Issue) error {
+func (d GitHubDownloader) downloadIssueComments(ctx context.Context, logger log.Logger, owner string, name string, issue *Issue) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadIssueComments", attribute.Int("issue", issue.Number))
+	defer func() { tracing.End(span, err) }()
+
 	// save first page of comments
 	for _, comment := range issue.Comments.Nodes {
-		err := d.storer.saveIssueComment(owner, name, issue.Number, &comment)
+		err := d.storer.saveIssueComment(ctx, owner, name, issue.Number, &comment)
 		if err != nil {
 			return err
 		}
@@ -278,13 +366,13 @@ func (d GitHubDownloader) downloadIssueComments(logger log.Logger, owner string,
 
 		variables["issueCommentsCursor"] = githubv4.String(endCursor)
 
-		err := d.client.Query(context.TODO(), &q, variables)
+		err := d.queryPage(ctx, "issueCommentsCursor", endCursor, &q, variables)
 		if err != nil {
 			return err
 		}
 
 		for _, comment := range q.Repository.Issue.Comments.Nodes {
-			err := d.storer.saveIssueComment(owner, name, issue.Number, &comment)
+			err := d.storer.saveIssueComment(ctx, owner, name, issue.Number, &comment)
 			if err != nil {
 				return err
 			}
@@ -297,17 +385,24 @@ func (d GitHubDownloader) downloadIssueComments(logger log.Logger, owner string,
 	return nil
 }
 
-func (d GitHubDownloader) downloadPullRequests(logger log.Logger, owner string, name string, repository *Repository) error {
+func (d GitHubDownloader) downloadPullRequests(ctx context.Context, logger log.Logger, owner string, name string, repository *Repository) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadPullRequests")
+	defer func() { tracing.End(span, err) }()
+
 	process := func(pr *PullRequest) error {
-		err := d.storer.savePullRequest(pr)
+		err := d.storer.savePullRequest(ctx, owner, name, pr)
 		if err != nil {
 			return err
 		}
-		err = d.downloadPullRequestComments(logger.With(log.Fields{"pr": pr.Number}), owner, name, pr)
+		err = d.downloadPullRequestComments(ctx, logger.With(log.Fields{"pr": pr.Number}), owner, name, pr)
 		if err != nil {
 			return err
 		}
-		err = d.downloadPullRequestReviews(logger.With(log.Fields{"pr": pr.Number}), owner, name, pr)
+		err = d.downloadPullRequestReviews(ctx, logger.With(log.Fields{"pr": pr.Number}), owner, name, pr)
+		if err != nil {
+			return err
+		}
+		err = d.downloadPullRequestReviewThreads(ctx, logger.With(log.Fields{"pr": pr.Number}), owner, name, pr)
 		if err != nil {
 			return err
 		}
@@ -348,7 +443,7 @@ func (d GitHubDownloader) downloadPullRequests(logger log.Logger, owner string,
 
 		variables["pullRequestsCursor"] = githubv4.String(endCursor)
 
-		err := d.client.Query(context.TODO(), &q, variables)
+		err := d.queryPage(ctx, "pullRequestsCursor", endCursor, &q, variables)
 		if err != nil {
 			return err
 		}
@@ -367,10 +462,13 @@ func (d GitHubDownloader) downloadPullRequests(logger log.Logger, owner string,
 	return nil
 }
 
-func (d GitHubDownloader) downloadPullRequestComments(logger log.Logger, owner string, name string, pr *PullRequest) error {
+func (d GitHubDownloader) downloadPullRequestComments(ctx context.Context, logger log.Logger, owner string, name string, pr *PullRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadPullRequestComments", attribute.Int("pr", pr.Number))
+	defer func() { tracing.End(span, err) }()
+
 	// save first page of comments
 	for _, comment := range pr.Comments.Nodes {
-		err := d.storer.saveIssueComment(owner, name, pr.Number, &comment)
+		err := d.storer.saveIssueComment(ctx, owner, name, pr.Number, &comment)
 		if err != nil {
 			return err
 		}
@@ -395,19 +493,19 @@ func (d GitHubDownloader) downloadPullRequestComments(logger log.Logger, owner s
 			Repository struct {
 				PullRequest struct {
 					Comments IssueCommentsConnection `graphql:"comments(first: $pageList, after: $issueCommentsCursor)"`
-				} `graphql:"issue(number: $issueNumber)"`
+				} `graphql:"pullRequest(number: $issueNumber)"`
 			} `graphql:"repository(owner: $owner, name: $name)"`
 		}
 
 		variables["issueCommentsCursor"] = githubv4.String(endCursor)
 
-		err := d.client.Query(context.TODO(), &q, variables)
+		err := d.queryPage(ctx, "issueCommentsCursor", endCursor, &q, variables)
 		if err != nil {
 			return err
 		}
 
 		for _, comment := range q.Repository.PullRequest.Comments.Nodes {
-			err := d.storer.saveIssueComment(owner, name, pr.Number, &comment)
+			err := d.storer.saveIssueComment(ctx, owner, name, pr.Number, &comment)
 			if err != nil {
 				return err
 			}
@@ -420,13 +518,16 @@ func (d GitHubDownloader) downloadPullRequestComments(logger log.Logger, owner s
 	return nil
 }
 
-func (d GitHubDownloader) downloadPullRequestReviews(logger log.Logger, owner string, name string, pr *PullRequest) error {
+func (d GitHubDownloader) downloadPullRequestReviews(ctx context.Context, logger log.Logger, owner string, name string, pr *PullRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadPullRequestReviews", attribute.Int("pr", pr.Number))
+	defer func() { tracing.End(span, err) }()
+
 	process := func(review *PullRequestReview) error {
-		err := d.storer.savePullRequestReview(review)
+		err := d.storer.savePullRequestReview(ctx, owner, name, pr.Number, review)
 		if err != nil {
 			return err
 		}
-		return d.downloadReviewComments(logger.With(log.Fields{"pr": pr.Number}), owner, name, pr.Number, review)
+		return d.downloadReviewComments(ctx, logger.With(log.Fields{"pr": pr.Number}), owner, name, pr.Number, review)
 	}
 
 	// save first page of reviews
@@ -464,13 +565,16 @@ func (d GitHubDownloader) downloadPullRequestReviews(logger log.Logger, owner st
 
 		variables["pullRequestReviewsCursor"] = githubv4.String(endCursor)
 
-		err := d.client.Query(context.TODO(), &q, variables)
+		err := d.queryPage(ctx, "pullRequestReviewsCursor", endCursor, &q, variables)
 		if err != nil {
 			return err
 		}
 
 		for _, review := range q.Repository.PullRequest.Reviews.Nodes {
-			process(&review)
+			err := process(&review)
+			if err != nil {
+				return err
+			}
 		}
 
 		hasNextPage = q.Repository.PullRequest.Reviews.PageInfo.HasNextPage
@@ -480,36 +584,184 @@ func (d GitHubDownloader) downloadPullRequestReviews(logger log.Logger, owner st
 	return nil
 }
 
-func (d GitHubDownloader) downloadReviewComments(logger log.Logger, repositoryOwner, repositoryName string, issueNumber int, review *PullRequestReview) error {
+func (d GitHubDownloader) downloadReviewComments(ctx context.Context, logger log.Logger, repositoryOwner, repositoryName string, issueNumber int, review *PullRequestReview) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadReviewComments", attribute.Int("pr", issueNumber))
+	defer func() { tracing.End(span, err) }()
+
+	progress.FromContext(ctx).AddTotal(progress.ReviewComments, review.Comments.TotalCount)
+
 	// save first page of comments
 	for _, comment := range review.Comments.Nodes {
-		err := d.storer.saveReviewComment(&comment)
+		err := d.storer.saveReviewComment(ctx, repositoryOwner, repositoryName, issueNumber, review.DatabaseId, &comment)
+		if err != nil {
+			return err
+		}
+	}
+
+	// There isn't a way to ask for repository/pullRequest(number:3)/review(id:X),
+	// so the next pages are requested using the review node ID
+	variables := map[string]interface{}{
+		"id":       githubv4.ID(review.Id),
+		"pageList": githubv4.Int(pageList),
+	}
+
+	// if there are more review comments, loop over all the pages
+	hasNextPage := review.Comments.PageInfo.HasNextPage
+	endCursor := review.Comments.PageInfo.EndCursor
+
+	for hasNextPage {
+		logger.Debugf("PR review comments loop")
+
+		// get only PR review comments
+		var q struct {
+			Node struct {
+				PullRequestReview struct {
+					Comments PullRequestReviewCommentConnection `graphql:"comments(first: $pageList, after: $pullRequestReviewCommentsCursor)"`
+				} `graphql:"... on PullRequestReview"`
+			} `graphql:"node(id: $id)"`
+		}
+
+		variables["pullRequestReviewCommentsCursor"] = githubv4.String(endCursor)
+
+		err := d.queryPage(ctx, "pullRequestReviewCommentsCursor", endCursor, &q, variables)
+		if err != nil {
+			return err
+		}
+
+		for _, comment := range q.Node.PullRequestReview.Comments.Nodes {
+			err := d.storer.saveReviewComment(ctx, repositoryOwner, repositoryName, issueNumber, review.DatabaseId, &comment)
+			if err != nil {
+				return err
+			}
+		}
+
+		hasNextPage = q.Node.PullRequestReview.Comments.PageInfo.HasNextPage
+		endCursor = q.Node.PullRequestReview.Comments.PageInfo.EndCursor
+	}
+
+	return nil
+}
+
+func (d GitHubDownloader) downloadPullRequestReviewThreads(ctx context.Context, logger log.Logger, owner string, name string, pr *PullRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "downloadPullRequestReviewThreads", attribute.Int("pr", pr.Number))
+	defer func() { tracing.End(span, err) }()
+
+	process := func(thread *PullRequestReviewThread) error {
+		err := d.downloadReviewThreadComments(ctx, logger, thread)
+		if err != nil {
+			return err
+		}
+		return d.storer.saveReviewThread(ctx, owner, name, pr.Number, thread)
+	}
+
+	// save first page of threads
+	for _, thread := range pr.ReviewThreads.Nodes {
+		err := process(&thread)
 		if err != nil {
 			return err
 		}
 	}
 
-	/*
-		TODO: how to perform pagination at this level
+	variables := map[string]interface{}{
+		"owner":       githubv4.String(owner),
+		"name":        githubv4.String(name),
+		"pageList":    githubv4.Int(pageList),
+		"issueNumber": githubv4.Int(pr.Number),
+	}
 
-		There isn't a way to ask for repository/pullRequest(number:3)/review(id:X),
-		you can only query all reviews
+	// if there are more threads, loop over all the pages
+	hasNextPage := pr.ReviewThreads.PageInfo.HasNextPage
+	endCursor := pr.ReviewThreads.PageInfo.EndCursor
+
+	for hasNextPage {
+		logger.Debugf("PR review threads loop")
 
-		{
-		  repository(owner: "carlosms-test-org", name: "test-repo") {
-		    pullRequest(number:3) {
-		      reviews {.....}
-		    }
-		  }
+		// get only PR review threads
+		var q struct {
+			Repository struct {
+				PullRequest struct {
+					ReviewThreads PullRequestReviewThreadConnection `graphql:"reviewThreads(first: $pageList, after: $pullRequestReviewThreadsCursor)"`
+				} `graphql:"pullRequest(number: $issueNumber)"`
+			} `graphql:"repository(owner: $owner, name: $name)"`
 		}
-	*/
-	if review.Comments.PageInfo.HasNextPage {
-		log.Errorf(nil, "PR review contains more than on page of comments, but pagination is not implemented")
+
+		variables["pullRequestReviewThreadsCursor"] = githubv4.String(endCursor)
+
+		err := d.queryPage(ctx, "pullRequestReviewThreadsCursor", endCursor, &q, variables)
+		if err != nil {
+			return err
+		}
+
+		for _, thread := range q.Repository.PullRequest.ReviewThreads.Nodes {
+			err := process(&thread)
+			if err != nil {
+				return err
+			}
+		}
+
+		hasNextPage = q.Repository.PullRequest.ReviewThreads.PageInfo.HasNextPage
+		endCursor = q.Repository.PullRequest.ReviewThreads.PageInfo.EndCursor
 	}
 
 	return nil
 }
 
+// downloadReviewThreadComments adds the next pages of comments of the thread
+// to thread.Comments.Nodes
+func (d GitHubDownloader) downloadReviewThreadComments(ctx context.Context, logger log.Logger, thread *PullRequestReviewThread) error {
+	variables := map[string]interface{}{
+		"id":       githubv4.ID(thread.Id),
+		"pageList": githubv4.Int(pageList),
+	}
+
+	// if there are more thread comments, loop over all the pages
+	hasNextPage := thread.Comments.PageInfo.HasNextPage
+	endCursor := thread.Comments.PageInfo.EndCursor
+
+	for hasNextPage {
+		logger.Debugf("PR review thread comments loop")
+
+		var q struct {
+			Node struct {
+				PullRequestReviewThread struct {
+					Comments PullRequestReviewThreadCommentConnection `graphql:"comments(first: $pageList, after: $pullRequestReviewThreadCommentsCursor)"`
+				} `graphql:"... on PullRequestReviewThread"`
+			} `graphql:"node(id: $id)"`
+		}
+
+		variables["pullRequestReviewThreadCommentsCursor"] = githubv4.String(endCursor)
+
+		err := d.queryPage(ctx, "pullRequestReviewThreadCommentsCursor", endCursor, &q, variables)
+		if err != nil {
+			return err
+		}
+
+		comments := q.Node.PullRequestReviewThread.Comments
+		thread.Comments.Nodes = append(thread.Comments.Nodes, comments.Nodes...)
+
+		hasNextPage = comments.PageInfo.HasNextPage
+		endCursor = comments.PageInfo.EndCursor
+	}
+
+	return nil
+}
+
+// queryPage runs the query for the next page of a pagination loop, in its own
+// span
+func (d GitHubDownloader) queryPage(ctx context.Context, cursorName string, cursor string, q interface{}, variables map[string]interface{}) error {
+	ctx, span := tracing.Start(ctx, "queryPage",
+		attribute.String("cursor.variable", cursorName),
+		attribute.String("cursor", cursor))
+
+	err := d.client.Query(ctx, q, variables)
+	if err == nil {
+		progress.FromContext(ctx).Page()
+	}
+
+	tracing.End(span, err)
+	return err
+}
+
 func (d GitHubDownloader) DownloadOrg(name string, version string) error {
 	return fmt.Errorf("not implemented")
 }
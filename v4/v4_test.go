@@ -0,0 +1,547 @@
+package v4
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/carlosms/metadata-retrieval-playground/internal/client"
+	"github.com/carlosms/metadata-retrieval-playground/internal/fakegithub"
+	"github.com/carlosms/metadata-retrieval-playground/internal/progress"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// memoryStorer keeps a copy of all the saved entities in memory
+type memoryStorer struct {
+	stdoutStorer
+
+	repositories   []RepositoryFields
+	issues         []Issue
+	issueComments  []IssueComment
+	prs            []PullRequest
+	reviews        []PullRequestReview
+	reviewComments []PullRequestReviewComment
+	reviewThreads  []PullRequestReviewThread
+	users          []UserFields
+	orgs           []OrganizationFields
+	reactions      []Reactions
+}
+
+func (s *memoryStorer) saveRepository(ctx context.Context, repository *RepositoryFields) error {
+	s.repositories = append(s.repositories, *repository)
+	return nil
+}
+
+func (s *memoryStorer) saveIssue(ctx context.Context, repositoryOwner, repositoryName string, issue *Issue) error {
+	s.issues = append(s.issues, *issue)
+	return nil
+}
+
+func (s *memoryStorer) saveIssueComment(ctx context.Context, repositoryOwner, repositoryName string, issueNumber int, comment *IssueComment) error {
+	s.issueComments = append(s.issueComments, *comment)
+	return nil
+}
+
+func (s *memoryStorer) savePullRequest(ctx context.Context, repositoryOwner, repositoryName string, pr *PullRequest) error {
+	s.prs = append(s.prs, *pr)
+	return nil
+}
+
+func (s *memoryStorer) savePullRequestReview(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, review *PullRequestReview) error {
+	s.reviews = append(s.reviews, *review)
+	return nil
+}
+
+func (s *memoryStorer) saveReviewComment(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, reviewID int, comment *PullRequestReviewComment) error {
+	s.reviewComments = append(s.reviewComments, *comment)
+	return nil
+}
+
+func (s *memoryStorer) saveReviewThread(ctx context.Context, repositoryOwner, repositoryName string, pullRequestNumber int, thread *PullRequestReviewThread) error {
+	s.reviewThreads = append(s.reviewThreads, *thread)
+	return nil
+}
+
+func (s *memoryStorer) saveUser(ctx context.Context, user *UserFields) error {
+	s.users = append(s.users, *user)
+	return nil
+}
+
+func (s *memoryStorer) saveOrganization(ctx context.Context, org *OrganizationFields) error {
+	s.orgs = append(s.orgs, *org)
+	return nil
+}
+
+func (s *memoryStorer) saveReactions(ctx context.Context, repositoryOwner, repositoryName string, reactions *Reactions) error {
+	s.reactions = append(s.reactions, *reactions)
+	return nil
+}
+
+func replayDownloader(t *testing.T, path string) (*GitHubDownloader, *memoryStorer) {
+	cassette, err := client.LoadCassette(path)
+	require.NoError(t, err)
+
+	storer := &memoryStorer{}
+	return &GitHubDownloader{
+		storer: storer,
+		client: githubv4.NewClient(&http.Client{Transport: client.NewReplayer(cassette)}),
+	}, storer
+}
+
+func fakeDownloader(srv *fakegithub.Server) (*GitHubDownloader, *memoryStorer) {
+	storer := &memoryStorer{}
+	return &GitHubDownloader{
+		storer: storer,
+		client: githubv4.NewEnterpriseClient(srv.GraphQLURL(), srv.Client()),
+	}, storer
+}
+
+func TestDownloadRepositoryReplay(t *testing.T) {
+	require := require.New(t)
+
+	d, storer := replayDownloader(t, "testdata/test-repo.json")
+	require.NoError(d.DownloadRepository("carlosms-test-org", "test-repo", "v0"))
+
+	require.Len(storer.repositories, 1)
+	require.Equal("carlosms-test-org/test-repo", storer.repositories[0].NameWithOwner)
+
+	require.Len(storer.issues, 2)
+	require.Len(storer.prs, 2)
+	require.Len(storer.issueComments, 2)
+	require.Len(storer.reviews, 3)
+	require.Len(storer.reviewComments, 2)
+
+	require.Equal("carlosms-bot", storer.reviews[0].Author.Login)
+
+	require.Len(storer.orgs, 1)
+	require.Equal(46494994, storer.orgs[0].DatabaseId)
+	require.Len(storer.users, 2)
+	require.Equal("carlosms", storer.users[0].Login)
+	require.Equal("Madrid, Spain", storer.users[0].Location)
+	require.Equal("carlosms-bot", storer.users[1].Login)
+}
+
+func TestDownloadRepositoryPagination(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  fakegithub.Config
+	}{
+		{"empty", fakegithub.Config{}},
+		{"issues", fakegithub.Config{
+			Issues:           2*pageList + 1,
+			CommentsPerIssue: pageList + 1,
+		}},
+		{"PRs", fakegithub.Config{
+			PullRequests:                 pageList + 1,
+			CommentsPerPullRequest:       pageList + 1,
+			ReviewsPerPullRequest:        2,
+			CommentsPerPullRequestReview: 2,
+		}},
+		{"reviews", fakegithub.Config{
+			PullRequests:                 1,
+			ReviewsPerPullRequest:        pageList + 1,
+			CommentsPerPullRequestReview: pageList + 1,
+		}},
+		{"exact page", fakegithub.Config{
+			Issues:                       pageList,
+			CommentsPerIssue:             pageList,
+			PullRequests:                 pageList,
+			ReviewsPerPullRequest:        1,
+			CommentsPerPullRequestReview: pageList,
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require := require.New(t)
+
+			c.cfg.Owner, c.cfg.Name = "org", "repo"
+			repo := fakegithub.NewRepository(c.cfg)
+
+			srv := fakegithub.NewServer(repo)
+			defer srv.Close()
+
+			d, storer := fakeDownloader(srv)
+			require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+			require.Len(storer.repositories, 1)
+			require.Equal(repo.ID, storer.repositories[0].DatabaseId)
+
+			issueIDs := make(map[int]bool)
+			for _, i := range storer.issues {
+				issueIDs[i.DatabaseId] = true
+			}
+			require.Equal(c.cfg.Issues, len(issueIDs))
+			require.Len(storer.issues, c.cfg.Issues)
+
+			prIDs := make(map[int]bool)
+			for _, pr := range storer.prs {
+				prIDs[pr.DatabaseId] = true
+			}
+			require.Equal(c.cfg.PullRequests, len(prIDs))
+			require.Len(storer.prs, c.cfg.PullRequests)
+
+			commentIDs := make(map[int]bool)
+			for _, comment := range storer.issueComments {
+				commentIDs[comment.DatabaseId] = true
+			}
+			comments := c.cfg.Issues*c.cfg.CommentsPerIssue + c.cfg.PullRequests*c.cfg.CommentsPerPullRequest
+			require.Equal(comments, len(commentIDs))
+			require.Len(storer.issueComments, comments)
+
+			reviews := c.cfg.PullRequests * c.cfg.ReviewsPerPullRequest
+			require.Len(storer.reviews, reviews)
+
+			reviewCommentIDs := make(map[int]bool)
+			for _, comment := range storer.reviewComments {
+				reviewCommentIDs[comment.DatabaseId] = true
+			}
+			reviewComments := reviews * c.cfg.CommentsPerPullRequestReview
+			require.Equal(reviewComments, len(reviewCommentIDs))
+			require.Len(storer.reviewComments, reviewComments)
+
+			// each thread has the comments on one position of all the reviews
+			threads := 0
+			if c.cfg.ReviewsPerPullRequest > 0 {
+				threads = c.cfg.PullRequests * c.cfg.CommentsPerPullRequestReview
+			}
+			require.Len(storer.reviewThreads, threads)
+
+			threadCommentIDs := make(map[int]bool)
+			for _, thread := range storer.reviewThreads {
+				require.Len(thread.Comments.Nodes, c.cfg.ReviewsPerPullRequest)
+				for _, comment := range thread.Comments.Nodes {
+					require.True(reviewCommentIDs[comment.DatabaseId])
+					threadCommentIDs[comment.DatabaseId] = true
+				}
+			}
+			require.Equal(reviewComments, len(threadCommentIDs))
+		})
+	}
+}
+
+func TestDownloadRepositoryReviewThreads(t *testing.T) {
+	require := require.New(t)
+
+	repo := fakegithub.NewRepository(fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		PullRequests:                 1,
+		ReviewsPerPullRequest:        2,
+		CommentsPerPullRequestReview: 3,
+	})
+	repo.PullRequests[0].Threads[1].ResolvedBy = "dave"
+
+	srv := fakegithub.NewServer(repo)
+	defer srv.Close()
+
+	d, storer := fakeDownloader(srv)
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	require.Len(storer.reviewThreads, 3)
+
+	open := MapReviewThread("org", "repo", 1, &storer.reviewThreads[0])
+	require.False(open.IsResolved)
+	require.False(open.IsOutdated)
+	require.Empty(open.ResolvedBy)
+	require.Equal("README.md", open.Path)
+	require.Equal(1, open.Line)
+	require.Equal(1, open.OriginalLine)
+	require.Equal([]int64{
+		int64(storer.reviewComments[0].DatabaseId),
+		int64(storer.reviewComments[3].DatabaseId),
+	}, open.CommentIDs)
+
+	resolved := MapReviewThread("org", "repo", 1, &storer.reviewThreads[1])
+	require.True(resolved.IsResolved)
+	require.Equal("dave", resolved.ResolvedBy)
+
+	outdated := MapReviewThread("org", "repo", 1, &storer.reviewThreads[2])
+	require.True(outdated.IsOutdated)
+	require.Zero(outdated.Line)
+	require.Equal(3, outdated.OriginalLine)
+
+	// the user that resolved the thread is saved with the other actors
+	var logins []string
+	for _, u := range storer.users {
+		logins = append(logins, u.Login)
+	}
+	require.Contains(logins, "dave")
+}
+
+func TestDownloadRepositoryActors(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       3,
+		CommentsPerIssue:             2,
+		PullRequests:                 2,
+		ReviewsPerPullRequest:        1,
+		CommentsPerPullRequestReview: 1,
+	}))
+	defer srv.Close()
+
+	d, storer := fakeDownloader(srv)
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	require.Len(storer.orgs, 1)
+	require.Equal("org", storer.orgs[0].Login)
+	require.NotZero(storer.orgs[0].DatabaseId)
+
+	logins := make(map[string]bool)
+	for _, u := range storer.users {
+		require.False(logins[u.Login], "user %s saved twice", u.Login)
+		logins[u.Login] = true
+		require.NotZero(u.DatabaseId)
+		require.NotEmpty(u.Name)
+		require.False(u.CreatedAt.IsZero())
+	}
+	require.NotEmpty(logins)
+}
+
+func TestDownloadRepositoryActorTypes(t *testing.T) {
+	require := require.New(t)
+
+	repo := fakegithub.NewRepository(fakegithub.Config{
+		Owner:            "org",
+		Name:             "repo",
+		Issues:           2,
+		CommentsPerIssue: 1,
+	})
+	repo.Issues[0].Author = ""
+	repo.Issues[1].Comments[0].Author = "dependabot[bot]"
+
+	srv := fakegithub.NewServer(repo)
+	defer srv.Close()
+
+	d, storer := fakeDownloader(srv)
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	require.True(storer.issues[0].Author.IsNull())
+	require.Equal("ghost", MapActor(storer.issues[0].Author))
+
+	bot := storer.issueComments[1].Author
+	require.Equal("Bot", bot.Typename)
+	require.Equal("dependabot", bot.Login)
+	require.NotZero(bot.DatabaseId())
+	require.Equal("dependabot[bot]", MapActor(bot))
+
+	require.Equal("User", storer.issues[1].Author.Typename)
+	require.NotZero(storer.issues[1].Author.DatabaseId())
+
+	types := make(map[string]string)
+	for _, u := range storer.users {
+		types[MapUser(&u).Login] = u.Typename
+	}
+	require.Equal("Bot", types["dependabot[bot]"])
+	require.Equal("Ghost", types["ghost"])
+	require.Equal("User", types[storer.issues[1].Author.Login])
+}
+
+func TestDownloadActorsBatches(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{
+		Owner:  "org",
+		Name:   "repo",
+		Issues: 1,
+	}))
+	defer srv.Close()
+
+	d, storer := fakeDownloader(srv)
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+	owner := storer.repositories[0].Owner.Node.Id
+	author := storer.issues[0].Author.Node.Id
+
+	// the IDs that do not exist resolve to null
+	var ids []string
+	for i := 0; i < 2*maxNodeIDs+1; i++ {
+		ids = append(ids, fmt.Sprintf("missing-%d", i))
+	}
+	ids[0], ids[2*maxNodeIDs] = owner, author
+
+	d, storer = fakeDownloader(srv)
+	before := len(srv.Requests())
+	require.NoError(d.downloadActors(context.Background(), log.New(nil), ids))
+
+	require.Equal(3, len(srv.Requests())-before)
+	require.Len(storer.orgs, 1)
+	require.Len(storer.users, 1)
+}
+
+func TestDownloadRepositoryReactions(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       2,
+		CommentsPerIssue:             1,
+		PullRequests:                 1,
+		ReviewsPerPullRequest:        1,
+		CommentsPerPullRequestReview: 1,
+		Reactions:                    3,
+	}))
+	defer srv.Close()
+
+	d, storer := fakeDownloader(srv)
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	subjects := make(map[string]int)
+	for _, r := range storer.reactions {
+		subjects[r.SubjectType]++
+		require.NotZero(r.SubjectId)
+		require.Nil(r.Users)
+
+		reactions := MapReactions("org", "repo", &r)
+		require.Len(reactions, 2)
+		require.Equal("THUMBS_UP", reactions[0].Content)
+		require.Equal(2, reactions[0].Count)
+		require.Equal("HEART", reactions[1].Content)
+		require.Equal(1, reactions[1].Count)
+	}
+	require.Equal(map[string]int{
+		IssueSubject:         2,
+		IssueCommentSubject:  2,
+		PullRequestSubject:   1,
+		ReviewSubject:        1,
+		ReviewCommentSubject: 1,
+	}, subjects)
+}
+
+func TestDownloadRepositoryReactionUsers(t *testing.T) {
+	require := require.New(t)
+
+	srv := fakegithub.NewServer(fakegithub.NewRepository(fakegithub.Config{
+		Owner:     "org",
+		Name:      "repo",
+		Issues:    2,
+		Reactions: pageList + 5,
+	}))
+	defer srv.Close()
+
+	d, storer := fakeDownloader(srv)
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+	without := len(srv.Requests())
+
+	d, storer = fakeDownloader(srv)
+	d.UseReactionUsers()
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	// one nodes(ids:) request, and one more page for each issue
+	require.Equal(3, len(srv.Requests())-2*without)
+
+	require.Len(storer.reactions, 2)
+	for _, r := range storer.reactions {
+		var users int
+		for _, reaction := range MapReactions("org", "repo", &r) {
+			require.Len(reaction.Users, reaction.Count)
+			users += len(reaction.Users)
+		}
+		require.Equal(pageList+5, users)
+		require.Contains(r.Users["THUMBS_UP"], "user-0")
+	}
+}
+
+func TestDownloadRepositoryNotFound(t *testing.T) {
+	srv := fakegithub.NewServer()
+	defer srv.Close()
+
+	d, _ := fakeDownloader(srv)
+	require.Error(t, d.DownloadRepository("org", "missing", "v0"))
+}
+
+func TestDownloadRepositoryTracing(t *testing.T) {
+	require := require.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	repo := fakegithub.NewRepository(fakegithub.Config{
+		Owner:            "org",
+		Name:             "repo",
+		Issues:           pageList + 1,
+		CommentsPerIssue: 1,
+	})
+
+	srv := fakegithub.NewServer(repo)
+	defer srv.Close()
+
+	d, storer := fakeDownloader(srv)
+	d.storer = &instrumentedStorer{storer: storer, name: "memory"}
+	d.client = githubv4.NewEnterpriseClient(srv.GraphQLURL(), &http.Client{
+		Transport: &client.TracingTransport{T: srv.Client().Transport},
+	})
+
+	require.NoError(d.DownloadRepository("org", "repo", "v0"))
+
+	spans := make(map[string]int)
+	var root *sdktrace.SpanSnapshot
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name]++
+		if s.Name == "DownloadRepository" {
+			root = s
+		}
+	}
+
+	require.NotNil(root)
+	require.False(root.Parent.IsValid())
+	for _, s := range exporter.GetSpans() {
+		require.Equal(root.SpanContext.TraceID(), s.SpanContext.TraceID(), s.Name)
+	}
+
+	require.Equal(1, spans["downloadIssues"])
+	require.Equal(pageList+1, spans["downloadIssueComments"])
+	require.Equal(1, spans["queryPage"])
+	require.Equal(pageList+1, spans["save issue"])
+	require.Equal(pageList+1, spans["save issue_comment"])
+	require.Equal(1, spans["downloadActors"])
+	require.Equal(len(storer.users), spans["save user"])
+	require.Equal(1, spans["save organization"])
+	require.Equal(1, spans["downloadReactions"])
+	require.Zero(spans["save reaction"])
+	// repository, next page of issues, actors, and the rate limit twice
+	require.Equal(5, spans["HTTP POST"])
+}
+
+func TestCountTotals(t *testing.T) {
+	require := require.New(t)
+
+	cfg := fakegithub.Config{
+		Owner:                        "org",
+		Name:                         "repo",
+		Issues:                       2*pageCount + 1,
+		CommentsPerIssue:             2,
+		PullRequests:                 pageCount + 1,
+		CommentsPerPullRequest:       1,
+		ReviewsPerPullRequest:        2,
+		CommentsPerPullRequestReview: 3,
+	}
+	srv := fakegithub.NewServer(fakegithub.NewRepository(cfg))
+	defer srv.Close()
+
+	d, _ := fakeDownloader(srv)
+	got, err := d.countTotals(context.Background(), log.New(nil), "org", "repo")
+	require.NoError(err)
+
+	require.Equal(totals{
+		progress.Issues:             cfg.Issues,
+		progress.IssueComments:      cfg.Issues*cfg.CommentsPerIssue + cfg.PullRequests*cfg.CommentsPerPullRequest,
+		progress.PullRequests:       cfg.PullRequests,
+		progress.PullRequestReviews: cfg.PullRequests * cfg.ReviewsPerPullRequest,
+		progress.ReviewThreads:      cfg.PullRequests * cfg.CommentsPerPullRequestReview,
+	}, got)
+
+	// the issues need 3 pages, the PRs are paginated in the same queries
+	require.Len(srv.Requests(), 3)
+}